@@ -0,0 +1,126 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/shellwords"
+)
+
+// aliasSection is the section of the config file in which aliases are
+// defined.
+const aliasSection = "alias"
+
+func (cli *cli) EnableAliases() {
+	cli.aliasesEnabled = true
+}
+
+func (cli *cli) validateAliases(vflags []ValidationFlag) error {
+	if cli.aliasesEnabled && !cli.app.HasSubCmds {
+		return errors.New("aliases require sub-commands")
+	}
+
+	if !validateFlagIsSet(vflags, ValidateAliases) {
+		return nil
+	}
+
+	_, shadowing, err := cli.aliases()
+	if err != nil {
+		return err
+	}
+	if len(shadowing) > 0 {
+		return shadowErr(shadowing[0])
+	}
+	return nil
+}
+
+// aliases returns the aliases defined in the config file, if aliases are
+// enabled. Aliases which shadow a command could never be run, and are
+// returned separately.
+func (cli *cli) aliases() (aliases, shadowing []app.Alias, err error) {
+	if !cli.aliasesEnabled {
+		return nil, nil, nil
+	}
+
+	cfg, err := cli.readConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range cfg.Keys(aliasSection) {
+		command, _ := cfg.Get(aliasSection, name)
+		alias := app.Alias{Name: name, Command: command}
+		if cli.command(name) != nil || name == "help" || name == "version" {
+			shadowing = append(shadowing, alias)
+		} else {
+			aliases = append(aliases, alias)
+		}
+	}
+
+	return aliases, shadowing, nil
+}
+
+func shadowErr(alias app.Alias) error {
+	return fmt.Errorf("alias %q shadows the %s command", alias.Name, strings.ToLower(alias.Name))
+}
+
+// expandAliases replaces an alias at the start of args with the command
+// line for which it stands, repeatedly, until args begin with something
+// other than an alias. An alias may not expand to itself, directly or
+// via other aliases.
+func expandAliases(aliases []app.Alias, args []string) ([]string, error) {
+	expanded := []string{}
+	for len(args) > 0 {
+		alias, ok := findAlias(aliases, args[0])
+		if !ok {
+			return args, nil
+		}
+
+		for _, name := range expanded {
+			if name == alias.Name {
+				chain := strings.Join(append(expanded, alias.Name), " -> ")
+				return nil, fmt.Errorf("alias %q is recursive: %s", expanded[0], chain)
+			}
+		}
+		expanded = append(expanded, alias.Name)
+
+		expansion, err := shellwords.Split(alias.Command)
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %s", alias.Name, err)
+		}
+		if len(expansion) == 0 {
+			return nil, fmt.Errorf("alias %q is empty", alias.Name)
+		}
+
+		args = append(expansion, args[1:]...)
+	}
+
+	return args, nil
+}
+
+func findAlias(aliases []app.Alias, name string) (app.Alias, bool) {
+	for _, alias := range aliases {
+		if alias.Name == name {
+			return alias, true
+		}
+	}
+	return app.Alias{}, false
+}
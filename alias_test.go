@@ -0,0 +1,233 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestExpandAliases(t *testing.T) {
+	aliases := []app.Alias{
+		{Name: "dp", Command: "deploy --env=prod --confirm"},
+		{Name: "dpw", Command: "dp --wait"},
+		{Name: "quoted", Command: `echo "a b" 'c'`},
+		{Name: "loop1", Command: "loop2 x"},
+		{Name: "loop2", Command: "loop1 y"},
+		{Name: "self", Command: "self"},
+		{Name: "empty", Command: ""},
+		{Name: "bad", Command: `echo "unterminated`},
+	}
+
+	for _, tc := range []struct {
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{args: []string{}, want: []string{}},
+		{args: []string{"deploy", "dp"}, want: []string{"deploy", "dp"}},
+		{args: []string{"dp"}, want: []string{"deploy", "--env=prod", "--confirm"}},
+		{args: []string{"dpw", "x"}, want: []string{"deploy", "--env=prod", "--confirm", "--wait", "x"}},
+		{args: []string{"quoted"}, want: []string{"echo", "a b", "c"}},
+		{args: []string{"loop1"}, wantErr: `alias "loop1" is recursive: loop1 -> loop2 -> loop1`},
+		{args: []string{"self"}, wantErr: `alias "self" is recursive: self -> self`},
+		{args: []string{"empty"}, wantErr: `alias "empty" is empty`},
+		{args: []string{"bad"}, wantErr: `alias "bad": `},
+	} {
+		assert.Group(
+			fmt.Sprintf("expandAliases(%q)", tc.args),
+			t,
+			func(g *assert.G) {
+				got, err := expandAliases(aliases, tc.args)
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+				} else {
+					assert.Nil(g, err)
+					assert.DeepEqual(g, got, tc.want)
+				}
+			},
+		)
+	}
+}
+
+func mkAliasCLI(t *testing.T, aliases string) (*cli, func()) {
+	dir, err := ioutil.TempDir("", "aliases")
+	assert.Nil(t, err)
+
+	configFile := filepath.Join(dir, "config")
+	assert.Nil(t, ioutil.WriteFile(configFile, []byte("[alias]\n"+aliases), 0600))
+
+	var env string
+	deploy := &command.Cmd{
+		Name:    "deploy",
+		Summary: "deploy it",
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			fmt.Fprintf(cmd.Stdout(), "%s %q\n", env, args)
+			return command.NoError()
+		}),
+	}
+	deploy.Flags.StringVar(&env, "env", "dev", "the environment")
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, deploy).(*cli)
+	c.flags.Bool("verbose", false, "be verbose")
+	c.SetConfigFile(configFile)
+	c.EnableAliases()
+
+	return c, func() { os.RemoveAll(dir) }
+}
+
+func TestCLIRunAliases(t *testing.T) {
+	c, cleanup := mkAliasCLI(t, "dp = deploy --env=prod\n")
+	defer cleanup()
+
+	cmdErr, stdout, _ := runCLI(c, []string{"--verbose", "dp", "a", "b"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `prod ["a" "b"]`+"\n")
+	assert.DeepEqual(t, c.globalArgs, []string{"--verbose"})
+
+	cmdErr, stdout, _ = runCLI(c, []string{"help", "dp"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.True(t, strings.HasPrefix(stdout, "NAME\n    deploy - deploy it"))
+
+	c.newUsage = func(w io.Writer, mode style.Mode) app.Usage {
+		return c.app.StyledRedirectedUsage(w, style.Never)
+	}
+	cmdErr, stdout, _ = runCLI(c, []string{"help"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.True(t, strings.Contains(stdout, "ALIASES\n    dp      deploy --env=prod\n"))
+}
+
+func TestCLIRunAliasErrors(t *testing.T) {
+	for _, tc := range []struct {
+		aliases string
+		args    []string
+		wantErr string
+	}{
+		{
+			aliases: "a = b\nb = a\n",
+			args:    []string{"a"},
+			wantErr: `alias "a" is recursive: a -> b -> a`,
+		},
+		{
+			aliases: "deploy = deploy --env=prod\n",
+			args:    []string{"deploy"},
+			wantErr: `alias "deploy" shadows the deploy command`,
+		},
+		{
+			aliases: "dp = undeploy\n",
+			args:    []string{"dp"},
+			wantErr: `unknown command: "undeploy"`,
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q) with %q", tc.args, tc.aliases),
+			t,
+			func(g *assert.G) {
+				c, cleanup := mkAliasCLI(t, tc.aliases)
+				defer cleanup()
+
+				cmdErr, _, stderr := runCLI(c, tc.args, map[string]string{}, "")
+				assert.Equal(g, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+				assert.True(g, strings.Contains(stderr, tc.wantErr))
+			},
+		)
+	}
+}
+
+func TestCLIRunShadowingAliases(t *testing.T) {
+	c, cleanup := mkAliasCLI(t, "deploy = deploy --env=prod\ndp = deploy --env=qa\n")
+	defer cleanup()
+
+	// other aliases and commands still run
+	cmdErr, stdout, stderr := runCLI(c, []string{"dp", "a"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `qa ["a"]`+"\n")
+	assert.Equal(t, stderr, "")
+
+	cmdErr, stdout, stderr = runCLI(c, []string{"--help"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.True(t, strings.Contains(stdout, "dp"))
+	assert.False(t, strings.Contains(stdout, "deploy --env=prod"))
+	assert.Equal(t, stderr, "warning: alias \"deploy\" shadows the deploy command\n")
+
+	cmdErr, _, stderr = runCLI(c, []string{"deploy"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+	assert.True(t, strings.Contains(stderr, `alias "deploy" shadows the deploy command`))
+
+	assert.Nil(t, c.Validate())
+	assert.ErrorContains(t, c.Validate(ValidateAliases), `alias "deploy" shadows the deploy command`)
+}
+
+func TestCLIRunAliasesDefaultConfigPath(t *testing.T) {
+	writeConfig := func(aliases string) string {
+		home, err := ioutil.TempDir("", "home")
+		assert.Nil(t, err)
+		dir := filepath.Join(home, ".config", "app")
+		assert.Nil(t, os.MkdirAll(dir, 0700))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "config"), []byte("[alias]\n"+aliases), 0600))
+		return home
+	}
+
+	// the process's config file is not consulted
+	processHome := writeConfig("deploy = deploy --env=shadowed\n")
+	defer os.RemoveAll(processHome)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", processHome)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", "")
+
+	runHome := writeConfig("dp = deploy --env=prod\n")
+	defer os.RemoveAll(runHome)
+
+	c, cleanup := mkAliasCLI(t, "")
+	defer cleanup()
+	c.SetConfigFile("")
+
+	cmdErr, stdout, _ := runCLI(c, []string{"dp", "a"}, map[string]string{"HOME": runHome}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `prod ["a"]`+"\n")
+
+	// without a home directory, there is no config file
+	cmdErr, stdout, _ = runCLI(c, []string{"deploy", "b"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `dev ["b"]`+"\n")
+}
+
+func TestValidateAliases(t *testing.T) {
+	c, cleanup := mkAliasCLI(t, "")
+	defer cleanup()
+	assert.Nil(t, c.Validate())
+
+	c, cleanup = mkAliasCLI(t, "help = deploy\n")
+	defer cleanup()
+	assert.Nil(t, c.Validate())
+	assert.ErrorContains(t, c.Validate(ValidateAliases), `alias "help" shadows the help command`)
+
+	c = mkNew(app.App{Name: "app"}, &command.Cmd{Name: "app"}).(*cli)
+	c.EnableAliases()
+	assert.ErrorContains(t, c.Validate(), "aliases require sub-commands")
+}
@@ -21,27 +21,83 @@ package app
 
 import (
 	"io"
+	"io/ioutil"
 	"os"
+
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
+	tbnflag "github.com/turbinelabs/nonstdlib/flag"
 )
 
 // A simple representation of a command-line application
 type App struct {
-	Name          string // the binary name of the application
-	Description   string // a short description of what the application does
-	VersionString string // the current version of the application
-	HasSubCmds    bool   // whether or not the app has sub commands
+	Name           string             // the binary name of the application
+	Description    string             // a short description of what the application does
+	VersionString  string             // the current version of the application
+	HasSubCmds     bool               // whether or not the app has sub commands
+	UsageTemplates UsageTemplates     // optional replacements for the default usage templates
+	ExitCodes      []command.ExitCode // application-defined exit codes, described in usage
+	Plugins        []Plugin           // external commands, listed in usage
+	Aliases        []Alias            // user-defined shortcuts for commands, listed in usage
+	ShortUsage     bool               // if true, usage omits advanced flags, as for -h
+}
+
+// An Alias is a user-defined name for a command and its arguments: "<app>
+// name" runs "<app> command".
+type Alias struct {
+	Name    string // the alias
+	Command string // the command line for which it stands
+}
+
+// A Plugin is an external command, provided by an executable named for
+// the application and the command: "<app> foo" runs "<app>-foo".
+type Plugin struct {
+	Name string // the command name
+	Path string // the path of the executable
 }
 
 // Usage produces the default implementation of Usage for this App, which
-// prints tab-formatted output to STDOUT.
+// prints tab-formatted output to STDOUT, styled if STDOUT is a terminal.
 func (a App) Usage() Usage {
-	return newUsage(a, os.Stdout, widthFromTerm, false)
+	return a.StyledUsage(style.Auto)
+}
+
+// StyledUsage produces a Usage for this App, which prints tab-formatted
+// output to STDOUT, styled according to the given style.Mode.
+func (a App) StyledUsage(mode style.Mode) Usage {
+	return newUsage(a, os.Stdout, widthFromTerm, mode)
 }
 
 // RedirectedUsage produces a Usage for this App, which prints
 // tab-formatted output to the given Writer at a width of 80 columns.
 func (a App) RedirectedUsage(writer io.Writer) Usage {
-	return newUsage(a, writer, 80, false)
+	return newUsage(a, writer, 80, style.Auto)
+}
+
+// StyledRedirectedUsage produces a Usage for this App, which prints
+// tab-formatted output to the given Writer at a width of 80 columns,
+// styled according to the given style.Mode.
+func (a App) StyledRedirectedUsage(writer io.Writer, mode style.Mode) Usage {
+	return newUsage(a, writer, 80, mode)
+}
+
+// ValidateUsageTemplates verifies that the usage templates for this App
+// parse, without rendering them.
+func (a App) ValidateUsageTemplates() error {
+	_, err := mkUsage(a, ioutil.Discard, 80, style.Never)
+	return err
+}
+
+// ValidateUsage verifies that the usage templates for this App parse, and
+// that global usage and the usage for each of the given command.Cmds
+// render without error. The cmdFlagsFromEnv function returns the
+// tbnflag.FromEnv for a given command.Cmd.
+func (a App) ValidateUsage(
+	cmds []*command.Cmd,
+	globalFlagsFromEnv tbnflag.FromEnv,
+	cmdFlagsFromEnv func(*command.Cmd) tbnflag.FromEnv,
+) error {
+	return validateUsage(a, cmds, globalFlagsFromEnv, cmdFlagsFromEnv)
 }
 
 func (a App) Version() Version {
@@ -34,8 +34,10 @@ func (m *MockUsage) EXPECT() *MockUsageMockRecorder {
 }
 
 // Global mocks base method
-func (m *MockUsage) Global(cmds []*command.Cmd, flagsFromEnv flag.FromEnv) {
-	m.ctrl.Call(m, "Global", cmds, flagsFromEnv)
+func (m *MockUsage) Global(cmds []*command.Cmd, flagsFromEnv flag.FromEnv) error {
+	ret := m.ctrl.Call(m, "Global", cmds, flagsFromEnv)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // Global indicates an expected call of Global
@@ -44,8 +46,10 @@ func (mr *MockUsageMockRecorder) Global(cmds, flagsFromEnv interface{}) *gomock.
 }
 
 // Command mocks base method
-func (m *MockUsage) Command(cmd *command.Cmd, globalFlagsFromEnv, cmdFlagsFromEnv flag.FromEnv) {
-	m.ctrl.Call(m, "Command", cmd, globalFlagsFromEnv, cmdFlagsFromEnv)
+func (m *MockUsage) Command(cmd *command.Cmd, globalFlagsFromEnv, cmdFlagsFromEnv flag.FromEnv) error {
+	ret := m.ctrl.Call(m, "Command", cmd, globalFlagsFromEnv, cmdFlagsFromEnv)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // Command indicates an expected call of Command
@@ -20,12 +20,13 @@ package app
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"go/doc"
 	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	"sort"
 	"strconv"
 	"strings"
@@ -33,9 +34,9 @@ import (
 	"text/template"
 	"unicode"
 
-	tty "github.com/mattn/go-isatty"
-
 	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
+	"github.com/turbinelabs/cli/terminal"
 	tbnflag "github.com/turbinelabs/nonstdlib/flag"
 	"github.com/turbinelabs/nonstdlib/flag/usage"
 )
@@ -46,11 +47,13 @@ const widthFromTerm = -1
 // No specific output format or destination is specified by the interface.
 type Usage interface {
 	// Global outputs the global usage for an App, based on the provided
-	// command.Cmds and flag.FlagSet
-	Global(cmds []*command.Cmd, flagsFromEnv tbnflag.FromEnv)
+	// command.Cmds and flag.FlagSet. It returns an error if the usage
+	// template fails to parse or render.
+	Global(cmds []*command.Cmd, flagsFromEnv tbnflag.FromEnv) error
 	// Command outputs the usage for the given command.Cmd, including global and
-	// command flags.
-	Command(cmd *command.Cmd, globalFlagsFromEnv tbnflag.FromEnv, cmdFlagsFromEnv tbnflag.FromEnv)
+	// command flags. It returns an error if the usage template fails to parse
+	// or render.
+	Command(cmd *command.Cmd, globalFlagsFromEnv tbnflag.FromEnv, cmdFlagsFromEnv tbnflag.FromEnv) error
 }
 
 // The default implementation of Usage for this App, prints tab-formatted
@@ -61,27 +64,44 @@ type usageT struct {
 	commandUsageTemplate *template.Template
 	tabWriter            *tabwriter.Writer
 	width                int
+	style                style.Style
+	err                  error // the error parsing the templates, if any
 }
 
 const (
 	rightIndent     = 4 // The right-side padding for cleaned text
 	preformatIndent = 4 // The extra indent for preformatted text
+)
 
-	globalUsageTemplateStr = `
-{{bold "NAME"}}
+// DefaultGlobalUsageTemplate is the text/template used to render global
+// usage, unless overridden by App.UsageTemplates. It is executed with a
+// GlobalUsageData.
+const DefaultGlobalUsageTemplate = `{{bold "NAME"}}
 {{cleanf 4 "%s - %s" .Executable .Description}}
 {{bold "USAGE"}}
 {{cleanf 4 "%s [GLOBAL OPTIONS] <command> [COMMAND OPTIONS] [arguments...]" .Executable}}
 {{bold "VERSION"}}
 {{clean 4 .Version}}
-{{bold "COMMANDS"}}{{range .Commands}}
-{{cmd .Name .Summary}}{{end}}
-{{bold "GLOBAL OPTIONS"}}{{range .GlobalFlags.AllFlags}}{{option .}}{{end}}
-{{optionsText "Global options" .GlobalFlags.Prefix .GlobalFlags.Filled}}
-{{- cmdHelp .Executable}}
+{{range .CommandGroups}}{{bold .Heading}}{{range .Commands}}
+{{cmd .Name (summary .)}}{{end}}
+{{end}}{{if .Aliases}}{{bold "ALIASES"}}{{range .Aliases}}
+{{cmd .Name .Command}}{{end}}
+{{end}}{{if .Plugins}}{{bold "PLUGINS"}}{{range .Plugins}}
+{{cmd .Name .Path}}{{end}}
+{{end}}{{range optionGroups "GLOBAL " .GlobalFlags}}{{bold .Heading}}{{range .Flags}}{{option . $.GlobalFlags}}{{end}}
+{{end}}{{optionsText "Global options" .GlobalFlags.Prefix .GlobalFlags.Filled}}
+{{- if omitsAdvanced .GlobalFlags}}{{advancedHelp}}
+{{end -}}
+{{- if .ExitCodes}}{{bold "EXIT STATUS"}}{{range .ExitCodes}}
+{{exitCode .}}{{end}}
+{{end -}}
+{{cmdHelp .Executable}}
 `
-	commandUsageTemplateStr = `
-{{bold "NAME"}}
+
+// DefaultCommandUsageTemplate is the text/template used to render command
+// usage, unless overridden by App.UsageTemplates. It is executed with a
+// CommandUsageData.
+const DefaultCommandUsageTemplate = `{{bold "NAME"}}
 {{if .HasSubCmds}}{{cleanf 4 "%s - %s" .Cmd.Name .Cmd.Summary}}
 {{else}}{{cleanf 4 "%s - %s" .Executable .Cmd.Summary}}
 {{end}}{{bold "USAGE"}}
@@ -89,47 +109,214 @@ const (
 {{else}}{{cleanf 4 "%s %s" .Executable .Cmd.Usage}}
 {{end}}{{bold "VERSION"}}
 {{clean 4 .Version}}
-{{bold "DESCRIPTION"}}
+{{if .Cmd.Deprecated}}{{bold "DEPRECATED"}}
+{{clean 4 .Cmd.Deprecated}}
+{{end}}{{bold "DESCRIPTION"}}
 {{clean 4 .Cmd.Description}}
-{{if .HasSubCmds}}{{bold "GLOBAL OPTIONS"}}{{range .GlobalFlags.AllFlags}}{{option .}}{{end}}
-{{optionsText "Global options" .GlobalFlags.Prefix .GlobalFlags.Filled}}{{end -}}
-{{if .CmdFlags}}{{bold "OPTIONS"}}{{range .CmdFlags.AllFlags}}{{option .}}{{end}}
-{{optionsText "Options" .CmdFlags.Prefix .CmdFlags.Filled}}{{end}}`
-)
+{{if .HasSubCmds}}{{range optionGroups "GLOBAL " .GlobalFlags}}{{bold .Heading}}{{range .Flags}}{{option . $.GlobalFlags}}{{end}}
+{{end}}{{optionsText "Global options" .GlobalFlags.Prefix .GlobalFlags.Filled}}{{end -}}
+{{if .CmdFlags}}{{range optionGroups "" .CmdFlags}}{{bold .Heading}}{{range .Flags}}{{option . $.CmdFlags}}{{end}}
+{{end}}{{optionsText "Options" .CmdFlags.Prefix .CmdFlags.Filled}}{{end -}}
+{{if or (and .HasSubCmds (omitsAdvanced .GlobalFlags)) (omitsAdvanced .CmdFlags)}}{{advancedHelp}}
+{{end -}}
+{{if .Cmd.Examples}}{{bold "EXAMPLES"}}{{range .Cmd.Examples}}
+{{example .}}{{end}}
+{{end}}{{if .ExitCodes}}{{bold "EXIT STATUS"}}{{range .ExitCodes}}
+{{exitCode .}}{{end}}
+{{end}}`
+
+// UsageTemplates optionally replaces the text/templates used to render
+// usage. An empty template uses the corresponding default.
+//
+// In addition to the text/template builtins, templates may use the
+// following functions:
+//
+//	bold <text>                          render text in bold
+//	ul <text>                            render text underlined
+//	faint <text>                         render text with decreased intensity
+//	clean <indent> <text>                collapse whitespace, indent and wrap text
+//	cleanf <indent> <format> <args...>   format, then clean, text
+//	indent <indent> <text>               indent each line of text, without wrapping
+//	option <*flag.Flag> [<FromEnv>]      render a flag, its default and usage, and its
+//	                                     environment variable, if the FromEnv is given
+//	optionGroups <prefix> <FromEnv>      group listed flags by category, under headings with a prefix
+//	omitsAdvanced <FromEnv>              whether brief usage omits any advanced flags
+//	advancedHelp                         describe how to get usage of advanced flags
+//	optionsText <desc> <prefix> <filled> describe the environment variables for flags
+//	cmd <name> <summary>                 render a command name and summary
+//	summary <*command.Cmd>               a command's summary, marked if deprecated
+//	example <command.Example>            render an example, with its command line verbatim
+//	exitCode <command.ExitCode>          render an exit code and its description
+//	globalHelp <executable>              describe how to get global help
+//	cmdHelp <executable>                 describe how to get command help
+//	join <separator> <[]string>          join strings with a separator
+//	upper <text>                         convert text to upper case
+//	lower <text>                         convert text to lower case
+type UsageTemplates struct {
+	Global  string // replaces DefaultGlobalUsageTemplate
+	Command string // replaces DefaultCommandUsageTemplate
+}
 
-func notGraphic(r rune) bool {
-	return !unicode.IsGraphic(r)
+// GlobalUsageData is the data with which the global usage template is
+// executed. Hidden commands are omitted from Commands and CommandGroups.
+type GlobalUsageData struct {
+	Executable    string
+	Commands      []*command.Cmd
+	CommandGroups []CommandGroup
+	GlobalFlags   tbnflag.FromEnv
+	Description   string
+	Version       string
+	ExitCodes     []command.ExitCode
+	Plugins       []Plugin
+	Aliases       []Alias
 }
 
-var consoleIsInteractive = true
+// CommandGroup is a set of commands listed together under a heading in
+// global usage.
+type CommandGroup struct {
+	Heading  string
+	Commands []*command.Cmd
+}
+
+// groupCommands omits hidden commands and groups the remainder by
+// category. Uncategorized commands come first, under COMMANDS, followed by
+// each category in the order it first appears.
+func groupCommands(cmds []*command.Cmd) ([]*command.Cmd, []CommandGroup) {
+	visible := []*command.Cmd{}
+	groups := []CommandGroup{{Heading: "COMMANDS"}}
+	index := map[string]int{"": 0}
+
+	for _, cmd := range cmds {
+		if cmd.Hidden {
+			continue
+		}
+		visible = append(visible, cmd)
+
+		i, ok := index[cmd.Category]
+		if !ok {
+			i = len(groups)
+			index[cmd.Category] = i
+			groups = append(groups, CommandGroup{Heading: strings.ToUpper(cmd.Category)})
+		}
+		groups[i].Commands = append(groups[i].Commands, cmd)
+	}
 
-func ul(s string) string {
-	if consoleIsInteractive {
-		return "\033[4m" + s + "\033[0m"
+	if len(groups[0].Commands) == 0 {
+		groups = groups[1:]
 	}
-	return s
+
+	return visible, groups
+}
+
+// OptionGroup is a set of flags listed together under a heading in usage.
+type OptionGroup struct {
+	Heading string
+	Flags   []*flag.Flag
 }
 
-func bold(s string) string {
-	if consoleIsInteractive {
-		return "\033[1m" + s + "\033[0m"
+// groupOptions groups the flags listed in usage by category, under
+// headings beginning with the given prefix. Uncategorized flags come
+// first, under "<prefix>OPTIONS", followed by each category in
+// alphabetical order, except that advanced flags come last.
+func (u usageT) groupOptions(prefix string, flagsFromEnv tbnflag.FromEnv) []OptionGroup {
+	if flagsFromEnv == nil {
+		return nil
+	}
+
+	byCategory := map[string][]*flag.Flag{}
+	categories := []string{}
+	for _, f := range flagsFromEnv.AllFlags() {
+		if !u.listed(f) {
+			continue
+		}
+		category := command.FlagCategory(f)
+		if _, ok := byCategory[category]; !ok && category != "" {
+			categories = append(categories, category)
+		}
+		byCategory[category] = append(byCategory[category], f)
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		iAdvanced := categories[i] == command.AdvancedCategory
+		jAdvanced := categories[j] == command.AdvancedCategory
+		if iAdvanced != jAdvanced {
+			return jAdvanced
+		}
+		return categories[i] < categories[j]
+	})
+
+	groups := []OptionGroup{}
+	// the heading is shown even without flags, unless others follow
+	if len(byCategory[""]) > 0 || len(categories) == 0 {
+		groups = append(groups, OptionGroup{Heading: prefix + "OPTIONS", Flags: byCategory[""]})
+	}
+	for _, category := range categories {
+		groups = append(groups, OptionGroup{
+			Heading: prefix + strings.ToUpper(category) + " OPTIONS",
+			Flags:   byCategory[category],
+		})
 	}
-	return s
+	return groups
 }
 
-func termWidth() int {
-	cmd := exec.Command("stty", "size")
-	cmd.Stdin = os.Stdin
-	bytes, err := cmd.Output()
-	if err != nil {
-		return 80
+// listed indicates whether the flag is listed in usage. The help and
+// version short flags are described by their long forms.
+func (u usageT) listed(f *flag.Flag) bool {
+	if f.Name == "h" || f.Name == "v" || command.FlagHidden(f) {
+		return false
 	}
-	pair := strings.SplitN(string(bytes), " ", 2)
-	w, err := strconv.Atoi(strings.TrimSpace(pair[1]))
-	if err != nil {
-		return 80
+	return !u.app.ShortUsage || !command.FlagAdvanced(f)
+}
+
+// omitsAdvanced indicates whether brief usage omits any of the flags.
+func (u usageT) omitsAdvanced(flagsFromEnv tbnflag.FromEnv) bool {
+	if !u.app.ShortUsage || flagsFromEnv == nil {
+		return false
+	}
+	for _, f := range flagsFromEnv.AllFlags() {
+		if !command.FlagHidden(f) && command.FlagAdvanced(f) {
+			return true
+		}
 	}
-	return w
+	return false
+}
+
+func (u usageT) advancedHelp() string {
+	return u.clean(4, "Advanced options are not shown. Run with --help to show all options.")
+}
+
+// CommandUsageData is the data with which the command usage template is
+// executed.
+type CommandUsageData struct {
+	Executable  string
+	HasSubCmds  bool
+	Cmd         *command.Cmd
+	GlobalFlags tbnflag.FromEnv
+	CmdFlags    tbnflag.FromEnv
+	Version     string
+	ExitCodes   []command.ExitCode
+}
+
+// exitCodes returns the default exit codes followed by the App's exit
+// codes, ordered by code, or nil if the App defines no exit codes.
+func exitCodes(a App) []command.ExitCode {
+	if len(a.ExitCodes) == 0 {
+		return nil
+	}
+
+	codes := append(command.DefaultExitCodes(), a.ExitCodes...)
+	sort.SliceStable(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+func notGraphic(r rune) bool {
+	return !unicode.IsGraphic(r)
+}
+
+func termWidth() int {
+	return terminal.GetSize(os.Stdin.Fd()).Columns
 }
 
 func (u usageT) cleanf(indent int, format string, args ...interface{}) string {
@@ -139,8 +326,9 @@ func (u usageT) cleanf(indent int, format string, args ...interface{}) string {
 // replace whitespace with a single space, indent and wrap
 func (u usageT) clean(indent int, s string) string {
 	templFuncs := template.FuncMap{
-		"bold": bold,
-		"ul":   ul,
+		"bold":  u.style.Bold,
+		"ul":    u.style.Underline,
+		"faint": u.style.Faint,
 	}
 
 	var buf bytes.Buffer
@@ -158,14 +346,16 @@ func (u usageT) clean(indent int, s string) string {
 	return res.String()
 }
 
-// print a flag, including defaults
-func (u usageT) option(f *flag.Flag) string {
+// print a flag, including defaults and, if a FromEnv is given, the
+// environment variable from which it is filled
+func (u usageT) option(f *flag.Flag, flagsFromEnv ...tbnflag.FromEnv) string {
 	fCopy := *f
 	usg := usage.New(f.Usage)
 	fCopy.Usage = usg.Pretty()
+	fCopy.Value = command.UnwrapValue(f.Value)
 
 	typeName, usage := flag.UnquoteUsage(&fCopy)
-	if f.Name == "h" || f.Name == "v" {
+	if !u.listed(f) {
 		return ""
 	}
 	prefix := "--"
@@ -178,11 +368,16 @@ func (u usageT) option(f *flag.Flag) string {
 		eq = ""
 	}
 
+	repeatable, isRepeatable := fCopy.Value.(command.RepeatableValue)
+	if isRepeatable && typeName == "value" {
+		typeName = repeatable.ElemType()
+	}
+
 	_, isCustomValue := f.Value.(flag.Getter)
 	isString := typeName == "string"
 	if isCustomValue {
 		switch f.Value.(flag.Getter).Get().(type) {
-		case string, *string, []string:
+		case string, *string, []string, map[string]string:
 			isString = true
 		}
 	}
@@ -203,11 +398,13 @@ func (u usageT) option(f *flag.Flag) string {
 		}
 	}
 
+	validators := command.FlagValidators(f)
+
 	result := ""
 	nameLen := len(prefix + f.Name + eq + typeName)
-	fullName := "    " + prefix + ul(f.Name) + eq + typeName
+	fullName := "    " + prefix + u.style.Underline(f.Name) + eq + typeName
 	//     --name=type (default: x)
-	if nameLen < 7 && validValues == "" {
+	if nameLen < 7 && validValues == "" && !isRepeatable && len(validators) == 0 {
 		result += fullName
 		if defValue != "" {
 			result += fmt.Sprintf(
@@ -220,6 +417,8 @@ func (u usageT) option(f *flag.Flag) string {
 		//     --longerName=type
 		//            (default: x)
 		//            (valid values: x, y, or z)
+		//            (may be repeated)
+		//            (must be between 1 and 10)
 		result += u.clean(4, fullName)
 		if defValue != "" {
 			result += u.cleanf(12, "(%s)", defValue)
@@ -227,24 +426,67 @@ func (u usageT) option(f *flag.Flag) string {
 		if validValues != "" {
 			result += u.cleanf(12, "(%s)", validValues)
 		}
+		if isRepeatable {
+			result += u.clean(12, "(may be repeated)")
+		}
+		for _, v := range validators {
+			// descriptions may include regular expressions, which
+			// must not be parsed as templates
+			result += u.cleanf(12, "({{ %q }})", v.Description())
+		}
+	}
+	//            (env: PREFIX_NAME)
+	// though help and version can be set from the environment, they
+	// shouldn't be
+	if len(flagsFromEnv) > 0 && flagsFromEnv[0] != nil && f.Name != "help" && f.Name != "version" {
+		if key, ok := command.FlagEnvKey(flagsFromEnv[0].Prefix(), f); ok {
+			result += u.cleanf(12, "(env: %s)", key)
+		}
 	}
 	result += u.clean(12, usage)
 	return "\n" + result
 }
 
 func (u usageT) cmd(name, desc string) string {
+	return u.term(name, u.style.Underline(name), desc)
+}
+
+// render an exit code and its description
+func (u usageT) exitCode(ec command.ExitCode) string {
+	code := strconv.Itoa(int(ec.Code))
+	return u.term(code, code, ec.Description)
+}
+
+// render a term and its description, on the same line if they fit
+func (u usageT) term(name, styledName, desc string) string {
 	cleanDesc := u.clean(12, desc)
 	if len(name) < 7 && len(cleanDesc) < u.width {
 		cleanDesc = u.clean(0, desc)
 		return fmt.Sprintf(
 			"    %s%s%s",
-			ul(name),
+			styledName,
 			strings.Repeat(" ", 8-len(name)),
 			cleanDesc,
 		)
 	}
-	return fmt.Sprintf("    %s\n%s", ul(name), cleanDesc)
+	return fmt.Sprintf("    %s\n%s", styledName, cleanDesc)
+}
 
+// print an example, with a cleaned explanation and a verbatim command line
+func (u usageT) example(ex command.Example) string {
+	result := ""
+	if ex.Explanation != "" {
+		result += u.clean(4, ex.Explanation) + "\n"
+	}
+	return result + u.indent(8, ex.CommandLine)
+}
+
+// a command's summary, marked if the command is deprecated
+func (u usageT) summary(cmd *command.Cmd) string {
+	if cmd.Deprecated != "" {
+		return "[DEPRECATED] " + cmd.Summary
+	}
+	return cmd.Summary
 }
 
 func (u usageT) optionsText(prefix string, envKey string, flagsFromEnv map[string]string) string {
@@ -269,6 +511,18 @@ precedence over environment variables.`
 	return result + strings.Join(lines, "")
 }
 
+// indent each line of the given text, without otherwise reformatting it
+func (u usageT) indent(indent int, s string) string {
+	prefix := strings.Repeat(" ", indent)
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
 func (u usageT) globalHelp(name string) string {
 	return u.cleanf(0, `For global options run "%s help".`, name)
 }
@@ -277,76 +531,165 @@ func (u usageT) cmdHelp(name string) string {
 	return u.cleanf(0, `Run "%s help <command>" for more details on a specific command.`, name)
 }
 
-func newUsage(a App, wr io.Writer, width int, forceInteractive bool) Usage {
+func newUsage(a App, wr io.Writer, width int, mode style.Mode) Usage {
+	u, err := mkUsage(a, wr, width, mode)
+	if err != nil {
+		return usageT{err: err}
+	}
+	return u
+}
+
+func mkUsage(a App, wr io.Writer, width int, mode style.Mode) (usageT, error) {
 	if width == widthFromTerm {
 		width = termWidth()
 	}
 	tabWriter := new(tabwriter.Writer)
 	tabWriter.Init(wr, 0, 8, 1, '\t', 0)
 
-	if !forceInteractive {
-		consoleIsInteractive = tty.IsTerminal(os.Stdout.Fd())
+	u := usageT{app: a, tabWriter: tabWriter, width: width, style: style.For(wr, mode)}
+
+	templFuncs := template.FuncMap{
+		"bold":          u.style.Bold,
+		"ul":            u.style.Underline,
+		"faint":         u.style.Faint,
+		"clean":         u.clean,
+		"cmd":           u.cmd,
+		"example":       u.example,
+		"exitCode":      u.exitCode,
+		"summary":       u.summary,
+		"cleanf":        u.cleanf,
+		"indent":        u.indent,
+		"option":        u.option,
+		"optionsText":   u.optionsText,
+		"optionGroups":  u.groupOptions,
+		"omitsAdvanced": u.omitsAdvanced,
+		"advancedHelp":  u.advancedHelp,
+		"globalHelp":    u.globalHelp,
+		"cmdHelp":       u.cmdHelp,
+		"join":          func(sep string, strs []string) string { return strings.Join(strs, sep) },
+		"upper":         strings.ToUpper,
+		"lower":         strings.ToLower,
 	}
 
-	u := usageT{app: a, tabWriter: tabWriter, width: width}
+	globalTemplateStr := a.UsageTemplates.Global
+	if globalTemplateStr == "" {
+		globalTemplateStr = DefaultGlobalUsageTemplate
+	}
 
-	templFuncs := template.FuncMap{
-		"bold":        bold,
-		"ul":          ul,
-		"clean":       u.clean,
-		"cmd":         u.cmd,
-		"cleanf":      u.cleanf,
-		"option":      u.option,
-		"optionsText": u.optionsText,
-		"globalHelp":  u.globalHelp,
-		"cmdHelp":     u.cmdHelp,
+	commandTemplateStr := a.UsageTemplates.Command
+	if commandTemplateStr == "" {
+		commandTemplateStr = DefaultCommandUsageTemplate
 	}
 
-	u.globalUsageTemplate = template.Must(
-		template.New("global_usage").Funcs(templFuncs).Parse(globalUsageTemplateStr[1:]))
+	var err error
+	u.globalUsageTemplate, err =
+		template.New("global_usage").Funcs(templFuncs).Parse(globalTemplateStr)
+	if err != nil {
+		return usageT{}, err
+	}
 
-	u.commandUsageTemplate = template.Must(
-		template.New("command_usage").Funcs(templFuncs).Parse(commandUsageTemplateStr[1:]))
+	u.commandUsageTemplate, err =
+		template.New("command_usage").Funcs(templFuncs).Parse(commandTemplateStr)
+	if err != nil {
+		return usageT{}, err
+	}
 
-	return u
+	return u, nil
 }
 
-func (u usageT) Global(cmds []*command.Cmd, flagsFromEnv tbnflag.FromEnv) {
-	u.globalUsageTemplate.Execute(u.tabWriter, struct {
-		Executable  string
-		Commands    []*command.Cmd
-		GlobalFlags tbnflag.FromEnv
-		Description string
-		Version     string
-	}{
-		u.app.Name,
-		cmds,
-		flagsFromEnv,
-		u.app.Description,
-		u.app.VersionString,
+func (u usageT) Global(cmds []*command.Cmd, flagsFromEnv tbnflag.FromEnv) error {
+	if u.err != nil {
+		return u.err
+	}
+
+	visible, groups := groupCommands(cmds)
+	err := u.globalUsageTemplate.Execute(u.tabWriter, GlobalUsageData{
+		Executable:    u.app.Name,
+		Commands:      visible,
+		CommandGroups: groups,
+		GlobalFlags:   flagsFromEnv,
+		Description:   u.app.Description,
+		Version:       u.app.VersionString,
+		ExitCodes:     exitCodes(u.app),
+		Plugins:       u.app.Plugins,
+		Aliases:       u.app.Aliases,
 	})
 	u.tabWriter.Flush()
+	return err
 }
 
 func (u usageT) Command(
 	cmd *command.Cmd,
 	globalFlagsFromEnv tbnflag.FromEnv,
 	cmdFlagsFromEnv tbnflag.FromEnv,
-) {
-	u.commandUsageTemplate.Execute(u.tabWriter, struct {
-		Executable  string
-		HasSubCmds  bool
-		Cmd         *command.Cmd
-		GlobalFlags tbnflag.FromEnv
-		CmdFlags    tbnflag.FromEnv
-		Version     string
-	}{
-		u.app.Name,
-		u.app.HasSubCmds,
-		cmd,
-		globalFlagsFromEnv,
-		cmdFlagsFromEnv,
-		u.app.VersionString,
+) error {
+	if u.err != nil {
+		return u.err
+	}
+
+	err := u.commandUsageTemplate.Execute(u.tabWriter, CommandUsageData{
+		Executable:  u.app.Name,
+		HasSubCmds:  u.app.HasSubCmds,
+		Cmd:         cmd,
+		GlobalFlags: globalFlagsFromEnv,
+		CmdFlags:    cmdFlagsFromEnv,
+		Version:     u.app.VersionString,
+		ExitCodes:   exitCodes(u.app),
 	})
 	u.tabWriter.Flush()
+	return err
+}
+
+// validateUsage renders global usage and the usage of each of the given
+// command.Cmds, returning an error describing any failures.
+func validateUsage(
+	a App,
+	cmds []*command.Cmd,
+	globalFlagsFromEnv tbnflag.FromEnv,
+	cmdFlagsFromEnv func(*command.Cmd) tbnflag.FromEnv,
+) error {
+	errs := []string{}
+
+	try := func(f func() error) {
+		defer func() {
+			if e := recover(); e != nil {
+				switch v := e.(type) {
+				case error:
+					errs = append(errs, v.Error())
+				default:
+					errs = append(errs, fmt.Sprintf("%v", e))
+				}
+			}
+		}()
+
+		if err := f(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	var u usageT
+	try(func() error {
+		var err error
+		u, err = mkUsage(a, ioutil.Discard, 80, style.Never)
+		return err
+	})
+
+	if len(errs) == 0 {
+		try(func() error {
+			return u.Global(cmds, globalFlagsFromEnv)
+		})
+		for _, cmd := range cmds {
+			try(func() error {
+				return u.Command(cmd, globalFlagsFromEnv, cmdFlagsFromEnv(cmd))
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		msg := "error(s) generating help text:\n  "
+		msg += strings.Join(errs, "\n  ")
+		return errors.New(msg + "\n")
+	}
+
+	return nil
 }
@@ -19,10 +19,14 @@ package app
 import (
 	"bytes"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
 	tbnflag "github.com/turbinelabs/nonstdlib/flag"
 	"github.com/turbinelabs/nonstdlib/flag/usage"
 	"github.com/turbinelabs/test/assert"
@@ -47,8 +51,25 @@ func testFlagsFromEnv(scopes ...string) tbnflag.FromEnv {
 	return fe
 }
 
-var subCmdApp = App{"foo", "maybe foo, maybe bar", "1.0", true}
-var singleCmdApp = App{"bar", "maybe bar, maybe baz", "1.1", false}
+var testStyle = style.New(true)
+
+func bold(s string) string { return testStyle.Bold(s) }
+
+func ul(s string) string { return testStyle.Underline(s) }
+
+var subCmdApp = App{
+	Name:          "foo",
+	Description:   "maybe foo, maybe bar",
+	VersionString: "1.0",
+	HasSubCmds:    true,
+}
+
+var singleCmdApp = App{
+	Name:          "bar",
+	Description:   "maybe bar, maybe baz",
+	VersionString: "1.1",
+	HasSubCmds:    false,
+}
 
 func TestUsageGlobal(t *testing.T) {
 	cmds := []*command.Cmd{
@@ -58,7 +79,7 @@ func TestUsageGlobal(t *testing.T) {
 	}
 
 	buf := new(bytes.Buffer)
-	usage := newUsage(subCmdApp, buf, 84, true)
+	usage := newUsage(subCmdApp, buf, 84, style.Always)
 	usage.Global(cmds, testFlagsFromEnv(subCmdApp.Name))
 
 	assert.Equal(t, buf.String(), bold("NAME")+`
@@ -81,23 +102,29 @@ func TestUsageGlobal(t *testing.T) {
 `+bold("GLOBAL OPTIONS")+`
     --`+ul("bar")+`=quantity
             (default: 3)
+            (env: FOO_BAR)
             the quantity of bars you want
 
     --`+ul("baz")+`=string
+            (env: FOO_BAZ)
             [SENSITIVE] do you want baz with that?
 
     --`+ul("blegga")+`=fondue
             (default: 0.1)
+            (env: FOO_BLEGGA)
             on the spectrum of fondue, where do you fall?
 
     --`+ul("fnord")+`=fjord
+            (env: FOO_FNORD)
             rhymes with fjord
 
     --`+ul("foo")+`   (default: false)
+            (env: FOO_FOO)
             [REQUIRED] do the foo
 
     --`+ul("qux")+`=ducks
             (default: "\t\n")
+            (env: FOO_QUX)
             rhymes with ducks
 
     Global options can also be configured via upper-case, underscore-delimited
@@ -115,7 +142,7 @@ Run "foo help <command>" for more details on a specific command.
 `)
 
 	buf = new(bytes.Buffer)
-	usage = newUsage(subCmdApp, buf, 24, true)
+	usage = newUsage(subCmdApp, buf, 24, style.Always)
 	usage.Global(cmds, testFlagsFromEnv(subCmdApp.Name))
 
 	assert.Equal(t, buf.String(), bold("NAME")+`
@@ -150,12 +177,16 @@ Run "foo help <command>" for more details on a specific command.
     --`+ul("bar")+`=quantity
             (default:
             3)
+            (env:
+            FOO_BAR)
             the
             quantity
             of bars
             you want
 
     --`+ul("baz")+`=string
+            (env:
+            FOO_BAZ)
             [SENSITIVE]
             do you
             want baz
@@ -165,6 +196,8 @@ Run "foo help <command>" for more details on a specific command.
     --`+ul("blegga")+`=fondue
             (default:
             0.1)
+            (env:
+            FOO_BLEGGA)
             on the
             spectrum
             of
@@ -174,12 +207,16 @@ Run "foo help <command>" for more details on a specific command.
             fall?
 
     --`+ul("fnord")+`=fjord
+            (env:
+            FOO_FNORD)
             rhymes
             with
             fjord
 
     --`+ul("foo")+`   (default:
             false)
+            (env:
+            FOO_FOO)
             [REQUIRED]
             do the
             foo
@@ -187,6 +224,8 @@ Run "foo help <command>" for more details on a specific command.
     --`+ul("qux")+`=ducks
             (default:
             "\t\n")
+            (env:
+            FOO_QUX)
             rhymes
             with
             ducks
@@ -248,7 +287,7 @@ and pay special attention to this pseudo-code:
 	}
 
 	buf := new(bytes.Buffer)
-	usage := newUsage(subCmdApp, buf, 84, true)
+	usage := newUsage(subCmdApp, buf, 84, style.Always)
 	usage.Command(
 		cmd,
 		testFlagsFromEnv(subCmdApp.Name),
@@ -277,23 +316,29 @@ and pay special attention to this pseudo-code:
 `+bold("GLOBAL OPTIONS")+`
     --`+ul("bar")+`=quantity
             (default: 3)
+            (env: FOO_BAR)
             the quantity of bars you want
 
     --`+ul("baz")+`=string
+            (env: FOO_BAZ)
             [SENSITIVE] do you want baz with that?
 
     --`+ul("blegga")+`=fondue
             (default: 0.1)
+            (env: FOO_BLEGGA)
             on the spectrum of fondue, where do you fall?
 
     --`+ul("fnord")+`=fjord
+            (env: FOO_FNORD)
             rhymes with fjord
 
     --`+ul("foo")+`   (default: false)
+            (env: FOO_FOO)
             [REQUIRED] do the foo
 
     --`+ul("qux")+`=ducks
             (default: "\t\n")
+            (env: FOO_QUX)
             rhymes with ducks
 
     Global options can also be configured via upper-case, underscore-delimited
@@ -309,23 +354,29 @@ and pay special attention to this pseudo-code:
 `+bold("OPTIONS")+`
     --`+ul("bar")+`=quantity
             (default: 3)
+            (env: FOO_FOO_BAR)
             the quantity of bars you want
 
     --`+ul("baz")+`=string
+            (env: FOO_FOO_BAZ)
             [SENSITIVE] do you want baz with that?
 
     --`+ul("blegga")+`=fondue
             (default: 0.1)
+            (env: FOO_FOO_BLEGGA)
             on the spectrum of fondue, where do you fall?
 
     --`+ul("fnord")+`=fjord
+            (env: FOO_FOO_FNORD)
             rhymes with fjord
 
     --`+ul("foo")+`   (default: false)
+            (env: FOO_FOO_FOO)
             [REQUIRED] do the foo
 
     --`+ul("qux")+`=ducks
             (default: "\t\n")
+            (env: FOO_FOO_QUX)
             rhymes with ducks
 
     Options can also be configured via upper-case, underscore-delimited
@@ -341,7 +392,7 @@ and pay special attention to this pseudo-code:
 `)
 
 	buf = new(bytes.Buffer)
-	usage = newUsage(singleCmdApp, buf, 84, true)
+	usage = newUsage(singleCmdApp, buf, 84, style.Always)
 	usage.Command(
 		cmd,
 		testFlagsFromEnv(singleCmdApp.Name),
@@ -370,23 +421,29 @@ and pay special attention to this pseudo-code:
 `+bold("OPTIONS")+`
     --`+ul("bar")+`=quantity
             (default: 3)
+            (env: BAR_BAR)
             the quantity of bars you want
 
     --`+ul("baz")+`=string
+            (env: BAR_BAZ)
             [SENSITIVE] do you want baz with that?
 
     --`+ul("blegga")+`=fondue
             (default: 0.1)
+            (env: BAR_BLEGGA)
             on the spectrum of fondue, where do you fall?
 
     --`+ul("fnord")+`=fjord
+            (env: BAR_FNORD)
             rhymes with fjord
 
     --`+ul("foo")+`   (default: false)
+            (env: BAR_FOO)
             [REQUIRED] do the foo
 
     --`+ul("qux")+`=ducks
             (default: "\t\n")
+            (env: BAR_QUX)
             rhymes with ducks
 
     Options can also be configured via upper-case, underscore-delimited
@@ -402,7 +459,7 @@ and pay special attention to this pseudo-code:
 `)
 
 	buf = new(bytes.Buffer)
-	usage = newUsage(singleCmdApp, buf, 24, true)
+	usage = newUsage(singleCmdApp, buf, 24, style.Always)
 	usage.Command(
 		cmd,
 		testFlagsFromEnv(singleCmdApp.Name),
@@ -441,12 +498,16 @@ and pay special attention to this pseudo-code:
     --`+ul("bar")+`=quantity
             (default:
             3)
+            (env:
+            BAR_BAR)
             the
             quantity
             of bars
             you want
 
     --`+ul("baz")+`=string
+            (env:
+            BAR_BAZ)
             [SENSITIVE]
             do you
             want baz
@@ -456,6 +517,8 @@ and pay special attention to this pseudo-code:
     --`+ul("blegga")+`=fondue
             (default:
             0.1)
+            (env:
+            BAR_BLEGGA)
             on the
             spectrum
             of
@@ -465,12 +528,16 @@ and pay special attention to this pseudo-code:
             fall?
 
     --`+ul("fnord")+`=fjord
+            (env:
+            BAR_FNORD)
             rhymes
             with
             fjord
 
     --`+ul("foo")+`   (default:
             false)
+            (env:
+            BAR_FOO)
             [REQUIRED]
             do the
             foo
@@ -478,6 +545,8 @@ and pay special attention to this pseudo-code:
     --`+ul("qux")+`=ducks
             (default:
             "\t\n")
+            (env:
+            BAR_QUX)
             rhymes
             with
             ducks
@@ -518,7 +587,7 @@ func TestUsageGlobalChoiceAndStrings(t *testing.T) {
 	}
 
 	buf := new(bytes.Buffer)
-	usage := newUsage(subCmdApp, buf, 84, true)
+	usage := newUsage(subCmdApp, buf, 84, style.Always)
 
 	flags := &flag.FlagSet{}
 	choice := tbnflag.NewChoice("this-value", "that-value", "another-value")
@@ -586,27 +655,33 @@ func TestUsageGlobalChoiceAndStrings(t *testing.T) {
 `+bold("GLOBAL OPTIONS")+`
     --`+ul("choice")+`=flag
             (valid values: "this-value", "that-value", or "another-value")
+            (env: FOO_CHOICE)
             Pick any one flag.
 
     --`+ul("choice-defaulted")+`=flag
             (default: "this-value")
             (valid values: "this-value", "that-value", or "another-value")
+            (env: FOO_CHOICE_DEFAULTED)
             Pick any one flag.
 
     --`+ul("strings")+`=anything
+            (env: FOO_STRINGS)
             Set as many of anything as you like.
 
     --`+ul("strings-constrained")+`=things
             (valid values: "one-value", "two-value", or "three-value-ha-ha-ha")
+            (env: FOO_STRINGS_CONSTRAINED)
             Set as many of the things as you like.
 
     --`+ul("strings-constrained-and-defaulted")+`=things
             (default: "one-value")
             (valid values: "one-value", "two-value", or "three-value-ha-ha-ha")
+            (env: FOO_STRINGS_CONSTRAINED_AND_DEFAULTED)
             Set as many of the things as you like.
 
     --`+ul("strings-defaulted")+`=anything
             (default: "abc,def")
+            (env: FOO_STRINGS_DEFAULTED)
             Set as many of anything as you like.
 
     Global options can also be configured via upper-case, underscore-delimited
@@ -618,3 +693,547 @@ Run "foo help <command>" for more details on a specific command.
 
 `)
 }
+
+func TestUsageCustomTemplates(t *testing.T) {
+	cmds := []*command.Cmd{
+		{Name: "foo", Summary: "foo the thing"},
+		{Name: "baz", Summary: "baz the thing"},
+	}
+
+	a := subCmdApp
+	a.UsageTemplates = UsageTemplates{
+		Global: `{{bold (upper .Executable)}}{{range .Commands}} {{.Name}}{{end}}
+{{bold "SEE ALSO"}}
+{{indent 4 "foo-bar(1)\n  foo-baz(1)"}}`,
+		Command: `{{ul .Cmd.Name}} - {{lower "EXAMPLES"}}: {{join ", " .Cmd.Flags.Args}}
+{{faint .Version}}
+`,
+	}
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(a, buf, 84, style.Always)
+	usage.Global(cmds, testFlagsFromEnv(a.Name))
+
+	assert.Equal(t, buf.String(), bold("FOO")+` foo baz
+`+bold("SEE ALSO")+`
+    foo-bar(1)
+      foo-baz(1)
+`)
+
+	buf.Reset()
+	usage.Command(cmds[0], testFlagsFromEnv(a.Name), testFlagsFromEnv(a.Name, "foo"))
+	assert.Equal(t, buf.String(), ul("foo")+" - examples: \n"+testStyle.Faint("1.0")+"\n")
+}
+
+func TestUsageTemplateErrors(t *testing.T) {
+	cmds := []*command.Cmd{{Name: "foo", Summary: "foo the thing"}}
+
+	a := subCmdApp
+	a.UsageTemplates.Global = `{{end}}`
+	assert.ErrorContains(t, a.ValidateUsageTemplates(), "unexpected {{end}}")
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(a, buf, 84, style.Never)
+	assert.ErrorContains(t, usage.Global(cmds, testFlagsFromEnv(a.Name)), "unexpected {{end}}")
+	assert.ErrorContains(
+		t,
+		usage.Command(cmds[0], testFlagsFromEnv(a.Name), testFlagsFromEnv(a.Name, "foo")),
+		"unexpected {{end}}",
+	)
+	assert.Equal(t, buf.String(), "")
+
+	a = subCmdApp
+	a.UsageTemplates.Command = `{{.Cmd.Name}} {{.Cmd.Nope}}`
+	assert.Nil(t, a.ValidateUsageTemplates())
+	usage = newUsage(a, buf, 84, style.Never)
+	assert.ErrorContains(
+		t,
+		usage.Command(cmds[0], testFlagsFromEnv(a.Name), testFlagsFromEnv(a.Name, "foo")),
+		"can't evaluate field Nope",
+	)
+}
+
+func TestValidateUsage(t *testing.T) {
+	cmds := []*command.Cmd{
+		{Name: "foo", Summary: "foo the thing"},
+		{Name: "baz", Summary: "baz the thing"},
+	}
+	globalFlags := testFlagsFromEnv(subCmdApp.Name)
+	cmdFlags := func(cmd *command.Cmd) tbnflag.FromEnv {
+		return testFlagsFromEnv(subCmdApp.Name, cmd.Name)
+	}
+
+	assert.Nil(t, subCmdApp.ValidateUsage(cmds, globalFlags, cmdFlags))
+
+	a := subCmdApp
+	a.UsageTemplates.Global = `{{bold "NAME"`
+	err := a.ValidateUsage(cmds, globalFlags, cmdFlags)
+	assert.ErrorContains(t, err, "error(s) generating help text:")
+	assert.ErrorContains(t, err, "global_usage")
+
+	a = subCmdApp
+	a.UsageTemplates.Command = `{{.Cmd.Nope}}`
+	err = a.ValidateUsage(cmds, globalFlags, cmdFlags)
+	assert.ErrorContains(t, err, "can't evaluate field Nope")
+	assert.Equal(t, strings.Count(err.Error(), "can't evaluate field Nope"), 2)
+
+	a = subCmdApp
+	a.UsageTemplates.Command = `{{clean 4 .Cmd.Summary}}`
+	cmds[1].Summary = "{{nope}}"
+	err = a.ValidateUsage(cmds, globalFlags, cmdFlags)
+	assert.ErrorContains(t, err, `function "nope" not defined`)
+}
+
+func TestUsageCommandExamples(t *testing.T) {
+	cmd := &command.Cmd{
+		Name:        "foo",
+		Summary:     "foo the thing",
+		Description: "foo the thing thoroughly",
+		Usage:       "[OPTIONS] <thing>...",
+		Examples: []command.Example{
+			{
+				CommandLine: `bar --quantity=3 "some thing"  'another   thing'`,
+				Explanation: "Foo two things, three times,\nwithout wrapping the command line.",
+			},
+			{
+				CommandLine: "bar thing \\\n    other-thing",
+			},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(singleCmdApp, buf, 84, style.Always)
+	usage.Command(cmd, nil, tbnflag.NewFromEnv(&cmd.Flags, singleCmdApp.Name))
+
+	assert.Equal(t, buf.String(), bold("NAME")+`
+    bar - foo the thing
+
+`+bold("USAGE")+`
+    bar [OPTIONS] <thing>...
+
+`+bold("VERSION")+`
+    1.1
+
+`+bold("DESCRIPTION")+`
+    foo the thing thoroughly
+
+`+bold("OPTIONS")+`
+    Options can also be configured via upper-case, underscore-delimited
+    environment variables prefixed with "BAR_". For example, "--some-flag"
+    becomes "BAR_SOME_FLAG". Command-line flags take precedence over environment
+    variables.
+
+`+bold("EXAMPLES")+`
+    Foo two things, three times, without wrapping the command line.
+
+        bar --quantity=3 "some thing"  'another   thing'
+
+        bar thing \
+            other-thing
+
+`)
+}
+
+func TestUsageGlobalCommandGroups(t *testing.T) {
+	cmds := []*command.Cmd{
+		{Name: "foo", Summary: "foo the thing"},
+		{Name: "bar", Summary: "bar the thing", Category: "Bar Management"},
+		{Name: "baz", Summary: "baz the thing", Hidden: true},
+		{Name: "qux", Summary: "qux the thing", Deprecated: `use "foo" instead`},
+		{Name: "fnord", Summary: "fnord the thing", Category: "Bar Management"},
+	}
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(subCmdApp, buf, 84, style.Always)
+	usage.Global(cmds, testFlagsFromEnv(subCmdApp.Name))
+
+	out := buf.String()
+	start := strings.Index(out, bold("COMMANDS"))
+	end := strings.Index(out, bold("GLOBAL OPTIONS"))
+	assert.True(t, start >= 0)
+	assert.True(t, end > start)
+
+	assert.Equal(t, out[start:end], bold("COMMANDS")+`
+    `+ul("foo")+`     foo the thing
+
+    `+ul("qux")+`     [DEPRECATED] qux the thing
+
+`+bold("BAR MANAGEMENT")+`
+    `+ul("bar")+`     bar the thing
+
+    `+ul("fnord")+`   fnord the thing
+
+`)
+}
+
+func TestUsageGlobalCommandGroupsAllCategorized(t *testing.T) {
+	cmds := []*command.Cmd{
+		{Name: "foo", Summary: "foo the thing", Category: "Foo"},
+		{Name: "bar", Summary: "bar the thing", Hidden: true},
+	}
+
+	visible, groups := groupCommands(cmds)
+	assert.DeepEqual(t, visible, cmds[0:1])
+	assert.DeepEqual(t, groups, []CommandGroup{{Heading: "FOO", Commands: cmds[0:1]}})
+}
+
+func TestUsageCommandDeprecated(t *testing.T) {
+	cmd := &command.Cmd{
+		Name:        "foo",
+		Summary:     "foo the thing",
+		Description: "foo the thing thoroughly",
+		Usage:       "[OPTIONS]",
+		Deprecated:  `use "bar" instead`,
+	}
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(subCmdApp, buf, 84, style.Always)
+	usage.Command(cmd, testFlagsFromEnv(subCmdApp.Name), tbnflag.NewFromEnv(&cmd.Flags, subCmdApp.Name, cmd.Name))
+
+	assert.True(t, strings.Contains(buf.String(), bold("DEPRECATED")+`
+    use "bar" instead
+
+`+bold("DESCRIPTION")+`
+    foo the thing thoroughly
+`))
+}
+
+func TestUsageExitCodes(t *testing.T) {
+	a := subCmdApp
+	a.ExitCodes = []command.ExitCode{
+		{Code: 4, Description: "the thing could not be found"},
+		{Code: 3, Description: "the thing was already foo'd"},
+	}
+
+	cmds := []*command.Cmd{{Name: "foo", Summary: "foo the thing", Description: "foo it"}}
+
+	wantExitStatus := bold("EXIT STATUS") + `
+    0       Success.
+
+    1       An error occurred.
+
+    2       The command line or environment contained invalid input.
+
+    3       the thing was already foo'd
+
+    4       the thing could not be found
+
+`
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(a, buf, 84, style.Always)
+	usage.Global(cmds, testFlagsFromEnv(a.Name))
+
+	out := buf.String()
+	start := strings.Index(out, bold("EXIT STATUS"))
+	assert.True(t, start >= 0)
+	assert.Equal(t, out[start:], wantExitStatus+
+		`Run "foo help <command>" for more details on a specific command.
+
+`)
+
+	buf = new(bytes.Buffer)
+	usage = newUsage(a, buf, 84, style.Always)
+	usage.Command(cmds[0], testFlagsFromEnv(a.Name), tbnflag.NewFromEnv(&cmds[0].Flags, a.Name, "foo"))
+
+	out = buf.String()
+	start = strings.Index(out, bold("EXIT STATUS"))
+	assert.True(t, start >= 0)
+	assert.Equal(t, out[start:], wantExitStatus)
+
+	buf = new(bytes.Buffer)
+	usage = newUsage(subCmdApp, buf, 84, style.Always)
+	usage.Command(cmds[0], testFlagsFromEnv(a.Name), tbnflag.NewFromEnv(&cmds[0].Flags, a.Name, "foo"))
+	assert.False(t, strings.Contains(buf.String(), "EXIT STATUS"))
+}
+
+func TestUsageGlobalPlugins(t *testing.T) {
+	a := subCmdApp
+	a.Plugins = []Plugin{
+		{Name: "bar", Path: "/usr/local/bin/foo-bar"},
+		{Name: "baz", Path: "/usr/bin/foo-baz"},
+	}
+
+	cmds := []*command.Cmd{{Name: "foo", Summary: "foo the thing", Description: "foo it"}}
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(a, buf, 84, style.Always)
+	usage.Global(cmds, testFlagsFromEnv(a.Name))
+
+	out := buf.String()
+	start := strings.Index(out, bold("PLUGINS"))
+	end := strings.Index(out, bold("GLOBAL OPTIONS"))
+	assert.True(t, start >= 0)
+	assert.True(t, end > start)
+	assert.Equal(t, out[start:end], bold("PLUGINS")+`
+    `+ul("bar")+`     /usr/local/bin/foo-bar
+
+    `+ul("baz")+`     /usr/bin/foo-baz
+
+`)
+
+	buf = new(bytes.Buffer)
+	usage = newUsage(subCmdApp, buf, 84, style.Always)
+	usage.Global(cmds, testFlagsFromEnv(a.Name))
+	assert.False(t, strings.Contains(buf.String(), "PLUGINS"))
+}
+
+func TestUsageGlobalAliases(t *testing.T) {
+	a := subCmdApp
+	a.Aliases = []Alias{{Name: "fa", Command: "foo --all"}}
+	a.Plugins = []Plugin{{Name: "bar", Path: "/usr/local/bin/foo-bar"}}
+
+	cmds := []*command.Cmd{{Name: "foo", Summary: "foo the thing", Description: "foo it"}}
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(a, buf, 84, style.Always)
+	usage.Global(cmds, testFlagsFromEnv(a.Name))
+
+	out := buf.String()
+	start := strings.Index(out, bold("ALIASES"))
+	end := strings.Index(out, bold("PLUGINS"))
+	assert.True(t, start >= 0)
+	assert.True(t, end > start)
+	assert.Equal(t, out[start:end], bold("ALIASES")+`
+    `+ul("fa")+`      foo --all
+
+`)
+
+	buf = new(bytes.Buffer)
+	usage = newUsage(subCmdApp, buf, 84, style.Always)
+	usage.Global(cmds, testFlagsFromEnv(a.Name))
+	assert.False(t, strings.Contains(buf.String(), "ALIASES"))
+}
+
+func TestUsageRepeatableFlags(t *testing.T) {
+	hosts := []string{"a", "b"}
+	var ports []int
+	var labels map[string]string
+	timeouts := map[string]time.Duration{"read": time.Second}
+
+	flags := &flag.FlagSet{}
+	command.SliceVar(flags, &hosts, "host", "A host.")
+	command.SliceVar(flags, &ports, "port", "A `number`.")
+	command.MapVar(flags, &labels, "label", "A label.")
+	command.MapVar(flags, &timeouts, "timeout", "A timeout.")
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(subCmdApp, buf, 84, style.Always)
+	usage.Global(nil, tbnflag.NewFromEnv(flags, subCmdApp.Name))
+
+	out := buf.String()
+	start := strings.Index(out, bold("GLOBAL OPTIONS"))
+	end := strings.Index(out, "    Global options can")
+	assert.True(t, start >= 0)
+	assert.True(t, end > start)
+	assert.Equal(t, out[start:end], bold("GLOBAL OPTIONS")+`
+    --`+ul("host")+`=string
+            (default: "a,b")
+            (may be repeated)
+            (env: FOO_HOST)
+            A host.
+
+    --`+ul("label")+`=key=string
+            (may be repeated)
+            (env: FOO_LABEL)
+            A label.
+
+    --`+ul("port")+`=number
+            (may be repeated)
+            (env: FOO_PORT)
+            A number.
+
+    --`+ul("timeout")+`=key=duration
+            (default: read=1s)
+            (may be repeated)
+            (env: FOO_TIMEOUT)
+            A timeout.
+
+`)
+}
+
+func TestUsageFlagValidators(t *testing.T) {
+	flags := &flag.FlagSet{}
+	flags.Int("port", 80, "The `port`.")
+	flags.String("name", "", "The name.")
+	command.SetFlagOptions(flags, "port", command.WithValidators(command.Range(1, 65535)))
+	command.SetFlagOptions(flags, "name", command.WithValidators(command.Regexp(`^[a-z]{{1,8}}$`)))
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(subCmdApp, buf, 84, style.Always)
+	usage.Global(nil, tbnflag.NewFromEnv(flags, subCmdApp.Name))
+
+	out := buf.String()
+	start := strings.Index(out, bold("GLOBAL OPTIONS"))
+	end := strings.Index(out, "    Global options can")
+	assert.True(t, start >= 0)
+	assert.True(t, end > start)
+	assert.Equal(t, out[start:end], bold("GLOBAL OPTIONS")+`
+    --`+ul("name")+`=string
+            (must match ^[a-z]{{1,8}}$)
+            (env: FOO_NAME)
+            The name.
+
+    --`+ul("port")+`=port
+            (default: 80)
+            (must be between 1 and 65535)
+            (env: FOO_PORT)
+            The port.
+
+`)
+}
+
+func TestUsageFlagEnvKeys(t *testing.T) {
+	flags := &flag.FlagSet{}
+	flags.String("name", "", "The name.")
+	flags.String("password", "", "The password.")
+	flags.Bool("dry-run", false, "Do nothing.")
+	command.SetFlagOptions(flags, "password", command.WithEnvKey("DB_PASSWORD"))
+	command.SetFlagOptions(flags, "dry-run", command.WithoutEnv())
+
+	buf := new(bytes.Buffer)
+	usage := newUsage(subCmdApp, buf, 84, style.Always)
+	usage.Global(nil, tbnflag.NewFromEnv(flags, subCmdApp.Name))
+
+	out := buf.String()
+	start := strings.Index(out, bold("GLOBAL OPTIONS"))
+	end := strings.Index(out, "    Global options can")
+	assert.True(t, start >= 0)
+	assert.True(t, end > start)
+	assert.Equal(t, out[start:end], bold("GLOBAL OPTIONS")+`
+    --`+ul("dry-run")+`
+            (default: false)
+            Do nothing.
+
+    --`+ul("name")+`=string
+            (env: FOO_NAME)
+            The name.
+
+    --`+ul("password")+`=string
+            (env: DB_PASSWORD)
+            The password.
+
+`)
+}
+
+func testCategorizedFlags() *flag.FlagSet {
+	flags := &flag.FlagSet{}
+	flags.String("name", "", "The name.")
+	flags.String("url", "", "The URL.")
+	flags.Bool("json", false, "Output JSON.")
+	flags.Int("retries", 3, "The retries.")
+	flags.String("trace", "", "The trace file.")
+	flags.String("secret", "", "Internal.")
+	command.SetFlagOptions(flags, "url", command.WithCategory("Connection"))
+	command.SetFlagOptions(flags, "json", command.WithCategory("Output"))
+	command.SetFlagOptions(flags, "retries", command.WithCategory("Connection"), command.WithAdvanced())
+	command.SetFlagOptions(flags, "trace", command.WithAdvanced())
+	command.SetFlagOptions(flags, "secret", command.WithHidden())
+	return flags
+}
+
+func TestUsageCategorizedFlags(t *testing.T) {
+	cmd := &command.Cmd{Name: "foo", Summary: "foo the thing", Description: "foo it"}
+	flags := testCategorizedFlags()
+
+	for _, tc := range []struct {
+		short bool
+		want  string
+	}{
+		{
+			want: bold("OPTIONS") + `
+    --` + ul("name") + `=string
+            (env: BAR_NAME)
+            The name.
+
+` + bold("CONNECTION OPTIONS") + `
+    --` + ul("retries") + `=int
+            (default: 3)
+            (env: BAR_RETRIES)
+            The retries.
+
+    --` + ul("url") + `=string
+            (env: BAR_URL)
+            The URL.
+
+` + bold("OUTPUT OPTIONS") + `
+    --` + ul("json") + `  (default: false)
+            (env: BAR_JSON)
+            Output JSON.
+
+` + bold("ADVANCED OPTIONS") + `
+    --` + ul("trace") + `=string
+            (env: BAR_TRACE)
+            The trace file.
+
+`,
+		},
+		{
+			short: true,
+			want: bold("OPTIONS") + `
+    --` + ul("name") + `=string
+            (env: BAR_NAME)
+            The name.
+
+` + bold("CONNECTION OPTIONS") + `
+    --` + ul("url") + `=string
+            (env: BAR_URL)
+            The URL.
+
+` + bold("OUTPUT OPTIONS") + `
+    --` + ul("json") + `  (default: false)
+            (env: BAR_JSON)
+            Output JSON.
+
+`,
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("short=%t", tc.short),
+			t,
+			func(g *assert.G) {
+				a := singleCmdApp
+				a.ShortUsage = tc.short
+
+				buf := new(bytes.Buffer)
+				usage := newUsage(a, buf, 84, style.Always)
+				usage.Command(cmd, nil, tbnflag.NewFromEnv(flags, a.Name))
+
+				out := buf.String()
+				start := strings.Index(out, bold("OPTIONS"))
+				end := strings.Index(out, "    Options can")
+				assert.True(g, start >= 0)
+				assert.True(g, end > start)
+				assert.Equal(g, out[start:end], tc.want)
+
+				note := "Advanced options are not shown. Run with --help to show all options."
+				assert.Equal(g, strings.Contains(out, note), tc.short)
+				assert.False(g, strings.Contains(out, "secret"))
+			},
+		)
+	}
+}
+
+func TestUsageGlobalCategorizedFlags(t *testing.T) {
+	flags := &flag.FlagSet{}
+	flags.String("url", "", "The URL.")
+	command.SetFlagOptions(flags, "url", command.WithCategory("Connection"))
+
+	a := subCmdApp
+	a.ShortUsage = true
+	buf := new(bytes.Buffer)
+	usage := newUsage(a, buf, 84, style.Always)
+	usage.Global(nil, tbnflag.NewFromEnv(flags, a.Name))
+
+	// without uncategorized flags, there is no GLOBAL OPTIONS heading,
+	// and without advanced flags, no note
+	out := buf.String()
+	assert.False(t, strings.Contains(out, bold("GLOBAL OPTIONS")))
+	assert.True(t, strings.Contains(out, bold("GLOBAL CONNECTION OPTIONS")+`
+    --`+ul("url")+`=string
+            (env: FOO_URL)
+            The URL.
+
+    Global options can`))
+	assert.False(t, strings.Contains(out, "Advanced options"))
+}
@@ -17,25 +17,31 @@ limitations under the License.
 package cli
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/turbinelabs/cli/app"
 	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/shellwords"
+	"github.com/turbinelabs/cli/style"
 	tbnflag "github.com/turbinelabs/nonstdlib/flag"
 	"github.com/turbinelabs/nonstdlib/flag/usage"
-	"github.com/turbinelabs/nonstdlib/log/console"
 	tbnos "github.com/turbinelabs/nonstdlib/os"
 )
 
 const HelpSummary = "Show a list of commands or help for one command"
 const VersionSummary = "Print the version and exit"
+const ColorSummary = "Control the use of color and other styling in output: `mode` is auto, always, or never"
+const ErrorFormatSummary = "Control the format of error output: `format` is text, or json for a single JSON object written to stderr"
 
 type ValidationFlag int
 
@@ -43,6 +49,17 @@ const (
 	// Skips Validating that global and subcommand help text can
 	// be generated.
 	ValidateSkipHelpText ValidationFlag = iota
+
+	// Validates that the command line of each command.Example parses
+	// without producing a BadInput error: flags must be defined, required
+	// flags must be set, and values must pass their Validators. Flag
+	// values are parsed into the same flag.Values as the command line, so
+	// this is intended for use in unit tests only.
+	ValidateExamples
+
+	// Validates that no alias in the config file shadows a command,
+	// which would prevent the command from being run. See EnableAliases.
+	ValidateAliases
 )
 
 // A CLI represents a command-line application
@@ -52,11 +69,125 @@ type CLI interface {
 	// Set the flags
 	SetFlags(*flag.FlagSet)
 
+	// SetUsageTemplates replaces the text/templates used to render global
+	// and command usage. See app.UsageTemplates for the functions available
+	// to templates. Validate will verify that custom templates render for
+	// every command.
+	SetUsageTemplates(app.UsageTemplates)
+
+	// RegisterExitCode describes an application-defined exit code, which
+	// Runners may return via command.CmdErr.WithCode. Registered exit codes
+	// are listed, along with the default exit codes, in an EXIT STATUS
+	// section of usage. Validate will verify that registered exit codes
+	// are unique, and neither reserved nor out of range.
+	RegisterExitCode(code command.CmdErrCode, description string)
+
+	// EnableShell adds a shell command, which reads command lines
+	// interactively and runs each in turn without exiting, so that
+	// start-up costs are paid once. Only CLIs with sub-commands support
+	// the shell; Validate will report an error otherwise.
+	EnableShell(ShellOptions)
+
+	// EnablePlugins causes unknown commands to be run as plugins: "<app>
+	// foo" executes "<app>-foo", found on the PATH, with the remaining
+	// arguments. Global flags which have been set are exported to plugins
+	// as environment variables, named as when setting flags from the
+	// environment. Plugins report their own errors, and their exit status
+	// is returned unchanged. Discovered plugins are listed in global usage.
+	// Only CLIs with sub-commands support plugins; Validate will report an
+	// error otherwise.
+	EnablePlugins()
+
+	// EnableMultiCall supports installing a single binary under several
+	// names, busybox-style. When Main is invoked under the name of a
+	// command, it runs that command, as though invoked as "<mainName>
+	// <command>"; otherwise, it dispatches to sub-commands as usual. The
+	// CLI is named mainName regardless of how it was invoked, so
+	// environment variables, usage and version refer to mainName. Only
+	// CLIs with sub-commands support multi-call dispatch; Validate will
+	// report an error otherwise.
+	EnableMultiCall(mainName string)
+
+	// EnableResponseFiles causes arguments to be read from files, for
+	// commands which take more arguments than fit on a command line. An
+	// @file argument is replaced by the arguments in the file, separated by
+	// whitespace or newlines and quoted using shell quoting rules, and a -
+	// argument by the arguments read from stdin. A literal argument
+	// beginning with @ may be given by doubling the @. Arguments following
+	// --, and arguments read from files, are not expanded.
+	EnableResponseFiles()
+
+	// EnableFlagFiles allows flag values to be read from files, so that
+	// secrets need not appear in process lists or shell history. A flag
+	// given as --name=@path takes the contents of the file at path, as does
+	// a flag whose environment variable is given with FileEnvSuffix: for
+	// example, APP_API_KEY_FILE=path. A single trailing newline is removed.
+	// A value beginning with a literal @ may be given by doubling the @.
+	// Values read from files for sensitive flags are redacted in usage.
+	EnableFlagFiles()
+
+	// EnableEnvFile adds an env-file flag naming a dotenv file, whose
+	// variables are added to the environment before flags are filled from
+	// it. Variables already in the environment take precedence. The file
+	// may also be named by the corresponding environment variable (for
+	// example, APP_ENV_FILE); otherwise, the file at defaultPath is loaded,
+	// if it exists. Flags configured from the file are noted in usage. See
+	// the dotenv package for the file format.
+	EnableEnvFile(defaultPath string)
+
+	// SetConfigFile sets the path of the per-user config file, in which
+	// profiles and aliases are saved. By default, config.DefaultPath is
+	// used, in the environment given to Run.
+	SetConfigFile(path string)
+
+	// EnableProfiles adds a profile flag, and a profile command to manage
+	// the named sets of flag values, or profiles, saved in the config file.
+	// The flag values of the profile named by the profile flag or its
+	// environment variable, or otherwise last selected with "profile use",
+	// are applied to flags not set on the command line or from the
+	// environment. Only CLIs with sub-commands support profiles; Validate
+	// will report an error otherwise.
+	EnableProfiles()
+
+	// EnableShowConfig adds a show-config flag which, rather than running
+	// the command, prints each global flag and flag of the command with its
+	// effective value, the source of that value (default, flag, env,
+	// env-file, file, or profile), and the environment variable which would
+	// set it. Values of sensitive flags are redacted. With
+	// --show-config=json, the configuration is printed as a JSON object.
+	EnableShowConfig()
+
+	// EnableAliases allows users to define shortcuts for commands and
+	// their arguments in the alias section of the config file, as in:
+	//
+	//     [alias]
+	//     deploy-prod = deploy --env=prod --confirm
+	//
+	// so that "<app> deploy-prod --wait" runs "<app> deploy --env=prod
+	// --confirm --wait". Aliases may be defined in terms of other aliases,
+	// but not recursively. An alias which shadows a command is reported as
+	// an error when that command is run, as a warning in global usage, and
+	// by Validate with ValidateAliases. Aliases are listed in global usage.
+	// Only CLIs with sub-commands support aliases; Validate will report an
+	// error otherwise.
+	EnableAliases()
+
 	// Main serves as the main() function for the CLI. It will parse
 	// the command-line arguments and flags, call the appropriate sub-command,
 	// and return exit status and output error messages as appropriate.
 	Main()
 
+	// Run parses the given command-line arguments, which do not include
+	// the executable name, and flags, calls the appropriate sub-command,
+	// and outputs usage and error messages as appropriate, in the same
+	// manner as Main. Rather than exiting, it returns the resulting
+	// command.CmdErr. Flags are filled from the given environment, and
+	// output is written to the given streams, in place of those of the
+	// process; nil streams are treated as empty or discarded. If env is
+	// nil, the process environment is used. The context is made
+	// available to the Runner via command.Cmd.Context.
+	Run(ctx context.Context, args []string, env map[string]string, stdio command.Stdio) command.CmdErr
+
 	// Validate can be used to make sure the CLI is well-defined from within
 	// unit tests. In particular it will validate that no two flags exist with
 	// the same environment key. As a last-ditch effort, Validate will be called
@@ -74,10 +205,61 @@ type cli struct {
 	commands    []*command.Cmd
 	name        string
 	app         app.App
-	usage       app.Usage
+	newUsage    func(io.Writer, style.Mode) app.Usage
 	version     app.Version
 	versionFlag bool
 	helpFlag    bool
+	colorMode   style.Mode
+	errFormat   ErrorFormat
+
+	// bound to the help and version flags of each command
+	cmdHelpFlag    bool
+	cmdVersionFlag bool
+
+	// set when help is requested with -h, for brief usage, or with --help,
+	// for full usage, which takes precedence
+	briefHelp bool
+	fullHelp  bool
+
+	// if non-nil, used to construct fresh commands for each run
+	factories []CmdFactory
+	// true once the CLI has been run, and must be reset before running again
+	dirty bool
+
+	shellEnabled   bool
+	shellOptions   ShellOptions
+	shellCmd       *command.Cmd
+	shellRequested bool     // set by the shell command's Runner
+	inShell        bool     // true while the shell is running
+	globalArgs     []string // the global flags preceding the command
+
+	pluginsEnabled bool
+	multiCall      bool
+	responseFiles  bool
+	flagFiles      bool
+	envFileEnabled bool
+	envFileDefault string
+	envFile        string            // bound to the env-file flag
+	envFileKeys    map[string]string // the keys loaded from the env file, and its path
+	envFileErr     error             // set if the env file could not be loaded
+
+	configFile      string
+	profilesEnabled bool
+	profileCmd      *command.Cmd
+	profile         string // bound to the profile flag
+
+	// the profile from which each flag was set
+	profileFlags map[*flag.Flag]string
+
+	showConfigEnabled bool
+	showConfigFormat  showConfigFormat // bound to the show-config flag
+	globalArgFlags    map[string]bool  // the global flags set on the command line
+
+	aliasesEnabled bool
+
+	// set for the duration of Run
+	env   map[string]string
+	stdio command.Stdio
 
 	flagsFromEnv    tbnflag.FromEnv
 	cmdFlagsFromEnv map[string]tbnflag.FromEnv
@@ -85,6 +267,10 @@ type cli struct {
 	os tbnos.OS
 }
 
+// A CmdFactory produces a new command.Cmd, with its own Runner and flag
+// values.
+type CmdFactory func() *command.Cmd
+
 // New produces a CLI for the given command.Cmd
 func New(version string, command *command.Cmd) CLI {
 	app := app.App{
@@ -113,31 +299,102 @@ func NewWithSubCmds(
 	return mkNew(app, append([]*command.Cmd{command1}, commandsN...)...)
 }
 
+// NewFromFactory is like New, but the command.Cmd is produced by the given
+// CmdFactory, which is called again before each run of the CLI after the
+// first. This guarantees that no flag state is shared between runs.
+func NewFromFactory(version string, factory CmdFactory) CLI {
+	c := New(version, factory()).(*cli)
+	c.factories = []CmdFactory{factory}
+	return c
+}
+
+// NewWithSubCmdFactories is like NewWithSubCmds, but the command.Cmds are
+// produced by the given CmdFactories, which are called again before each
+// run of the CLI after the first. This guarantees that no command flag
+// state is shared between runs.
+func NewWithSubCmdFactories(
+	description string,
+	version string,
+	factory1 CmdFactory,
+	factoriesN ...CmdFactory,
+) CLI {
+	factories := append([]CmdFactory{factory1}, factoriesN...)
+	commands := make([]*command.Cmd, len(factories))
+	for i, factory := range factories {
+		commands[i] = factory()
+	}
+
+	c := NewWithSubCmds(description, version, commands[0], commands[1:]...).(*cli)
+	c.factories = factories
+	return c
+}
+
 func mkNew(app app.App, commands ...*command.Cmd) CLI {
 	c := &cli{
 		commands: commands,
 		app:      app,
 		name:     app.Name,
-		usage:    app.Usage(),
 		version:  app.Version(),
 
 		os: tbnos.New(),
 	}
+	c.newUsage = c.styledUsage
+	c.initFlagsFromEnv()
 
-	c.flagsFromEnv = tbnflag.NewFromEnv(&c.flags, app.Name)
+	return c
+}
 
-	if app.HasSubCmds {
-		c.cmdFlagsFromEnv = map[string]tbnflag.FromEnv{}
-		for _, cmd := range commands {
-			c.cmdFlagsFromEnv[cmd.Name] = tbnflag.NewFromEnv(&cmd.Flags, app.Name, cmd.Name)
+func (cli *cli) initFlagsFromEnv() {
+	cli.flagsFromEnv = tbnflag.NewFromEnv(&cli.flags, cli.app.Name)
+
+	if cli.app.HasSubCmds {
+		cli.cmdFlagsFromEnv = map[string]tbnflag.FromEnv{}
+		for _, cmd := range cli.commands {
+			cli.cmdFlagsFromEnv[cmd.Name] = tbnflag.NewFromEnv(&cmd.Flags, cli.app.Name, cmd.Name)
 		}
 	} else {
-		c.cmdFlagsFromEnv = map[string]tbnflag.FromEnv{
-			app.Name: tbnflag.NewFromEnv(&commands[0].Flags, app.Name),
+		cli.cmdFlagsFromEnv = map[string]tbnflag.FromEnv{
+			cli.app.Name: tbnflag.NewFromEnv(&cli.commands[0].Flags, cli.app.Name),
 		}
 	}
+}
 
-	return c
+// reset restores the CLI to its initial state after a run, so that no
+// flag values or other state leak into the next run. Commands produced by
+// CmdFactories are constructed anew; the flags of other commands, and the
+// global flags, are restored to their default values.
+func (cli *cli) reset() {
+	if !cli.dirty {
+		return
+	}
+
+	cli.helpFlag = false
+	cli.versionFlag = false
+	cli.cmdHelpFlag = false
+	cli.cmdVersionFlag = false
+	cli.briefHelp = false
+	cli.fullHelp = false
+	cli.colorMode = style.Auto
+	cli.errFormat = ErrorFormatText
+	cli.shellRequested = false
+	cli.showConfigFormat = showConfigOff
+	cli.profileFlags = nil
+	cli.globalArgFlags = nil
+	cli.globalArgs = nil
+
+	resetFlagSet(&cli.flags)
+	if cli.factories != nil {
+		for i, factory := range cli.factories {
+			cli.commands[i] = factory()
+		}
+	} else {
+		for _, cmd := range cli.commands {
+			resetFlagSet(&cmd.Flags)
+		}
+	}
+
+	cli.initFlagsFromEnv()
+	cli.dirty = false
 }
 
 func validateFlagIsSet(vflags []ValidationFlag, vflag ValidationFlag) bool {
@@ -154,28 +411,39 @@ func (cli *cli) Validate(vflags ...ValidationFlag) error {
 	seen := map[string]string{}
 	collisions := map[string][]string{}
 
+	// keys account for command.WithEnvKey and command.WithoutEnv
+	add := func(prefix string, f *flag.Flag, cmdWithArg string) {
+		envKey, ok := command.FlagEnvKey(prefix, f)
+		if !ok {
+			return
+		}
+		if seen[envKey] != "" {
+			// if we've seen it before, it's a problem
+			if len(collisions[envKey]) == 0 {
+				// add the first seen
+				collisions[envKey] = []string{seen[envKey]}
+			}
+			// add this one
+			collisions[envKey] = append(collisions[envKey], cmdWithArg)
+		} else {
+			// otherwise, mark as seen
+			seen[envKey] = cmdWithArg
+		}
+	}
+
 	// add top-level flags
 	for _, f := range tbnflag.Enumerate(&cli.flags) {
-		seen[tbnflag.EnvKey(cli.name, f.Name)] = fmt.Sprintf("%s -%s", cli.name, f.Name)
+		add(tbnflag.EnvKey(cli.name)+"_", f, fmt.Sprintf("%s -%s", cli.name, f.Name))
 	}
 
 	// add cmd-level flags
 	for _, cmd := range cli.commands {
 		for _, f := range tbnflag.Enumerate(&cmd.Flags) {
-			envKey := tbnflag.EnvKey(cli.name, cmd.Name, f.Name)
-			cmdWithArg := fmt.Sprintf("%s %s -%s", cli.name, cmd.Name, f.Name)
-			if seen[envKey] != "" {
-				// if we've seen it before, it's a problem
-				if len(collisions[envKey]) == 0 {
-					// add the first seen
-					collisions[envKey] = []string{seen[envKey]}
-				}
-				// add this one
-				collisions[envKey] = append(collisions[envKey], cmdWithArg)
-			} else {
-				// otherwise, mark as seen
-				seen[envKey] = cmdWithArg
-			}
+			add(
+				tbnflag.EnvKey(cli.name, cmd.Name)+"_",
+				f,
+				fmt.Sprintf("%s %s -%s", cli.name, cmd.Name, f.Name),
+			)
 		}
 	}
 
@@ -187,45 +455,130 @@ func (cli *cli) Validate(vflags ...ValidationFlag) error {
 		return errors.New(msg)
 	}
 
+	// templates are checked even if help text is not rendered, so that
+	// usage can always be shown
+	if err := cli.app.ValidateUsageTemplates(); err != nil {
+		return fmt.Errorf("invalid usage templates: %s", err)
+	}
+
+	if err := cli.validateExitCodes(); err != nil {
+		return err
+	}
+
+	if err := cli.validateFlagAliases(); err != nil {
+		return err
+	}
+
+	if err := cli.validateShell(); err != nil {
+		return err
+	}
+
+	if err := cli.validateProfiles(); err != nil {
+		return err
+	}
+
+	if err := cli.validateAliases(vflags); err != nil {
+		return err
+	}
+
+	if cli.pluginsEnabled && !cli.app.HasSubCmds {
+		return errors.New("plugins require sub-commands")
+	}
+
+	if cli.multiCall && !cli.app.HasSubCmds {
+		return errors.New("multi-call dispatch requires sub-commands")
+	}
+
 	if !validateFlagIsSet(vflags, ValidateSkipHelpText) {
 		if err := cli.validateHelpText(); err != nil {
 			return err
 		}
 	}
 
+	if validateFlagIsSet(vflags, ValidateExamples) {
+		if err := cli.validateExamples(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (cli *cli) validateHelpText() error {
-	usage := cli.app.RedirectedUsage(bytes.NewBufferString(""))
+func (cli *cli) validateExitCodes() error {
 	errs := []string{}
+	seen := map[command.CmdErrCode]bool{}
+	for _, ec := range command.DefaultExitCodes() {
+		seen[ec.Code] = true
+	}
 
-	try := func(u func()) {
-		defer func() {
-			if e := recover(); e != nil {
-				switch v := e.(type) {
-				case error:
-					errs = append(errs, v.Error())
-				default:
-					errs = append(errs, fmt.Sprintf("%v", e))
-				}
+	for _, ec := range cli.app.ExitCodes {
+		switch {
+		case ec.Code > command.MaxExitCode:
+			errs = append(errs, fmt.Sprintf("%d: greater than %d", ec.Code, command.MaxExitCode))
+		case seen[ec.Code]:
+			errs = append(errs, fmt.Sprintf("%d: already defined", ec.Code))
+		}
+		seen[ec.Code] = true
+	}
+
+	if len(errs) > 0 {
+		msg := "invalid exit code(s):\n  "
+		msg += strings.Join(errs, "\n  ")
+		return errors.New(msg + "\n")
+	}
+
+	return nil
+}
+
+// validateFlagAliases checks that each alias defined with
+// command.AliasFlag sets a flag defined in the same flag.FlagSet, and that
+// the flag is not itself an alias, since aliases are only applied once.
+func (cli *cli) validateFlagAliases() error {
+	errs := []string{}
+	check := func(fs *flag.FlagSet, cmdWithArg string) {
+		for _, f := range tbnflag.Enumerate(fs) {
+			name, ok := command.FlagAliasOf(f)
+			if !ok {
+				continue
+			}
+			target := fs.Lookup(name)
+			if target == nil {
+				errs = append(errs, fmt.Sprintf("%s -%s: alias of undefined flag -%s", cmdWithArg, f.Name, name))
+			} else if next, ok := command.FlagAliasOf(target); ok {
+				errs = append(
+					errs,
+					fmt.Sprintf("%s -%s: alias of -%s, which is an alias of -%s", cmdWithArg, f.Name, name, next),
+				)
 			}
-		}()
+		}
+	}
 
-		u()
+	check(&cli.flags, cli.name)
+	for _, cmd := range cli.commands {
+		check(&cmd.Flags, cli.name+" "+cmd.Name)
 	}
 
-	try(func() {
-		usage.Global(cli.commands, cli.flagsFromEnv)
-	})
+	if len(errs) > 0 {
+		msg := "conflicting flag aliases:\n  "
+		msg += strings.Join(errs, "\n  ")
+		return errors.New(msg + "\n")
+	}
+
+	return nil
+}
+
+func (cli *cli) validateExamples() error {
+	errs := []string{}
 	for _, cmd := range cli.commands {
-		try(func() {
-			usage.Command(cmd, cli.flagsFromEnv, cli.commandFlagsFromEnv(cmd))
-		})
+		for _, example := range cmd.Examples {
+			if err := cli.validateExample(cmd, example.CommandLine); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q: %s", cmd.Name, example.CommandLine, err))
+			}
+		}
 	}
 
 	if len(errs) > 0 {
-		msg := "error(s) generating help text:\n  "
+		msg := "invalid example(s):\n  "
 		msg += strings.Join(errs, "\n  ")
 		return errors.New(msg + "\n")
 	}
@@ -233,31 +586,273 @@ func (cli *cli) validateHelpText() error {
 	return nil
 }
 
+// validateExample parses the given command line, using copies of the
+// global and command FlagSets so that help, version and color flags may be
+// added without disturbing Main.
+func (cli *cli) validateExample(cmd *command.Cmd, commandLine string) error {
+	// the copies share the FlagSets' flag.Values, which must be reset
+	// before the next run
+	cli.dirty = true
+
+	args, err := shellwords.Split(commandLine)
+	if err != nil {
+		return err
+	}
+
+	// the first argument is the executable name, which is not
+	// necessarily cli.name when running tests
+	if len(args) > 0 {
+		args = args[1:]
+	}
+
+	var helpFlag, versionFlag bool
+	var colorMode style.Mode
+	var errFormat ErrorFormat
+	var envFile, profile string
+	var showConfig showConfigFormat
+	missingErrs := []string{}
+
+	if cli.app.HasSubCmds {
+		globalFlags := copyFlagSet(&cli.flags)
+		addVersionFlagIfMissing(globalFlags, &versionFlag)
+		addHelpFlagIfMissing(globalFlags, &helpFlag)
+		addColorFlagIfMissing(globalFlags, &colorMode)
+		addErrorFormatFlagIfMissing(globalFlags, &errFormat)
+		cli.addEnvFileFlagIfMissing(globalFlags, &envFile)
+		cli.addProfileFlagIfMissing(globalFlags, &profile)
+		cli.addShowConfigFlagIfMissing(globalFlags, &showConfig)
+
+		if err := quietParse(globalFlags, args); err != nil {
+			return err
+		}
+		if err := command.ApplyAliases(globalFlags); err != nil {
+			return err
+		}
+		missingErrs = checkRequired(globalFlags, missingErrs, "global ")
+		missingErrs = checkValid(globalFlags, missingErrs, "global ")
+
+		args = globalFlags.Args()
+		if len(args) > 0 && (args[0] == "help" || args[0] == "version") {
+			return nil
+		}
+		if len(args) < 1 || !strings.EqualFold(args[0], cmd.Name) {
+			return fmt.Errorf("does not invoke the %q command", cmd.Name)
+		}
+		args = args[1:]
+	}
+
+	cmdFlags := copyFlagSet(&cmd.Flags)
+	addHelpFlagIfMissing(cmdFlags, &helpFlag)
+	addVersionFlagIfMissing(cmdFlags, &versionFlag)
+	if !cli.app.HasSubCmds {
+		addColorFlagIfMissing(cmdFlags, &colorMode)
+		addErrorFormatFlagIfMissing(cmdFlags, &errFormat)
+		cli.addEnvFileFlagIfMissing(cmdFlags, &envFile)
+	}
+	cli.addShowConfigFlagIfMissing(cmdFlags, &showConfig)
+
+	if err := quietParse(cmdFlags, args); err != nil {
+		return err
+	}
+	if err := command.ApplyAliases(cmdFlags); err != nil {
+		return err
+	}
+
+	if helpFlag || versionFlag {
+		return nil
+	}
+
+	missingErrs = checkRequired(cmdFlags, missingErrs, "")
+	missingErrs = checkValid(cmdFlags, missingErrs, "")
+	if len(missingErrs) > 0 {
+		return errors.New(strings.Join(missingErrs, ", "))
+	}
+
+	return nil
+}
+
+func (cli *cli) validateHelpText() error {
+	return cli.app.ValidateUsage(cli.commands, cli.flagsFromEnv, cli.commandFlagsFromEnv)
+}
+
 func (cli *cli) Version() app.Version {
 	return cli.version
 }
 
 func (cli *cli) Main() {
+	args := cli.os.Args()
+	cmdErr := cli.Run(
+		context.Background(),
+		cli.multiCallArgs(args[0], args[1:]),
+		nil,
+		command.Stdio{
+			Stdin:  cli.os.Stdin(),
+			Stdout: cli.os.Stdout(),
+			Stderr: cli.os.Stderr(),
+		},
+	)
+	cli.os.Exit(int(cmdErr.Code))
+}
+
+func (cli *cli) Run(
+	ctx context.Context,
+	args []string,
+	env map[string]string,
+	stdio command.Stdio,
+) command.CmdErr {
+	cmdErr := cli.run(ctx, args, env, stdio)
+
+	// the shell runs once its command has completed, so that each command
+	// line it reads is run from a clean slate
+	if cli.shellRequested {
+		return cli.runShell(ctx, cli.globalArgs, env, stdio)
+	}
+
+	return cmdErr
+}
+
+func (cli *cli) run(
+	ctx context.Context,
+	args []string,
+	env map[string]string,
+	stdio command.Stdio,
+) command.CmdErr {
+	cli.reset()
+	defer func() { cli.dirty = true }()
+
+	// the built-in flags are needed to find the env file, and flag files,
+	// in args before they are parsed
+	if cli.app.HasSubCmds {
+		cli.addGlobalFlags()
+	} else {
+		cli.addCmdFlags(cli.commands[0])
+	}
+
+	env, cli.envFileErr = cli.loadEnvFile(args, env)
+	defer cli.useEnv(env)()
+	defer cli.useStdio(stdio)()
+
 	if err := cli.Validate(ValidateSkipHelpText); err != nil {
-		cli.stderr(fmt.Sprintf("%s\n\n", err))
-		cli.os.Exit(2)
+		cmdErr := mkBadInput(err)
+		cli.stderrCmdErr(cmdErr)
+		return cmdErr
 	}
 
-	cmdErr := cli.mainOrCmdErr()
+	cmdErr := cli.mainOrCmdErr(ctx, args)
+
+	// plugins report their own errors
+	if isPluginExit(cmdErr) {
+		return cmdErr
+	}
 
 	if cmdErr.IsError() {
-		cli.stderr(fmt.Sprintf("%s\n\n", cmdErr.Message))
+		cli.stderrCmdErr(cmdErr)
 	}
 
-	if cmdErr.Code == command.CmdErrCodeBadInput {
+	// usage is for humans; scripted callers get only the JSON error
+	if cmdErr.Code == command.CmdErrCodeBadInput && cli.errorFormat() != ErrorFormatJSON {
+		var usageErr command.CmdErr
 		if cmd := cmdErr.Cmd; cmd != nil {
-			cli.commandUsage(cmd)
+			usageErr = cli.commandUsage(cmd)
 		} else {
-			cli.globalUsage()
+			usageErr = cli.globalUsage()
+		}
+		if usageErr.IsError() {
+			cli.stderrCmdErr(usageErr)
 		}
 	}
 
-	cli.os.Exit(int(cmdErr.Code))
+	return cmdErr
+}
+
+// useEnv causes flags to be filled from the given environment, if it is
+// non-nil, from files if flag files are enabled, and from the environment
+// variables set with command.WithEnvKey, but not for flags marked
+// command.WithoutEnv, returning a function which restores the use of the
+// process environment.
+func (cli *cli) useEnv(env map[string]string) func() {
+	overrides := cli.hasEnvOptions()
+	if env == nil && !cli.flagFiles && !overrides {
+		return func() {}
+	}
+
+	flagsFromEnv, cmdFlagsFromEnv := cli.flagsFromEnv, cli.cmdFlagsFromEnv
+
+	wrap := func(fe tbnflag.FromEnv, fs *flag.FlagSet) tbnflag.FromEnv {
+		if env != nil || overrides {
+			fe = newLookupFromEnv(fe, fs, cli.lookupEnv, cli.envFileKeys)
+		}
+		if cli.flagFiles {
+			fe = newFileFromEnv(fe, fs, cli.lookupEnv)
+		}
+		return fe
+	}
+
+	cli.env = env
+	cli.flagsFromEnv = wrap(flagsFromEnv, &cli.flags)
+	cli.cmdFlagsFromEnv = map[string]tbnflag.FromEnv{}
+	for name, fe := range cmdFlagsFromEnv {
+		cmd := cli.commands[0]
+		if cli.app.HasSubCmds {
+			cmd = cli.command(name)
+		}
+		cli.cmdFlagsFromEnv[name] = wrap(fe, &cmd.Flags)
+	}
+
+	return func() {
+		cli.env = nil
+		cli.flagsFromEnv, cli.cmdFlagsFromEnv = flagsFromEnv, cmdFlagsFromEnv
+	}
+}
+
+// useStdio causes input and output to use the given streams, returning a
+// function which clears them.
+func (cli *cli) useStdio(stdio command.Stdio) func() {
+	cli.stdio = defaultStdio(stdio)
+	return func() { cli.stdio = command.Stdio{} }
+}
+
+// defaultStdio replaces nil streams with an empty input or discarded
+// output.
+func defaultStdio(stdio command.Stdio) command.Stdio {
+	if stdio.Stdin == nil {
+		stdio.Stdin = strings.NewReader("")
+	}
+	if stdio.Stdout == nil {
+		stdio.Stdout = ioutil.Discard
+	}
+	if stdio.Stderr == nil {
+		stdio.Stderr = ioutil.Discard
+	}
+	return stdio
+}
+
+// lookupEnv looks up the given key in the environment given to Run, or if
+// none was given, in the process environment.
+func (cli *cli) lookupEnv(key string) (string, bool) {
+	if cli.env != nil {
+		value, ok := cli.env[key]
+		return value, ok
+	}
+	return os.LookupEnv(key)
+}
+
+// style produces a style.Style for output written to the given io.Writer.
+func (cli *cli) style(w io.Writer) style.Style {
+	return style.New(cli.colorMode.EnabledInEnv(w, cli.lookupEnv))
+}
+
+// effectiveColorMode returns the color mode with which usage is rendered
+// and Runners are invoked. When an environment is given to Run, Auto is
+// resolved here, since usage and Runners consult the process environment.
+func (cli *cli) effectiveColorMode(w io.Writer) style.Mode {
+	if cli.env == nil || cli.colorMode != style.Auto {
+		return cli.colorMode
+	}
+	if cli.style(w).Enabled() {
+		return style.Always
+	}
+	return style.Never
 }
 
 func (cli *cli) Flags() *flag.FlagSet {
@@ -268,14 +863,69 @@ func (cli *cli) SetFlags(fs *flag.FlagSet) {
 	cli.flags = *fs
 }
 
-func (cli *cli) mainOrCmdErr() command.CmdErr {
+func (cli *cli) SetUsageTemplates(templates app.UsageTemplates) {
+	cli.app.UsageTemplates = templates
+}
+
+func (cli *cli) RegisterExitCode(code command.CmdErrCode, description string) {
+	cli.app.ExitCodes = append(
+		cli.app.ExitCodes,
+		command.ExitCode{Code: code, Description: description},
+	)
+}
+
+func (cli *cli) EnableMultiCall(mainName string) {
+	cli.multiCall = true
+	cli.name = mainName
+	cli.app.Name = mainName
+	cli.version = cli.app.Version()
+	cli.initFlagsFromEnv()
+}
+
+// multiCallArgs returns the arguments with which Main runs the CLI, given
+// the executable and its arguments. With multi-call dispatch enabled, an
+// executable named for a command, rather than for the CLI, selects that
+// command.
+func (cli *cli) multiCallArgs(executable string, args []string) []string {
+	if !cli.multiCall || !cli.app.HasSubCmds {
+		return args
+	}
+
+	name := filepath.Base(executable)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	if strings.EqualFold(name, cli.name) {
+		return args
+	}
+
+	if cmd := cli.command(name); cmd != nil {
+		return append([]string{cmd.Name}, args...)
+	}
+
+	return args
+}
+
+func (cli *cli) mainOrCmdErr(ctx context.Context, allArgs []string) command.CmdErr {
+	allArgs, err := cli.expandResponseFiles(allArgs)
+	if err == nil {
+		err = cli.envFileErr
+	}
+	if err != nil {
+		if !cli.app.HasSubCmds {
+			return cli.commands[0].BadInput(err)
+		}
+		return mkBadInput(err)
+	}
+
 	// if we have no sub cmds (e.g. a single anonymous command),
 	// it is implicitly the first argument
 	if !cli.app.HasSubCmds {
-		return cli.cmdOrCmdErr(cli.commands[0], cli.os.Args(), []string{})
+		return cli.cmdOrCmdErr(ctx, cli.commands[0], allArgs, []string{})
 	}
 
-	args, err := cli.parseGlobalFlags()
+	args, err := cli.parseGlobalFlags(allArgs)
 	if err != nil {
 		return mkBadInput(err)
 	}
@@ -284,21 +934,44 @@ func (cli *cli) mainOrCmdErr() command.CmdErr {
 		// <app> version [ignored]
 		// <app> -version [ignored]
 		// <app> -v [ignored]
-		fmt.Println(cli.version.Describe())
+		fmt.Fprintln(cli.stdio.Stdout, cli.version.Describe())
 		return command.NoError()
 	}
 
-	checkDeprecated(&cli.flags, "global ")
+	cli.checkDeprecated(&cli.flags, cli.flagsFromEnv, "global ")
 
 	missingErrs := checkRequired(&cli.flags, []string{}, "global ")
+	missingErrs = checkValid(&cli.flags, missingErrs, "global ")
+
+	// <app> <alias> [arguments...]
+	globalArgs := allArgs[:len(allArgs)-len(args)]
+	aliases, shadowing, err := cli.aliases()
+	if err == nil && len(args) > 0 {
+		if alias, ok := findAlias(shadowing, args[0]); ok {
+			err = shadowErr(alias)
+		}
+	}
+	if err == nil {
+		args, err = expandAliases(aliases, args)
+	}
+	if err != nil {
+		return mkBadInput(err)
+	}
 
 	if len(args) < 1 {
 		// <app> help
 		// <app> -help
 		// <app> -h
 		if cli.helpFlag {
-			cli.globalUsage()
-			return command.NoError()
+			return cli.globalUsage()
+		}
+
+		// <app> --show-config
+		if cli.showConfigFormat != showConfigOff {
+			if err := cli.applyProfile(nil); err != nil {
+				return mkBadInput(err)
+			}
+			return cli.showConfig(nil, nil)
 		}
 
 		errs := append([]string{"no command specified"}, missingErrs...)
@@ -307,26 +980,72 @@ func (cli *cli) mainOrCmdErr() command.CmdErr {
 
 	// determine which Cmd should be run, parse args
 	if cmd := cli.command(args[0]); cmd != nil {
-		return cli.cmdOrCmdErr(cmd, args, missingErrs)
+		cli.globalArgs = globalArgs
+		return cli.cmdOrCmdErr(ctx, cmd, args[1:], missingErrs)
 	}
 
-	return cli.handleBadCmd(args, missingErrs)
+	return cli.handleBadCmd(ctx, args, missingErrs)
 }
 
-func (cli *cli) parseGlobalFlags() ([]string, error) {
+// addGlobalFlags adds the built-in global flags, unless the application
+// defines flags of the same names.
+func (cli *cli) addGlobalFlags() {
 	addVersionFlagIfMissing(&cli.flags, &cli.versionFlag)
 	addHelpFlagIfMissing(&cli.flags, &cli.helpFlag)
+	addColorFlagIfMissing(&cli.flags, &cli.colorMode)
+	addErrorFormatFlagIfMissing(&cli.flags, &cli.errFormat)
+	cli.addEnvFileFlagIfMissing(&cli.flags, &cli.envFile)
+	cli.addProfileFlagIfMissing(&cli.flags, &cli.profile)
+	cli.addShowConfigFlagIfMissing(&cli.flags, &cli.showConfigFormat)
+}
+
+// addCmdFlags adds the built-in flags of the command, unless it defines
+// flags of the same names.
+func (cli *cli) addCmdFlags(cmd *command.Cmd) {
+	// only add help flag if not already present
+	addHelpFlagIfMissing(&cmd.Flags, &cli.cmdHelpFlag)
+
+	// only add version flag if not already present
+	addVersionFlagIfMissing(&cmd.Flags, &cli.cmdVersionFlag)
+
+	// without sub commands, there are no global flags, so the
+	// color and error format flags belong to the command
+	if !cli.app.HasSubCmds {
+		addColorFlagIfMissing(&cmd.Flags, &cli.colorMode)
+		addErrorFormatFlagIfMissing(&cmd.Flags, &cli.errFormat)
+		cli.addEnvFileFlagIfMissing(&cmd.Flags, &cli.envFile)
+	}
+
+	// like help, show-config may follow the command
+	cli.addShowConfigFlagIfMissing(&cmd.Flags, &cli.showConfigFormat)
+}
+
+func (cli *cli) parseGlobalFlags(args []string) ([]string, error) {
+	cli.addGlobalFlags()
+
+	args, err := cli.expandFlagFiles(&cli.flags, args)
+	if err != nil {
+		return nil, err
+	}
 
 	// parse flags
-	if err := quietParse(&cli.flags, cli.os.Args()[1:]); err != nil {
+	if err := quietParse(&cli.flags, args); err != nil {
+		return nil, err
+	}
+	if err := command.ApplyAliases(&cli.flags); err != nil {
 		return nil, err
 	}
+	cli.globalArgFlags = visited(&cli.flags)
+	cli.noteHelpFlags(&cli.flags, cli.globalArgFlags)
 
 	// fill unset flags from env
 	if err := cli.flagsFromEnv.Fill(); err != nil {
 		return nil, err
 	}
-	args := cli.flags.Args()
+	if err := command.ApplyAliases(&cli.flags); err != nil {
+		return nil, err
+	}
+	args = cli.flags.Args()
 
 	// treat help as -help
 	if len(args) > 0 && args[0] == "help" {
@@ -343,60 +1062,126 @@ func (cli *cli) parseGlobalFlags() ([]string, error) {
 	return args, nil
 }
 
-func (cli *cli) cmdOrCmdErr(cmd *command.Cmd, args []string, missingErrs []string) command.CmdErr {
-	// only add help flag if not already present
-	var cmdHelpFlag bool
-	addHelpFlagIfMissing(&cmd.Flags, &cmdHelpFlag)
+func (cli *cli) cmdOrCmdErr(
+	ctx context.Context,
+	cmd *command.Cmd,
+	args []string,
+	missingErrs []string,
+) command.CmdErr {
+	cli.addCmdFlags(cmd)
 
-	// only add version flag if not already present
-	var cmdVersionFlag bool
-	addVersionFlagIfMissing(&cmd.Flags, &cmdVersionFlag)
+	args, err := cli.expandFlagFiles(&cmd.Flags, args)
+	if err != nil {
+		return cmd.BadInput(err)
+	}
 
 	// parse flags
-	if err := quietParse(&cmd.Flags, args[1:]); err != nil {
+	if err := quietParse(&cmd.Flags, args); err != nil {
 		return cmd.BadInput(err)
 	}
+	if err := command.ApplyAliases(&cmd.Flags); err != nil {
+		return cmd.BadInput(err)
+	}
+	argFlags := visited(&cmd.Flags)
+	cli.noteHelpFlags(&cmd.Flags, argFlags)
 
 	// fill unset flags from env
 	if err := cli.commandFlagsFromEnv(cmd).Fill(); err != nil {
 		return cmd.BadInput(err)
 	}
+	if err := command.ApplyAliases(&cmd.Flags); err != nil {
+		return cmd.BadInput(err)
+	}
 
 	// <app> <command> -help
 	// <app> <command> -h
 	// <app> help <command>
 	// <app> -help <command>
 	// <app> -h <command>
-	if cmdHelpFlag || cli.helpFlag {
-		cli.commandUsage(cmd)
-		return command.NoError()
+	if cli.cmdHelpFlag || cli.helpFlag {
+		return cli.commandUsage(cmd)
 	}
 
 	// <app> <command> -version
 	// <app> <command> -v
-	if cmdVersionFlag {
-		fmt.Println(cli.version.Describe())
+	if cli.cmdVersionFlag {
+		fmt.Fprintln(cli.stdio.Stdout, cli.version.Describe())
 		return command.NoError()
 	}
 
-	checkDeprecated(&cmd.Flags, "")
+	// fill unset flags from the selected profile, which may also satisfy
+	// required global flags; the profile command, which manages profiles,
+	// requires none
+	if cli.profilesEnabled {
+		if err := cli.applyProfile(cmd); err != nil {
+			return cmd.BadInput(err)
+		}
+		missingErrs = nil
+		if cmd != cli.profileCmd {
+			missingErrs = checkRequired(&cli.flags, []string{}, "global ")
+			missingErrs = checkValid(&cli.flags, missingErrs, "global ")
+		}
+	}
+
+	// <app> <command> --show-config
+	// <app> --show-config <command>
+	if cli.showConfigFormat != showConfigOff {
+		return cli.showConfig(cmd, argFlags)
+	}
+
+	cli.checkDeprecated(&cmd.Flags, cli.commandFlagsFromEnv(cmd), "")
+	cli.checkDeprecatedCmd(cmd)
 
 	missingErrs = checkRequired(&cmd.Flags, missingErrs, "")
+	missingErrs = checkValid(&cmd.Flags, missingErrs, "")
 	if len(missingErrs) > 0 {
 		return cmd.BadInputf("\n  %s", strings.Join(missingErrs, "\n  "))
 	}
 
+	// don't start the command if the context is already done
+	if err := ctx.Err(); err != nil {
+		return cmd.Wrap(err)
+	}
+
 	// run the command
+	cmd.ColorMode = cli.effectiveColorMode(cli.stdio.Stdout)
+	cmd.Stdio = cli.stdio
+	cmd.SetContext(ctx)
 	return cmd.Run()
 }
 
-func (cli *cli) handleBadCmd(args []string, validationErrs []string) command.CmdErr {
+func (cli *cli) handleBadCmd(
+	ctx context.Context,
+	args []string,
+	validationErrs []string,
+) command.CmdErr {
+	// <app> <plugin> [arguments...]
+	// <app> help <plugin>
+	if path, ok := cli.findPlugin(args[0]); ok {
+		if cli.profilesEnabled {
+			if err := cli.applyProfile(nil); err != nil {
+				return mkBadInput(err)
+			}
+			validationErrs = checkRequired(&cli.flags, []string{}, "global ")
+			validationErrs = checkValid(&cli.flags, validationErrs, "global ")
+		}
+
+		if len(validationErrs) > 0 {
+			return mkBadInput(strings.Join(validationErrs, "\n"))
+		}
+
+		pluginArgs := args[1:]
+		if cli.helpFlag {
+			pluginArgs = append([]string{"--help"}, pluginArgs...)
+		}
+		return cli.runPlugin(ctx, args[0], path, pluginArgs)
+	}
+
 	// <app> help <unknown command>
 	// <app> -help <unknown command>
 	// <app> -h <unknown command>
 	if cli.helpFlag {
-		cli.globalUsage()
-		return command.NoError()
+		return cli.globalUsage()
 	}
 
 	// if we got this far, the specified command is bogus, no
@@ -411,8 +1196,7 @@ func (cli *cli) handleBadCmd(args []string, validationErrs []string) command.Cmd
 	// <app> <unknown command> -help
 	// <app> <unknown command> -h
 	if badCmdHelpFlag {
-		cli.globalUsage()
-		return command.NoError()
+		return cli.globalUsage()
 	}
 
 	errs := append([]string{fmt.Sprintf("unknown command: %q", args[0])}, validationErrs...)
@@ -428,12 +1212,60 @@ func (cli *cli) command(name string) *command.Cmd {
 	return nil
 }
 
-func (cli *cli) globalUsage() {
-	cli.usage.Global(cli.commands, cli.flagsFromEnv)
+// styledUsage produces an app.Usage writing to the given io.Writer. Usage
+// written to os.Stdout is wrapped to the width of the terminal.
+func (cli *cli) styledUsage(w io.Writer, mode style.Mode) app.Usage {
+	if w == os.Stdout {
+		return cli.app.StyledUsage(mode)
+	}
+	return cli.app.StyledRedirectedUsage(w, mode)
 }
 
-func (cli *cli) commandUsage(cmd *command.Cmd) {
-	cli.usage.Command(cmd, cli.flagsFromEnv, cli.commandFlagsFromEnv(cmd))
+// noteHelpFlags records whether help was requested with -h or --help,
+// given the flags set on the command line.
+func (cli *cli) noteHelpFlags(fs *flag.FlagSet, set map[string]bool) {
+	if set["help"] {
+		cli.fullHelp = true
+	} else if set["h"] && fs.Lookup("h").Usage == HelpSummary {
+		// -h is only help if not defined by the application
+		cli.briefHelp = true
+	}
+}
+
+// globalUsage writes global usage to stdout, returning an error if it
+// cannot be rendered.
+func (cli *cli) globalUsage() command.CmdErr {
+	cli.app.ShortUsage = cli.briefHelp && !cli.fullHelp
+	cli.app.Plugins = cli.discoverPlugins()
+	// errors were reported when aliases were expanded
+	aliases, shadowing, _ := cli.aliases()
+	cli.app.Aliases = aliases
+	for _, alias := range shadowing {
+		cli.stderrWarning(shadowErr(alias).Error())
+	}
+
+	w := cli.stdio.Stdout
+	err := cli.newUsage(w, cli.effectiveColorMode(w)).Global(cli.commands, cli.flagsFromEnv)
+	if err != nil {
+		return command.CmdErr{
+			Code:    command.CmdErrCodeError,
+			Message: fmt.Sprintf("rendering usage: %s", err),
+			Cause:   err,
+		}
+	}
+	return command.NoError()
+}
+
+// commandUsage writes the usage of the command to stdout, returning an
+// error if it cannot be rendered.
+func (cli *cli) commandUsage(cmd *command.Cmd) command.CmdErr {
+	cli.app.ShortUsage = cli.briefHelp && !cli.fullHelp
+	w := cli.stdio.Stdout
+	err := cli.newUsage(w, cli.effectiveColorMode(w)).Command(cmd, cli.flagsFromEnv, cli.commandFlagsFromEnv(cmd))
+	if err != nil {
+		return cmd.Wrapf(err, "rendering usage")
+	}
+	return command.NoError()
 }
 
 func (cli *cli) commandFlagsFromEnv(cmd *command.Cmd) tbnflag.FromEnv {
@@ -443,8 +1275,74 @@ func (cli *cli) commandFlagsFromEnv(cmd *command.Cmd) tbnflag.FromEnv {
 	return cli.cmdFlagsFromEnv[cli.name]
 }
 
-func (cli *cli) stderr(msg string) {
-	fmt.Fprint(cli.os.Stderr(), msg)
+// stderrError writes the given error message to stderr, styled as an error
+// if appropriate.
+func (cli *cli) stderrError(msg string) {
+	w := cli.stdio.Stderr
+	fmt.Fprintf(w, "%s\n\n", cli.style(w).Error(msg))
+}
+
+// stderrWarning writes the given message to stderr, styled as a warning.
+// In ErrorFormatJSON, warnings are suppressed, so that stderr holds at
+// most the JSONError.
+func (cli *cli) stderrWarning(msg string) {
+	if cli.errorFormat() == ErrorFormatJSON {
+		return
+	}
+	w := cli.stdio.Stderr
+	fmt.Fprintln(w, cli.style(w).Warning("warning: "+msg))
+}
+
+// stderrCmdErr writes the given CmdErr to stderr. In ErrorFormatJSON, it
+// is written as a JSONError. Otherwise its message is written, styled as
+// an error if appropriate, followed by its hint and documentation URL, if
+// any.
+func (cli *cli) stderrCmdErr(cmdErr command.CmdErr) {
+	if cli.errorFormat() == ErrorFormatJSON {
+		writeJSONError(cli.stdio.Stderr, cmdErr)
+		return
+	}
+
+	if cmdErr.Hint == "" && cmdErr.DocURL == "" {
+		cli.stderrError(cmdErr.Error())
+		return
+	}
+
+	w := cli.stdio.Stderr
+	s := cli.style(w)
+	fmt.Fprintln(w, s.Error(cmdErr.Error()))
+	if cmdErr.Hint != "" {
+		fmt.Fprintln(w, s.Info("hint: "+cmdErr.Hint))
+	}
+	if cmdErr.DocURL != "" {
+		fmt.Fprintln(w, "see: "+cmdErr.DocURL)
+	}
+	fmt.Fprintln(w)
+}
+
+// errorFormat returns the ErrorFormat for reporting errors. Since errors
+// may occur before flags are filled from the environment, the environment
+// is consulted directly if the error format flag has not been set.
+func (cli *cli) errorFormat() ErrorFormat {
+	fs := &cli.flags
+	if !cli.app.HasSubCmds {
+		fs = &cli.commands[0].Flags
+	}
+
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == "error-format"
+	})
+
+	if !set {
+		var errFormat ErrorFormat
+		value, _ := cli.lookupEnv(tbnflag.EnvKey(cli.name, "error-format"))
+		if errFormat.Set(value) == nil {
+			return errFormat
+		}
+	}
+
+	return cli.errFormat
 }
 
 func checkRequired(fs *flag.FlagSet, errStrs []string, prefix string) []string {
@@ -454,12 +1352,96 @@ func checkRequired(fs *flag.FlagSet, errStrs []string, prefix string) []string {
 	return errStrs
 }
 
-func checkDeprecated(fs *flag.FlagSet, prefix string) {
+func checkValid(fs *flag.FlagSet, errStrs []string, prefix string) []string {
+	set := visited(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		value, err := command.ValidateFlag(f, set[f.Name])
+		if err == nil {
+			return
+		}
+		// don't echo secrets
+		if usage.New(f.Usage).IsSensitive() {
+			errStrs = append(errStrs, fmt.Sprintf("invalid value for %sflag --%s: %s", prefix, f.Name, err))
+		} else {
+			errStrs = append(errStrs, fmt.Sprintf("invalid value %q for %sflag --%s: %s", value, prefix, f.Name, err))
+		}
+	})
+	return errStrs
+}
+
+// checkDeprecated warns of deprecated flags, and aliases, which are set.
+// An alias filled from the environment is reported by its environment
+// variable, along with that of the flag it stands for.
+func (cli *cli) checkDeprecated(fs *flag.FlagSet, fe tbnflag.FromEnv, prefix string) {
 	for _, name := range usage.DeprecatedAndSet(fs) {
-		console.Error().Printf("%sflag --%s is deprecated", prefix, name)
+		cli.stderrWarning(fmt.Sprintf("%sflag --%s is deprecated", prefix, name))
+	}
+	fs.Visit(func(f *flag.Flag) {
+		name, ok := command.FlagAliasOf(f)
+		if !ok {
+			return
+		}
+		if key, suffix, ok := filledEnvKey(fe, f); ok {
+			if newKey, ok := envKey(fe, fs.Lookup(name)); ok {
+				cli.stderrWarning(fmt.Sprintf(
+					"environment variable %s%s is deprecated, use %s%s",
+					key,
+					suffix,
+					newKey,
+					suffix,
+				))
+				return
+			}
+		}
+		cli.stderrWarning(fmt.Sprintf("%sflag --%s is deprecated, use --%s", prefix, f.Name, name))
+	})
+}
+
+// filledEnvKey returns the environment variable, if any, from which the
+// FromEnv filled the flag, and FileEnvSuffix if it named a flag file.
+func filledEnvKey(fe tbnflag.FromEnv, f *flag.Flag) (string, string, bool) {
+	key, ok := envKey(fe, f)
+	if !ok {
+		return "", "", false
+	}
+	filled := fe.Filled()
+	for _, suffix := range []string{"", FileEnvSuffix} {
+		if _, ok := filled[key+suffix]; ok {
+			return key, suffix, true
+		}
+	}
+	return "", "", false
+}
+
+// checkDeprecatedCmd warns if the command is deprecated.
+func (cli *cli) checkDeprecatedCmd(cmd *command.Cmd) {
+	if cmd.Deprecated != "" {
+		cli.stderrWarning(fmt.Sprintf("command %s is deprecated: %s", cmd.Name, cmd.Deprecated))
 	}
 }
 
+// copyFlagSet produces a new FlagSet containing the same flags, sharing
+// their flag.Values and default values.
+func copyFlagSet(fs *flag.FlagSet) *flag.FlagSet {
+	fsCopy := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	fs.VisitAll(func(f *flag.Flag) {
+		fsCopy.Var(f.Value, f.Name, f.Usage)
+		fsCopy.Lookup(f.Name).DefValue = f.DefValue
+	})
+	return fsCopy
+}
+
+// resetFlagSet restores each flag in the FlagSet to its default value, and
+// forgets which flags have been set. Values which cannot be set from
+// their default value are left unchanged.
+func resetFlagSet(fs *flag.FlagSet) {
+	fresh := copyFlagSet(fs)
+	fresh.VisitAll(func(f *flag.Flag) {
+		command.ResetValue(f.Value, f.DefValue)
+	})
+	*fs = *fresh
+}
+
 func quietParse(fs *flag.FlagSet, args []string) error {
 	fs.SetOutput(ioutil.Discard)
 	return fs.Parse(args)
@@ -474,6 +1456,18 @@ func addVersionFlagIfMissing(fs *flag.FlagSet, flag *bool) {
 	}
 }
 
+func addColorFlagIfMissing(fs *flag.FlagSet, mode *style.Mode) {
+	if fs.Lookup("color") == nil {
+		fs.Var(mode, "color", ColorSummary)
+	}
+}
+
+func addErrorFormatFlagIfMissing(fs *flag.FlagSet, errFormat *ErrorFormat) {
+	if fs.Lookup("error-format") == nil {
+		fs.Var(errFormat, "error-format", ErrorFormatSummary)
+	}
+}
+
 func addHelpFlagIfMissing(fs *flag.FlagSet, flag *bool) {
 	if fs.Lookup("help") == nil {
 		fs.BoolVar(flag, "help", false, HelpSummary)
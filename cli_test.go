@@ -18,8 +18,12 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"testing"
 
@@ -27,6 +31,7 @@ import (
 
 	"github.com/turbinelabs/cli/app"
 	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
 	tbnflag "github.com/turbinelabs/nonstdlib/flag"
 	"github.com/turbinelabs/nonstdlib/flag/usage"
 	tbnos "github.com/turbinelabs/nonstdlib/os"
@@ -51,6 +56,73 @@ func TestValidate(t *testing.T) {
 	assert.DeepEqual(t, fooCli.Validate(ValidateSkipHelpText), wantErr)
 }
 
+func TestValidateEnvOptions(t *testing.T) {
+	barCmd := &command.Cmd{Name: "bar"}
+	bazCmd := &command.Cmd{Name: "baz"}
+	fooCli := mkNew(app.App{Name: "foo"}, barCmd, bazCmd)
+
+	fooCli.Flags().String("token", "", "")
+	barCmd.Flags.String("secret", "", "")
+	command.SetFlagOptions(&barCmd.Flags, "secret", command.WithEnvKey("FOO_TOKEN"))
+	bazCmd.Flags.String("x", "", "")
+	command.SetFlagOptions(&bazCmd.Flags, "x", command.WithEnvKey("FOO_TOKEN"))
+
+	wantErr := errors.New(`possible environment key collisions:
+  FOO_TOKEN: "foo -token", "foo bar -secret", "foo baz -x"
+`)
+	assert.DeepEqual(t, fooCli.Validate(ValidateSkipHelpText), wantErr)
+
+	// flags without an environment variable cannot collide
+	command.SetFlagOptions(&barCmd.Flags, "secret", command.WithoutEnv())
+	command.SetFlagOptions(&bazCmd.Flags, "x", command.WithoutEnv())
+	assert.Nil(t, fooCli.Validate(ValidateSkipHelpText))
+}
+
+func TestValidateFlagAliases(t *testing.T) {
+	barCmd := &command.Cmd{Name: "bar"}
+	fooCli := mkNew(app.App{Name: "foo", HasSubCmds: true}, barCmd)
+
+	fooCli.Flags().String("timeout", "", "")
+	command.AliasFlag(fooCli.Flags(), "wait", "timeout")
+	barCmd.Flags.String("region", "", "")
+	command.AliasFlag(&barCmd.Flags, "zone", "region")
+	assert.Nil(t, fooCli.Validate(ValidateSkipHelpText))
+
+	command.AliasFlag(&barCmd.Flags, "az", "zone")
+	assert.DeepEqual(t, fooCli.Validate(ValidateSkipHelpText), errors.New(`conflicting flag aliases:
+  foo bar -az: alias of -zone, which is an alias of -region
+`))
+
+	// aliases are filled from the environment, so may collide, too
+	bazCmd := &command.Cmd{Name: "baz"}
+	bazCmd.Flags.String("wait", "", "")
+	fooCli = mkNew(app.App{Name: "foo", HasSubCmds: true}, bazCmd)
+	fooCli.Flags().String("timeout", "", "")
+	command.AliasFlag(fooCli.Flags(), "baz.wait", "timeout")
+	assert.ErrorContains(t, fooCli.Validate(ValidateSkipHelpText), "possible environment key collisions")
+}
+
+func TestValidateExitCodes(t *testing.T) {
+	barCmd := &command.Cmd{Name: "bar"}
+	fooCli := mkNew(app.App{Name: "foo"}, barCmd)
+
+	fooCli.RegisterExitCode(3, "the bar was closed")
+	fooCli.RegisterExitCode(command.MaxExitCode, "the bar was too far")
+	assert.Nil(t, fooCli.Validate(ValidateSkipHelpText))
+
+	fooCli.RegisterExitCode(command.CmdErrCodeBadInput, "bad bar")
+	fooCli.RegisterExitCode(3, "the bar was still closed")
+	fooCli.RegisterExitCode(command.MaxExitCode+1, "the bar was much too far")
+
+	wantErr := errors.New(`invalid exit code(s):
+  2: already defined
+  3: already defined
+  126: greater than 125
+`)
+
+	assert.DeepEqual(t, fooCli.Validate(ValidateSkipHelpText), wantErr)
+}
+
 func TestValidateHelpText(t *testing.T) {
 	barCmd := &command.Cmd{Name: "bar"}
 	barBazCmd := &command.Cmd{Name: "bar-baz"}
@@ -74,6 +146,204 @@ func TestValidateHelpText(t *testing.T) {
 	assert.Nil(t, fooCli.Validate(ValidateSkipHelpText))
 }
 
+func TestValidateUsageTemplates(t *testing.T) {
+	barCmd := &command.Cmd{Name: "bar"}
+	barBazCmd := &command.Cmd{Name: "bar-baz"}
+	fooCli := mkNew(app.App{Name: "foo"}, barCmd, barBazCmd)
+
+	fooCli.SetUsageTemplates(app.UsageTemplates{
+		Global:  `{{bold "EXIT STATUS"}}{{range .Commands}} {{.Name}}{{end}}`,
+		Command: `{{bold "EXAMPLES"}} {{.Cmd.Name}}`,
+	})
+	assert.Nil(t, fooCli.Validate())
+
+	fooCli.SetUsageTemplates(app.UsageTemplates{
+		Command: `{{bold "SEE ALSO"}} {{.Cmd.SeeAlso}}`,
+	})
+	assert.ErrorContains(t, fooCli.Validate(), `can't evaluate field SeeAlso`)
+
+	// ignores help text in this case
+	assert.Nil(t, fooCli.Validate(ValidateSkipHelpText))
+
+	// but not templates which fail to parse
+	fooCli.SetUsageTemplates(app.UsageTemplates{Global: `{{end}}`})
+	assert.ErrorContains(t, fooCli.Validate(), `unexpected {{end}}`)
+	assert.ErrorContains(t, fooCli.Validate(ValidateSkipHelpText), `invalid usage templates`)
+}
+
+func TestCLIRunUsageTemplateErrors(t *testing.T) {
+	barCmd := &command.Cmd{Name: "bar"}
+	fooCli := mkNew(app.App{Name: "foo", HasSubCmds: true}, barCmd)
+
+	for _, tc := range []struct {
+		templates app.UsageTemplates
+		args      []string
+		wantCode  command.CmdErrCode
+		wantErr   string
+	}{
+		{
+			templates: app.UsageTemplates{Global: `{{end}}`},
+			args:      []string{"--help"},
+			wantCode:  command.CmdErrCodeBadInput,
+			wantErr:   `invalid usage templates: template: global_usage:1: unexpected {{end}}`,
+		},
+		{
+			templates: app.UsageTemplates{Global: `{{.SeeAlso}}`},
+			args:      []string{"--help"},
+			wantCode:  command.CmdErrCodeError,
+			wantErr:   `rendering usage: template: global_usage:1:2: executing "global_usage"`,
+		},
+		{
+			templates: app.UsageTemplates{Command: `{{.Cmd.SeeAlso}}`},
+			args:      []string{"bar", "--help"},
+			wantCode:  command.CmdErrCodeError,
+			wantErr:   `bar: rendering usage: template: command_usage:1:6: executing "command_usage"`,
+		},
+		{
+			// bad input is still reported if usage fails
+			templates: app.UsageTemplates{Global: `{{.SeeAlso}}`},
+			args:      []string{"baz"},
+			wantCode:  command.CmdErrCodeBadInput,
+			wantErr:   `rendering usage: template: global_usage:1:2: executing "global_usage"`,
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q) with %v", tc.args, tc.templates),
+			t,
+			func(g *assert.G) {
+				fooCli.SetUsageTemplates(tc.templates)
+				var stdout, stderr bytes.Buffer
+				cmdErr := fooCli.Run(
+					context.Background(),
+					tc.args,
+					map[string]string{},
+					command.Stdio{Stdout: &stdout, Stderr: &stderr},
+				)
+				assert.Equal(g, cmdErr.Code, tc.wantCode)
+				assert.True(g, strings.Contains(stderr.String(), tc.wantErr))
+			},
+		)
+	}
+}
+
+func TestValidateExamples(t *testing.T) {
+	var verbose bool
+	var delim, apiKey string
+
+	barCmd := &command.Cmd{
+		Name: "bar",
+		Examples: []command.Example{
+			{CommandLine: "foo bar --delim=, a,b"},
+			{CommandLine: `foo --verbose bar -delim "; " 'a; b'`},
+			{CommandLine: "foo bar -h"},
+			{CommandLine: "foo help bar"},
+		},
+	}
+	barCmd.Flags.StringVar(&delim, "delim", "", "")
+	bazCmd := &command.Cmd{
+		Name: "baz",
+		Examples: []command.Example{
+			{CommandLine: "foo baz --api-key=xyz"},
+			{CommandLine: "foo baz"},
+		},
+	}
+	bazCmd.Flags.StringVar(&apiKey, "api-key", "", usage.Required("the key"))
+
+	fooCli := mkNew(app.App{Name: "foo", HasSubCmds: true}, barCmd, bazCmd)
+	fooCli.Flags().BoolVar(&verbose, "verbose", false, "")
+
+	// only validated if requested
+	bazCmd.Examples = append(bazCmd.Examples, command.Example{CommandLine: "foo baz --nope"})
+	assert.Nil(t, fooCli.Validate())
+
+	bazCmd.Examples = append(
+		bazCmd.Examples,
+		command.Example{CommandLine: "foo bar"},
+		command.Example{CommandLine: "foo 'baz"},
+	)
+
+	wantErr := errors.New(`invalid example(s):
+  baz: "foo baz": --api-key is a required flag
+  baz: "foo baz --nope": flag provided but not defined: -nope
+  baz: "foo bar": does not invoke the "baz" command
+  baz: "foo 'baz": unterminated quoted string
+`)
+	assert.DeepEqual(t, fooCli.Validate(ValidateExamples), wantErr)
+
+	// the original FlagSets are unmodified
+	assert.Nil(t, fooCli.Flags().Lookup("help"))
+	assert.Nil(t, barCmd.Flags.Lookup("help"))
+	assert.Nil(t, barCmd.Flags.Lookup("color"))
+
+	bazCmd.Examples = bazCmd.Examples[0:1]
+	assert.Nil(t, fooCli.Validate(ValidateExamples))
+}
+
+func TestValidateExamplesThenRun(t *testing.T) {
+	var env string
+	deploy := &command.Cmd{
+		Name: "deploy",
+		Examples: []command.Example{
+			{CommandLine: "app deploy --env=prod"},
+		},
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			fmt.Fprintln(cmd.Stdout(), env)
+			return command.NoError()
+		}),
+	}
+	deploy.Flags.StringVar(&env, "env", "dev", "the environment")
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, deploy)
+	assert.Nil(t, c.Validate(ValidateExamples))
+
+	// the example's flag values do not leak into the run
+	cmdErr, stdout, _ := runCLI(c, []string{"deploy"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, "dev\n")
+}
+
+func TestValidateExamplesValidators(t *testing.T) {
+	cmd := &command.Cmd{
+		Name: "serve",
+		Examples: []command.Example{
+			{CommandLine: "foo serve"},
+			{CommandLine: "foo serve --port=8080"},
+			{CommandLine: "foo --workers=0 serve --port=0"},
+		},
+	}
+	cmd.Flags.Int("port", 0, "")
+	command.SetFlagOptions(&cmd.Flags, "port", command.WithValidators(command.Range(1, 65535)))
+
+	fooCli := mkNew(app.App{Name: "foo", HasSubCmds: true}, cmd)
+	fooCli.Flags().Int("workers", 4, "")
+	command.SetFlagOptions(fooCli.Flags(), "workers", command.WithValidators(command.Range(1, 64)))
+
+	wantErr := errors.New(`invalid example(s):
+  serve: "foo --workers=0 serve --port=0": invalid value "0" for global flag --workers: must be between 1 and 64, invalid value "0" for flag --port: must be between 1 and 65535
+`)
+	assert.DeepEqual(t, fooCli.Validate(ValidateExamples), wantErr)
+}
+
+func TestValidateExamplesSingleCommand(t *testing.T) {
+	var n int
+	cmd := &command.Cmd{
+		Name: "foo",
+		Examples: []command.Example{
+			{CommandLine: "./foo -n 3 --color=never x y"},
+			{CommandLine: "foo -version"},
+			{CommandLine: "foo -n x"},
+		},
+	}
+	cmd.Flags.IntVar(&n, "n", 0, "")
+
+	fooCli := mkNew(app.App{Name: "foo"}, cmd)
+
+	wantErr := errors.New(`invalid example(s):
+  foo: "foo -n x": invalid value "x" for flag -n: parse error
+`)
+	assert.DeepEqual(t, fooCli.Validate(ValidateExamples), wantErr)
+}
+
 type cmdType string
 
 const (
@@ -91,6 +361,7 @@ type cliTestMocks struct {
 	cmdFooFlagsFromEnv *tbnflag.MockFromEnv
 	cmdBarFlagsFromEnv *tbnflag.MockFromEnv
 	os                 *tbnos.MockOS
+	stdout             *bytes.Buffer
 	stderr             *bytes.Buffer
 	finish             func()
 }
@@ -117,6 +388,7 @@ func newCLIAndMocks(t testing.TB, cType cmdType) (*cli, *cliTestMocks) {
 		cmdFooFlagsFromEnv: tbnflag.NewMockFromEnv(ctrl),
 		cmdBarFlagsFromEnv: tbnflag.NewMockFromEnv(ctrl),
 		os:                 tbnos.NewMockOS(ctrl),
+		stdout:             &bytes.Buffer{},
 		stderr:             &bytes.Buffer{},
 		finish:             ctrl.Finish,
 	}
@@ -140,8 +412,8 @@ func newCLIAndMocks(t testing.TB, cType cmdType) (*cli, *cliTestMocks) {
 		app: app.App{
 			HasSubCmds: cType != noSubCmd,
 		},
-		usage:   mocks.usage,
-		version: mocks.version,
+		newUsage: func(io.Writer, style.Mode) app.Usage { return mocks.usage },
+		version:  mocks.version,
 
 		flagsFromEnv:    mocks.flagsFromEnv,
 		cmdFlagsFromEnv: fromEnvMap,
@@ -149,6 +421,10 @@ func newCLIAndMocks(t testing.TB, cType cmdType) (*cli, *cliTestMocks) {
 		os: mocks.os,
 	}
 
+	mocks.os.EXPECT().Stdin().Return(&bytes.Buffer{}).AnyTimes()
+	mocks.os.EXPECT().Stdout().Return(mocks.stdout).AnyTimes()
+	mocks.os.EXPECT().Stderr().Return(mocks.stderr).AnyTimes()
+
 	return cli, mocks
 }
 
@@ -443,10 +719,6 @@ func TestCLI(t *testing.T) {
 						mocks.fooRunner.EXPECT().Run(fooCmd, []string{"baz"}).Return(cmdErr)
 					}
 
-					if tc.err != "" {
-						mocks.os.EXPECT().Stderr().Return(mocks.stderr)
-					}
-
 					mocks.os.EXPECT().Exit(int(tc.errCode))
 
 					c.Main()
@@ -454,8 +726,648 @@ func TestCLI(t *testing.T) {
 					assert.Equal(g, tc.cliBarFlagValue, cliBarFlag)
 					assert.Equal(g, tc.cmdBarFlagValue, cmdBarFlag)
 					assert.Equal(g, mocks.stderr.String(), tc.err)
+
+					if tc.versionCalled {
+						assert.Equal(g, mocks.stdout.String(), "version\n")
+					}
 				},
 			)
 		}
 	}
 }
+
+func TestCLIColor(t *testing.T) {
+	for _, tc := range []struct {
+		args      []string
+		cmdType   cmdType
+		wantMode  style.Mode
+		wantError string
+	}{
+		{
+			args:      []string{"-bar", "a", "foo", "-bar", "b", "baz"},
+			cmdType:   multipleCmds,
+			wantMode:  style.Auto,
+			wantError: "foo: Gah!\n\n",
+		},
+		{
+			args:      []string{"-color=always", "-bar", "a", "foo", "-bar", "b", "baz"},
+			cmdType:   multipleCmds,
+			wantMode:  style.Always,
+			wantError: "\033[31mfoo: Gah!\033[0m\n\n",
+		},
+		{
+			args:      []string{"-color", "never", "-bar", "b", "baz"},
+			cmdType:   noSubCmd,
+			wantMode:  style.Never,
+			wantError: "foo: Gah!\n\n",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("TestCLIColor(%s)", strings.Join(tc.args, " ")),
+			t,
+			func(g *assert.G) {
+				c, mocks := newCLIAndMocks(g, tc.cmdType)
+				defer mocks.finish()
+
+				fooCmd := c.command("foo")
+
+				var cmdBarFlag, cliBarFlag string
+				c.flags.StringVar(&cliBarFlag, "bar", "", "")
+				fooCmd.Flags.StringVar(&cmdBarFlag, "bar", "", "")
+
+				mocks.os.EXPECT().Args().Return(append([]string{c.name}, tc.args...))
+				if tc.cmdType == multipleCmds {
+					mocks.flagsFromEnv.EXPECT().Fill().Return(nil)
+				}
+				mocks.cmdFooFlagsFromEnv.EXPECT().Fill().Return(nil)
+				mocks.fooRunner.EXPECT().Run(fooCmd, []string{"baz"}).Return(fooCmd.Error("Gah!"))
+				mocks.os.EXPECT().Exit(int(command.CmdErrCodeError))
+
+				c.Main()
+
+				assert.Equal(g, c.colorMode, tc.wantMode)
+				assert.Equal(g, fooCmd.ColorMode, tc.wantMode)
+				assert.Equal(g, mocks.stderr.String(), tc.wantError)
+			},
+		)
+	}
+}
+
+func TestCLIRichError(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		mkErr     func(*command.Cmd) command.CmdErr
+		args      []string
+		wantCode  int
+		wantError string
+	}{
+		{
+			name: "hint and doc url",
+			mkErr: func(cmd *command.Cmd) command.CmdErr {
+				return cmd.Wrap(errors.New("Gah!")).
+					WithHint("try again").
+					WithDocURL("http://example.com/gah")
+			},
+			wantCode:  int(command.CmdErrCodeError),
+			wantError: "foo: Gah!\nhint: try again\nsee: http://example.com/gah\n\n",
+		},
+		{
+			name: "styled hint",
+			mkErr: func(cmd *command.Cmd) command.CmdErr {
+				return cmd.Error("Gah!").WithHint("try again")
+			},
+			args:      []string{"-color=always"},
+			wantCode:  int(command.CmdErrCodeError),
+			wantError: "\033[31mfoo: Gah!\033[0m\n\033[36mhint: try again\033[0m\n\n",
+		},
+		{
+			name: "custom exit code",
+			mkErr: func(cmd *command.Cmd) command.CmdErr {
+				return cmd.Error("Gah!").WithCode(42)
+			},
+			wantCode:  42,
+			wantError: "foo: Gah!\n\n",
+		},
+		{
+			name: "cause only",
+			mkErr: func(cmd *command.Cmd) command.CmdErr {
+				return command.CmdErr{Cmd: cmd, Code: 3, Cause: errors.New("Gah!")}
+			},
+			wantCode:  3,
+			wantError: "Gah!\n\n",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("TestCLIRichError(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				c, mocks := newCLIAndMocks(g, multipleCmds)
+				defer mocks.finish()
+
+				fooCmd := c.command("foo")
+
+				mocks.os.EXPECT().Args().Return(append(append([]string{c.name}, tc.args...), "foo"))
+				mocks.flagsFromEnv.EXPECT().Fill().Return(nil)
+				mocks.cmdFooFlagsFromEnv.EXPECT().Fill().Return(nil)
+				mocks.fooRunner.EXPECT().Run(fooCmd, []string{}).Return(tc.mkErr(fooCmd))
+				mocks.os.EXPECT().Exit(tc.wantCode)
+
+				c.Main()
+
+				assert.Equal(g, mocks.stderr.String(), tc.wantError)
+			},
+		)
+	}
+}
+
+func TestCLIErrorFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		args              []string
+		env               string
+		cmdType           cmdType
+		runnerCalled      bool
+		usageGlobalCalled bool
+		wantCode          command.CmdErrCode
+		wantError         string
+	}{
+		{
+			name:         "runner error",
+			args:         []string{"-error-format=json", "foo"},
+			cmdType:      multipleCmds,
+			runnerCalled: true,
+			wantCode:     command.CmdErrCodeError,
+			wantError:    `{"command":"foo","code":1,"message":"foo: Gah!"}` + "\n",
+		},
+		{
+			name:      "global bad input",
+			args:      []string{"-error-format=json", "-nope"},
+			cmdType:   multipleCmds,
+			wantCode:  command.CmdErrCodeBadInput,
+			wantError: `{"code":2,"message":"flag provided but not defined: -nope"}` + "\n",
+		},
+		{
+			name:      "global bad input from env",
+			args:      []string{"-nope"},
+			env:       "json",
+			cmdType:   multipleCmds,
+			wantCode:  command.CmdErrCodeBadInput,
+			wantError: `{"code":2,"message":"flag provided but not defined: -nope"}` + "\n",
+		},
+		{
+			name:              "flag overrides env",
+			args:              []string{"-error-format=text", "-nope"},
+			env:               "json",
+			cmdType:           multipleCmds,
+			usageGlobalCalled: true,
+			wantCode:          command.CmdErrCodeBadInput,
+			wantError:         "flag provided but not defined: -nope\n\n",
+		},
+		{
+			name:     "command bad input",
+			args:     []string{"-error-format=json", "-nope"},
+			cmdType:  noSubCmd,
+			wantCode: command.CmdErrCodeBadInput,
+			wantError: `{"command":"foo","code":2,` +
+				`"message":"foo: flag provided but not defined: -nope"}` + "\n",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("TestCLIErrorFormat(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				if tc.env != "" {
+					os.Setenv("BLAR_ERROR_FORMAT", tc.env)
+					defer os.Unsetenv("BLAR_ERROR_FORMAT")
+				}
+
+				c, mocks := newCLIAndMocks(g, tc.cmdType)
+				defer mocks.finish()
+
+				fooCmd := c.command("foo")
+
+				mocks.os.EXPECT().Args().Return(append([]string{c.name}, tc.args...))
+				if tc.runnerCalled {
+					mocks.flagsFromEnv.EXPECT().Fill().Return(nil)
+					mocks.cmdFooFlagsFromEnv.EXPECT().Fill().Return(nil)
+					mocks.fooRunner.EXPECT().Run(fooCmd, []string{}).Return(fooCmd.Error("Gah!"))
+				}
+				if tc.usageGlobalCalled {
+					mocks.usage.EXPECT().Global(c.commands, mocks.flagsFromEnv)
+				}
+				mocks.os.EXPECT().Exit(int(tc.wantCode))
+
+				c.Main()
+
+				assert.Equal(g, mocks.stderr.String(), tc.wantError)
+			},
+		)
+	}
+}
+
+func TestCLIRunErrorFormatWarnings(t *testing.T) {
+	var region string
+	push := &command.Cmd{
+		Name:       "push",
+		Deprecated: `use "deploy"`,
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			return cmd.Error("Gah!")
+		}),
+	}
+	push.Flags.StringVar(&region, "region", "", "")
+	command.AliasFlag(&push.Flags, "zone", "region")
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, push)
+
+	cmdErr, _, stderr := runCLI(c, []string{"push", "--zone=eu"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeError))
+	assert.True(t, strings.Contains(stderr, `warning: command push is deprecated: use "deploy"`))
+
+	// stderr holds only the JSON error
+	cmdErr, _, stderr = runCLI(
+		c,
+		[]string{"--error-format=json", "push", "--zone=eu"},
+		map[string]string{},
+		"",
+	)
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeError))
+	assert.Equal(t, stderr, `{"command":"push","code":1,"message":"push: Gah!"}`+"\n")
+
+	cmdErr, _, stderr = runCLI(c, []string{"push", "--zone=eu"}, map[string]string{"APP_ERROR_FORMAT": "json"}, "")
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeError))
+	assert.Equal(t, stderr, `{"command":"push","code":1,"message":"push: Gah!"}`+"\n")
+}
+
+func TestCLIRun(t *testing.T) {
+	c, mocks := newCLIAndMocks(t, multipleCmds)
+	defer mocks.finish()
+
+	var cliBarFlag, cmdBarFlag string
+	c.flags.StringVar(&cliBarFlag, "bar", "", "")
+	fooCmd := c.command("foo")
+	fooCmd.Flags.StringVar(&cmdBarFlag, "bar", "", "")
+
+	mocks.flagsFromEnv.EXPECT().Prefix().Return("BLAR_").AnyTimes()
+	mocks.cmdFooFlagsFromEnv.EXPECT().Prefix().Return("BLAR_FOO_").AnyTimes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stdin := strings.NewReader("input")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	mocks.fooRunner.EXPECT().Run(fooCmd, []string{"baz"}).DoAndReturn(
+		func(cmd *command.Cmd, args []string) command.CmdErr {
+			assert.Equal(t, cmd.Context(), ctx)
+			assert.Equal(t, cmd.Stdin(), stdin)
+			assert.Equal(t, cmd.Stdout(), stdout)
+			assert.Equal(t, cmd.Stderr(), stderr)
+			assert.Equal(t, cmd.ColorMode, style.Never)
+			return cmd.Error("Gah!")
+		},
+	)
+
+	cmdErr := c.Run(
+		ctx,
+		[]string{"foo", "baz"},
+		map[string]string{"BLAR_BAR": "a", "BLAR_FOO_BAR": "b"},
+		command.Stdio{Stdin: stdin, Stdout: stdout, Stderr: stderr},
+	)
+
+	assert.Equal(t, cmdErr, fooCmd.Error("Gah!"))
+	assert.Equal(t, cliBarFlag, "a")
+	assert.Equal(t, cmdBarFlag, "b")
+	assert.Equal(t, stderr.String(), "foo: Gah!\n\n")
+	assert.Equal(t, mocks.stderr.String(), "")
+
+	// the environment and streams are only used for the duration of Run
+	assert.Nil(t, c.env)
+	assert.Equal(t, c.flagsFromEnv, mocks.flagsFromEnv)
+	assert.Equal(t, c.cmdFlagsFromEnv["foo"], mocks.cmdFooFlagsFromEnv)
+}
+
+// runCLI runs the CLI with the given arguments, environment, and stdin,
+// returning the resulting CmdErr and the output written to stdout and
+// stderr.
+func runCLI(c CLI, args []string, env map[string]string, stdin string) (command.CmdErr, string, string) {
+	var stdout, stderr bytes.Buffer
+	cmdErr := c.Run(
+		context.Background(),
+		args,
+		env,
+		command.Stdio{Stdin: strings.NewReader(stdin), Stdout: &stdout, Stderr: &stderr},
+	)
+	return cmdErr, stdout.String(), stderr.String()
+}
+
+func TestCLIRunCanceled(t *testing.T) {
+	c, mocks := newCLIAndMocks(t, noSubCmd)
+	defer mocks.finish()
+
+	mocks.cmdFooFlagsFromEnv.EXPECT().Prefix().Return("BLAR_").AnyTimes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stderr := &bytes.Buffer{}
+	cmdErr := c.Run(ctx, []string{"baz"}, map[string]string{}, command.Stdio{Stderr: stderr})
+
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeError))
+	assert.True(t, errors.Is(cmdErr, context.Canceled))
+	assert.Equal(t, stderr.String(), "foo: context canceled\n\n")
+}
+
+func TestCLIRunFlagValidators(t *testing.T) {
+	var ran bool
+	deploy := &command.Cmd{
+		Name: "deploy",
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			ran = true
+			return command.NoError()
+		}),
+	}
+	deploy.Flags.Int("replicas", 1, "")
+	deploy.Flags.Int("port", 0, "")
+	deploy.Flags.String("name", "", usage.Required(""))
+	command.SetFlagOptions(&deploy.Flags, "replicas", command.WithValidators(command.Range(1, 10)))
+	command.SetFlagOptions(&deploy.Flags, "port", command.WithValidators(command.Range(1, 65535)))
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, deploy).(*cli)
+	c.flags.String("api-url", "", "")
+	c.flags.String("api-key", "", usage.Sensitive(""))
+	command.SetFlagOptions(&c.flags, "api-url", command.WithValidators(command.URL()))
+	command.SetFlagOptions(&c.flags, "api-key", command.WithValidators(command.Regexp(`k-.*`)))
+
+	for _, tc := range []struct {
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			args: []string{"deploy", "--name=n"},
+		},
+		{
+			args: []string{"--api-url=http://x", "--api-key=k-1", "deploy", "--name=n", "--replicas=10"},
+		},
+		{
+			args: []string{"--api-url=x", "--api-key=secret", "deploy", "--replicas=11"},
+			wantErr: `deploy: 
+  invalid value for global flag --api-key: must match k-.*
+  invalid value "x" for global flag --api-url: must be an absolute URL
+  --name is a required flag
+  invalid value "11" for flag --replicas: must be between 1 and 10`,
+		},
+		{
+			args:    []string{"deploy", "--name=n"},
+			env:     map[string]string{"APP_API_URL": "x", "APP_DEPLOY_REPLICAS": "0"},
+			wantErr: `invalid value "x" for global flag --api-url`,
+		},
+		{
+			args:    []string{"deploy", "--name=n"},
+			env:     map[string]string{"APP_DEPLOY_REPLICAS": "0"},
+			wantErr: `invalid value "0" for flag --replicas`,
+		},
+		{
+			args:    []string{"deploy", "--name=n", "--port=0"},
+			wantErr: `invalid value "0" for flag --port`,
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q, %v)", tc.args, tc.env),
+			t,
+			func(g *assert.G) {
+				ran = false
+				var stdout, stderr bytes.Buffer
+				cmdErr := c.Run(
+					context.Background(),
+					tc.args,
+					tc.env,
+					command.Stdio{Stdout: &stdout, Stderr: &stderr},
+				)
+				if tc.wantErr == "" {
+					assert.Equal(g, cmdErr, command.NoError())
+					assert.True(g, ran)
+				} else {
+					assert.Equal(g, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+					assert.True(g, strings.Contains(stderr.String(), tc.wantErr))
+					assert.False(g, strings.Contains(stderr.String(), "secret"))
+					assert.False(g, ran)
+				}
+			},
+		)
+	}
+}
+
+func TestCLIRunFlagAliases(t *testing.T) {
+	var region, timeout string
+	var legacy bool
+	runner := runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+		fmt.Fprintf(cmd.Stdout(), "%s %s\n", region, timeout)
+		return command.NoError()
+	})
+	deploy := &command.Cmd{Name: "deploy", Runner: runner}
+	deploy.Flags.StringVar(&region, "region", "us-east-1", usage.Required(""))
+	deploy.Flags.BoolVar(&legacy, "legacy", false, usage.Deprecated(""))
+	command.AliasFlag(&deploy.Flags, "zone", "region")
+	push := &command.Cmd{Name: "push", Runner: runner, Deprecated: `use "deploy"`}
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, deploy, push).(*cli)
+	c.flags.StringVar(&timeout, "timeout", "1m", "")
+	command.AliasFlag(&c.flags, "wait", "timeout")
+
+	const (
+		waitWarning = "warning: global flag --wait is deprecated, use --timeout\n"
+		zoneWarning = "warning: flag --zone is deprecated, use --region\n"
+
+		waitEnvWarning = "warning: environment variable APP_WAIT is deprecated, use APP_TIMEOUT\n"
+		zoneEnvWarning = "warning: environment variable APP_DEPLOY_ZONE is deprecated, use APP_DEPLOY_REGION\n"
+	)
+
+	for _, tc := range []struct {
+		args       []string
+		env        map[string]string
+		want       string
+		wantStderr string
+	}{
+		{
+			args: []string{"deploy", "--region=eu"},
+			want: "eu 1m\n",
+		},
+		{
+			args:       []string{"--wait=2m", "deploy", "--zone=eu"},
+			want:       "eu 2m\n",
+			wantStderr: waitWarning + zoneWarning,
+		},
+		{
+			args:       []string{"deploy", "--zone=eu", "--region=ap"},
+			want:       "ap 1m\n",
+			wantStderr: zoneWarning,
+		},
+		{
+			args:       []string{"deploy"},
+			env:        map[string]string{"APP_WAIT": "2m", "APP_DEPLOY_ZONE": "eu"},
+			want:       "eu 2m\n",
+			wantStderr: waitEnvWarning + zoneEnvWarning,
+		},
+		{
+			args:       []string{"--wait=2m", "deploy"},
+			env:        map[string]string{"APP_DEPLOY_ZONE": "eu"},
+			want:       "eu 2m\n",
+			wantStderr: waitWarning + zoneEnvWarning,
+		},
+		{
+			args:       []string{"--timeout=3m", "deploy"},
+			env:        map[string]string{"APP_WAIT": "2m", "APP_DEPLOY_ZONE": "eu", "APP_DEPLOY_REGION": "ap"},
+			want:       "ap 3m\n",
+			wantStderr: waitEnvWarning + zoneEnvWarning,
+		},
+		{
+			args:       []string{"deploy", "--region=eu", "--legacy"},
+			want:       "eu 1m\n",
+			wantStderr: "warning: flag --legacy is deprecated\n",
+		},
+		{
+			args:       []string{"push"},
+			want:       "us-east-1 1m\n",
+			wantStderr: `warning: command push is deprecated: use "deploy"` + "\n",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q, %v)", tc.args, tc.env),
+			t,
+			func(g *assert.G) {
+				var stdout, stderr bytes.Buffer
+				cmdErr := c.Run(
+					context.Background(),
+					tc.args,
+					tc.env,
+					command.Stdio{Stdout: &stdout, Stderr: &stderr},
+				)
+				assert.Equal(g, cmdErr, command.NoError())
+				assert.Equal(g, stdout.String(), tc.want)
+				assert.Equal(g, stderr.String(), tc.wantStderr)
+			},
+		)
+	}
+}
+
+func TestCLIRunBriefHelp(t *testing.T) {
+	deploy := &command.Cmd{Name: "deploy", Runner: runnerFunc(nil)}
+	deploy.Flags.String("region", "", "the region")
+	deploy.Flags.Int("retries", 3, "the retries")
+	command.SetFlagOptions(&deploy.Flags, "retries", command.WithAdvanced())
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, deploy).(*cli)
+	c.flags.String("trace", "", "the trace file")
+	command.SetFlagOptions(&c.flags, "trace", command.WithAdvanced())
+
+	for _, tc := range []struct {
+		args      []string
+		wantBrief bool
+	}{
+		{args: []string{"-h"}, wantBrief: true},
+		{args: []string{"--help"}},
+		{args: []string{"help"}},
+		{args: []string{"deploy", "-h"}, wantBrief: true},
+		{args: []string{"-h", "deploy"}, wantBrief: true},
+		{args: []string{"deploy", "--help"}},
+		{args: []string{"-h", "deploy", "--help"}},
+		{args: []string{"help", "deploy"}},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q)", tc.args),
+			t,
+			func(g *assert.G) {
+				var stdout bytes.Buffer
+				cmdErr := c.Run(context.Background(), tc.args, nil, command.Stdio{Stdout: &stdout})
+				assert.Equal(g, cmdErr, command.NoError())
+
+				out := stdout.String()
+				assert.True(g, strings.Contains(out, "--region") || len(tc.args) == 1)
+				assert.Equal(g, strings.Contains(out, "--trace"), !tc.wantBrief)
+				assert.Equal(g, strings.Contains(out, "ADVANCED OPTIONS"), !tc.wantBrief)
+				assert.Equal(g, strings.Contains(out, "Run with --help"), tc.wantBrief)
+			},
+		)
+	}
+}
+
+func TestResetFlagSet(t *testing.T) {
+	var (
+		fs  flag.FlagSet
+		s   string
+		n   int
+		set bool
+	)
+	fs.StringVar(&s, "s", "default", "a string")
+	fs.IntVar(&n, "n", 3, "an int")
+	fs.BoolVar(&set, "b", false, "a bool")
+
+	assert.Nil(t, fs.Parse([]string{"-s=x", "-n=5", "-b"}))
+	assert.Equal(t, fs.NFlag(), 3)
+
+	resetFlagSet(&fs)
+	assert.Equal(t, s, "default")
+	assert.Equal(t, n, 3)
+	assert.False(t, set)
+	assert.Equal(t, fs.NFlag(), 0)
+	assert.Equal(t, fs.Lookup("s").DefValue, "default")
+
+	assert.Nil(t, fs.Parse([]string{"-n=7"}))
+	assert.Equal(t, n, 7)
+	assert.Equal(t, fs.NFlag(), 1)
+}
+
+func mkMultiCallCLI(runner command.Runner) *cli {
+	ls := &command.Cmd{Name: "ls", Runner: runner}
+	cat := &command.Cmd{Name: "cat", Runner: runner}
+	c := mkNew(app.App{Name: "ls", HasSubCmds: true}, ls, cat).(*cli)
+	c.EnableMultiCall("box")
+	return c
+}
+
+func TestMultiCallArgs(t *testing.T) {
+	c := mkMultiCallCLI(nil)
+	assert.Equal(t, c.name, "box")
+	assert.Equal(t, c.app.Name, "box")
+	assert.Equal(t, c.Version().Describe(), c.app.Version().Describe())
+
+	for _, tc := range []struct {
+		executable string
+		args       []string
+		want       []string
+	}{
+		{"/bin/ls", []string{"-l"}, []string{"ls", "-l"}},
+		{"CAT", []string{"a"}, []string{"cat", "a"}},
+		{"/usr/local/bin/box", []string{"ls", "-l"}, []string{"ls", "-l"}},
+		{"box", []string{}, []string{}},
+		{"/bin/box-1.2", []string{"cat"}, []string{"cat"}},
+	} {
+		assert.Group(
+			fmt.Sprintf("multiCallArgs(%q, %q)", tc.executable, tc.args),
+			t,
+			func(g *assert.G) {
+				assert.DeepEqual(g, c.multiCallArgs(tc.executable, tc.args), tc.want)
+			},
+		)
+	}
+
+	c.multiCall = false
+	assert.DeepEqual(t, c.multiCallArgs("/bin/ls", []string{"-l"}), []string{"-l"})
+}
+
+func TestCLIMainMultiCall(t *testing.T) {
+	ctrl := gomock.NewController(assert.Tracing(t))
+	defer ctrl.Finish()
+
+	runner := command.NewMockRunner(ctrl)
+	c := mkMultiCallCLI(runner)
+
+	mockOS := tbnos.NewMockOS(ctrl)
+	mockOS.EXPECT().Stdin().Return(strings.NewReader("")).AnyTimes()
+	mockOS.EXPECT().Stdout().Return(&bytes.Buffer{}).AnyTimes()
+	mockOS.EXPECT().Stderr().Return(&bytes.Buffer{}).AnyTimes()
+	c.os = mockOS
+
+	gomock.InOrder(
+		mockOS.EXPECT().Args().Return([]string{"/bin/cat", "a", "b"}),
+		runner.EXPECT().Run(c.command("cat"), []string{"a", "b"}).Return(command.NoError()),
+		mockOS.EXPECT().Exit(0),
+	)
+	c.Main()
+
+	gomock.InOrder(
+		mockOS.EXPECT().Args().Return([]string{"/bin/box", "ls", "x"}),
+		runner.EXPECT().Run(c.command("ls"), []string{"x"}).Return(command.NoError()),
+		mockOS.EXPECT().Exit(0),
+	)
+	c.Main()
+}
+
+func TestValidateMultiCall(t *testing.T) {
+	assert.Nil(t, mkMultiCallCLI(nil).Validate())
+
+	c := mkNew(app.App{Name: "ls"}, &command.Cmd{Name: "ls"}).(*cli)
+	c.EnableMultiCall("box")
+	assert.ErrorContains(t, c.Validate(), "multi-call dispatch requires sub-commands")
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The clitest package provides a harness for testing a cli.CLI in-process:
+// a CLI is run with given arguments, environment and standard input, and
+// its standard output, standard error and exit code are captured. Runners
+// must write to command.Cmd.Stdout and command.Cmd.Stderr, rather than
+// directly to os.Stdout and os.Stderr, for their output to be captured.
+// Runs do not consult the process environment.
+package clitest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli"
+	"github.com/turbinelabs/cli/command"
+)
+
+// UpdateEnvKey is the environment variable which, if set to a non-empty
+// value, initializes Update to true.
+const UpdateEnvKey = "CLITEST_UPDATE_GOLDEN"
+
+// Update, if true, causes AssertGolden to write golden files rather than
+// compare against them.
+var Update = os.Getenv(UpdateEnvKey) != ""
+
+// An Invocation describes a single run of a CLI.
+type Invocation struct {
+	Args  []string          // Command-line arguments, not including the executable name
+	Env   map[string]string // The complete environment; the process environment is not consulted
+	Stdin string            // Contents of standard input
+}
+
+// A Result holds the output and exit code of an Invocation.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Run runs the given CLI with the given Invocation, and returns the
+// Result.
+func Run(c cli.CLI, inv Invocation) Result {
+	env := inv.Env
+	if env == nil {
+		env = map[string]string{}
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmdErr := c.Run(
+		context.Background(),
+		inv.Args,
+		env,
+		command.Stdio{
+			Stdin:  strings.NewReader(inv.Stdin),
+			Stdout: stdout,
+			Stderr: stderr,
+		},
+	)
+
+	return Result{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: int(cmdErr.Code),
+	}
+}
+
+// Usage runs the given CLI with the --help flag, and returns the Result.
+// If a command name is given, the result contains the usage of that
+// command, otherwise it contains the global usage. Styling is disabled,
+// and usage is wrapped at 80 columns, so that the output is suitable for
+// comparison with a golden file.
+func Usage(c cli.CLI, command ...string) Result {
+	return Run(c, Invocation{
+		Args: append(command, "--help"),
+		Env:  map[string]string{"NO_COLOR": "1"},
+	})
+}
+
+// AssertGolden compares got with the contents of the golden file at the
+// given path, reporting an error if they differ. If Update is true, got is
+// instead written to the file, creating its parent directories as needed.
+// Returns true if got matched or the file was written.
+func AssertGolden(t testing.TB, path, got string) bool {
+	if Update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Errorf("creating golden file directory: %s", err)
+			return false
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Errorf("writing golden file: %s", err)
+			return false
+		}
+		return true
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Errorf("reading golden file (set %s=1 to create it): %s", UpdateEnvKey, err)
+		return false
+	}
+
+	if got != string(want) {
+		t.Errorf(
+			"output does not match golden file %s (set %s=1 to update it):\ngot:\n%s\nwant:\n%s",
+			path,
+			UpdateEnvKey,
+			got,
+			want,
+		)
+		return false
+	}
+
+	return true
+}
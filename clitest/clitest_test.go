@@ -0,0 +1,238 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clitest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/test/assert"
+)
+
+type echoRunner struct {
+	prefix string
+}
+
+func (r *echoRunner) Run(cmd *command.Cmd, args []string) command.CmdErr {
+	if len(args) == 0 {
+		return cmd.BadInput("nothing to echo")
+	}
+
+	if args[0] == "-" {
+		stdin, err := ioutil.ReadAll(cmd.Stdin())
+		if err != nil {
+			return cmd.Error(err)
+		}
+		args = strings.Fields(string(stdin))
+	}
+
+	fmt.Fprintln(cmd.Stdout(), r.prefix+strings.Join(args, " "))
+	return command.NoError()
+}
+
+func mkCLI() cli.CLI {
+	runner := &echoRunner{}
+	cmd := &command.Cmd{
+		Name:        "echo",
+		Summary:     "echo arguments",
+		Usage:       "[OPTIONS] <arg>...",
+		Description: "Echoes its arguments, or with a single - argument, its input.",
+		Runner:      runner,
+	}
+	cmd.Flags.StringVar(&runner.prefix, "prefix", "", "A `prefix` for the output")
+	command.AliasFlag(&cmd.Flags, "pre", "prefix")
+
+	return cli.NewWithSubCmds("an echoing CLI", "1.2.3", cmd)
+}
+
+func TestRun(t *testing.T) {
+	envPrefix := strings.ToUpper(strings.Replace(path.Base(os.Args[0]), ".", "_", -1))
+
+	for _, tc := range []struct {
+		name string
+		inv  Invocation
+		want Result
+	}{
+		{
+			name: "args",
+			inv:  Invocation{Args: []string{"echo", "-prefix=> ", "a", "b"}},
+			want: Result{Stdout: "> a b\n"},
+		},
+		{
+			name: "stdin",
+			inv:  Invocation{Args: []string{"echo", "-"}, Stdin: "c d\n"},
+			want: Result{Stdout: "c d\n"},
+		},
+		{
+			name: "env",
+			inv: Invocation{
+				Args: []string{"echo", "e"},
+				Env:  map[string]string{envPrefix + "_ECHO_PREFIX": "$ "},
+			},
+			want: Result{Stdout: "$ e\n"},
+		},
+		{
+			name: "error",
+			inv:  Invocation{Args: []string{"-error-format=json", "echo"}},
+			want: Result{
+				Stderr:   `{"command":"echo","code":2,"message":"echo: nothing to echo"}` + "\n",
+				ExitCode: 2,
+			},
+		},
+		{
+			name: "flag alias",
+			inv:  Invocation{Args: []string{"echo", "-pre=> ", "f"}},
+			want: Result{
+				Stdout: "> f\n",
+				Stderr: "warning: flag --pre is deprecated, use --prefix\n",
+			},
+		},
+		{
+			name: "version",
+			inv:  Invocation{Args: []string{"version"}},
+			want: Result{Stdout: mkCLI().Version().Describe() + "\n"},
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				assert.Equal(g, Run(mkCLI(), tc.inv), tc.want)
+			},
+		)
+	}
+}
+
+func TestRunIgnoresProcessEnv(t *testing.T) {
+	envPrefix := strings.ToUpper(strings.Replace(path.Base(os.Args[0]), ".", "_", -1))
+
+	key := envPrefix + "_ECHO_PREFIX"
+	os.Setenv(key, "process: ")
+	defer os.Unsetenv(key)
+
+	assert.Equal(
+		t,
+		Run(mkCLI(), Invocation{Args: []string{"echo", "x"}}),
+		Result{Stdout: "x\n"},
+	)
+}
+
+func TestRunDeprecationWarning(t *testing.T) {
+	cmd := &command.Cmd{Name: "shout", Runner: &echoRunner{}, Deprecated: `use "echo"`}
+	c := cli.NewWithSubCmds("an echoing CLI", "1.2.3", cmd)
+
+	assert.Equal(
+		t,
+		Run(c, Invocation{Args: []string{"shout", "a"}}),
+		Result{
+			Stdout: "a\n",
+			Stderr: `warning: command shout is deprecated: use "echo"` + "\n",
+		},
+	)
+}
+
+func TestUsage(t *testing.T) {
+	global := Usage(mkCLI())
+	assert.Equal(t, global.ExitCode, 0)
+	assert.Equal(t, global.Stderr, "")
+	assert.True(t, strings.Contains(global.Stdout, "an echoing CLI"))
+	assert.False(t, strings.Contains(global.Stdout, "\033["))
+
+	result := Usage(mkCLI(), "echo")
+	assert.Equal(t, result.ExitCode, 0)
+	assert.True(t, strings.Contains(result.Stdout, "--prefix=prefix"))
+}
+
+func TestAssertGolden(t *testing.T) {
+	result := Usage(mkCLI(), "echo")
+
+	// the executable name varies with the test binary
+	got := strings.Replace(result.Stdout, path.Base(os.Args[0]), "echo-cli", -1)
+	AssertGolden(t, filepath.Join("testdata", "echo_usage.golden"), got)
+}
+
+func TestAssertGoldenUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clitest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	golden := filepath.Join(dir, "sub", "out.golden")
+
+	Update = true
+	assert.True(t, AssertGolden(t, golden, "some output\n"))
+	Update = false
+
+	contents, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(contents), "some output\n")
+
+	assert.True(t, AssertGolden(t, golden, "some output\n"))
+
+	mockT := &testing.T{}
+	assert.False(t, AssertGolden(mockT, golden, "other output\n"))
+	assert.True(t, mockT.Failed())
+}
+
+func TestRunRepeated(t *testing.T) {
+	c := mkCLI()
+
+	assert.Equal(
+		t,
+		Run(c, Invocation{Args: []string{"echo", "-prefix=> ", "a"}}),
+		Result{Stdout: "> a\n"},
+	)
+	assert.Equal(t, Run(c, Invocation{Args: []string{"echo", "b"}}), Result{Stdout: "b\n"})
+
+	help := Run(c, Invocation{Args: []string{"echo", "--help"}})
+	assert.Equal(t, help.ExitCode, 0)
+	assert.True(t, strings.Contains(help.Stdout, "--prefix=prefix"))
+
+	assert.Equal(t, Run(c, Invocation{Args: []string{"echo", "c"}}), Result{Stdout: "c\n"})
+}
+
+func TestRunRepeatedFactory(t *testing.T) {
+	calls := 0
+	c := cli.NewWithSubCmdFactories(
+		"an echoing CLI",
+		"1.2.3",
+		func() *command.Cmd {
+			calls++
+			runner := &echoRunner{}
+			cmd := &command.Cmd{Name: "echo", Runner: runner}
+			cmd.Flags.StringVar(&runner.prefix, "prefix", "", "A `prefix` for the output")
+			return cmd
+		},
+	)
+	assert.Equal(t, calls, 1)
+
+	assert.Equal(
+		t,
+		Run(c, Invocation{Args: []string{"echo", "-prefix=> ", "a"}}),
+		Result{Stdout: "> a\n"},
+	)
+	assert.Equal(t, calls, 1)
+
+	assert.Equal(t, Run(c, Invocation{Args: []string{"echo", "b"}}), Result{Stdout: "b\n"})
+	assert.Equal(t, calls, 2)
+}
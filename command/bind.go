@@ -0,0 +1,298 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+)
+
+var flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
+
+// BindFlags defines a flag in the flag.FlagSet for each field of the
+// struct to which v points that has a flag tag, so that parsing flags sets
+// the fields:
+//
+//	type deployRunner struct {
+//		Region  string        `flag:"region" usage:"The region" default:"us-west-1"`
+//		Timeout time.Duration `flag:"timeout" usage:"How long to wait" default:"1m"`
+//		Hosts   []string      `flag:"host" usage:"A host to deploy to" required:"true"`
+//		Mode    string        `flag:"mode" usage:"The deploy mode" enum:"rolling,all-at-once"`
+//	}
+//
+// The tags are:
+//
+//	flag       the flag name; the field is ignored if absent or "-"
+//	usage      the flag usage
+//	default    the default value, parsed as by flag.Value.Set; otherwise
+//	           the field's value when BindFlags is called is the default
+//	required   if "true", the flag is marked as required
+//	sensitive  if "true", the flag is marked as sensitive
+//	env        the environment variable from which the flag is filled,
+//	           or "-" if it is not; see WithEnvKey and WithoutEnv
+//	enum       a comma-separated list of the permitted values
+//	hidden     if "true", the flag is omitted from usage; see WithHidden
+//	advanced   if "true", the flag is omitted from brief usage; see
+//	           WithAdvanced
+//	category   the heading under which the flag is listed in usage; see
+//	           WithCategory
+//	alias      a comma-separated list of former names for the flag; see
+//	           AliasFlag
+//
+// Fields may be strings, bools, ints, int64s, uints, uint64s, float64s,
+// time.Durations, types whose pointers implement flag.Value, or slices or
+// string-keyed maps of these; see NewSliceValue and NewMapValue. For
+// slices, enum restricts each element.
+//
+// A struct field defines flags for its own fields. If it has a flag tag,
+// their names are prefixed with the tag and a dash: for example, a Host
+// field tagged "host" within a field tagged "db" defines --db-host.
+//
+// Unexported fields cannot be set, so a flag tag on one is an error.
+func BindFlags(fs *flag.FlagSet, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("BindFlags requires a pointer to a struct, not %T", v)
+	}
+	return bindStruct(fs, rv.Elem(), "")
+}
+
+func bindStruct(fs *flag.FlagSet, sv reflect.Value, prefix string) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		// the exported fields of embedded structs are settable, even if
+		// their types are unexported
+		unexported := field.PkgPath != "" && !(field.Anonymous && field.Type.Kind() == reflect.Struct)
+
+		name, tagged := field.Tag.Lookup("flag")
+		if name == "-" || unexported && !tagged {
+			continue
+		}
+		if unexported {
+			return fmt.Errorf("field %s: flag tag on unexported field", field.Name)
+		}
+		if tagged && name == "" {
+			return fmt.Errorf("field %s: empty flag name", field.Name)
+		}
+
+		fv := sv.Field(i)
+		value, isValue := fieldFlagValue(fv)
+
+		if !isValue && field.Type.Kind() == reflect.Struct {
+			nestedPrefix := prefix
+			if tagged {
+				nestedPrefix = prefix + name + "-"
+			}
+			if err := bindStruct(fs, fv, nestedPrefix); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !tagged {
+			continue
+		}
+
+		if err := bindField(fs, field, fv, value, prefix, name); err != nil {
+			return fmt.Errorf("field %s: %s", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// fieldFlagValue returns the field as a flag.Value, if it is one.
+func fieldFlagValue(fv reflect.Value) (flag.Value, bool) {
+	if fv.Kind() == reflect.Ptr && fv.Type().Implements(flagValueType) {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return fv.Interface().(flag.Value), true
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(flagValueType) {
+		return fv.Addr().Interface().(flag.Value), true
+	}
+	return nil, false
+}
+
+func bindField(
+	fs *flag.FlagSet,
+	field reflect.StructField,
+	fv reflect.Value,
+	value flag.Value,
+	prefix, name string,
+) error {
+	name = prefix + name
+	if fs.Lookup(name) != nil {
+		return fmt.Errorf("flag redefined: %s", name)
+	}
+
+	u := usage.New(field.Tag.Get("usage"))
+	if field.Tag.Get("required") == "true" {
+		u = u.SetRequired()
+	}
+	if field.Tag.Get("sensitive") == "true" {
+		u = u.SetSensitive()
+	}
+
+	if value == nil {
+		var err error
+		if value, err = newFieldValue(fs, fv, name, u.String()); err != nil {
+			return err
+		}
+	} else {
+		fs.Var(value, name, u.String())
+	}
+	f := fs.Lookup(name)
+
+	if enum, ok := field.Tag.Lookup("enum"); ok {
+		f.Value = &enumValue{
+			wrappedValue: wrappedValue{f.Value},
+			allowed:      strings.Split(enum, ","),
+			slice:        fv.Kind() == reflect.Slice,
+		}
+	}
+
+	if def, ok := field.Tag.Lookup("default"); ok {
+		if err := f.Value.Set(def); err != nil {
+			return fmt.Errorf("invalid default %q: %s", def, err)
+		}
+		if rv, ok := value.(RepeatableValue); ok {
+			// the next value given replaces the default
+			rv.ResetDefault(f.Value.String())
+		}
+		f.DefValue = f.Value.String()
+	}
+
+	switch env := field.Tag.Get("env"); env {
+	case "":
+	case "-":
+		SetFlagOptions(fs, name, WithoutEnv())
+	default:
+		SetFlagOptions(fs, name, WithEnvKey(env))
+	}
+	if field.Tag.Get("hidden") == "true" {
+		SetFlagOptions(fs, name, WithHidden())
+	}
+	if field.Tag.Get("advanced") == "true" {
+		SetFlagOptions(fs, name, WithAdvanced())
+	}
+	if category := field.Tag.Get("category"); category != "" {
+		SetFlagOptions(fs, name, WithCategory(category))
+	}
+	if aliases := field.Tag.Get("alias"); aliases != "" {
+		for _, alias := range strings.Split(aliases, ",") {
+			alias = prefix + alias
+			if fs.Lookup(alias) != nil {
+				return fmt.Errorf("flag redefined: %s", alias)
+			}
+			AliasFlag(fs, alias, name)
+		}
+	}
+
+	return nil
+}
+
+// newFieldValue defines a flag for a field of a basic type, slice or map, and
+// returns its flag.Value. Basic types are defined as by the flag package,
+// so that usage describes their types as usual.
+func newFieldValue(fs *flag.FlagSet, fv reflect.Value, name, usage string) (flag.Value, error) {
+	switch p := fv.Addr().Interface().(type) {
+	case *string:
+		fs.StringVar(p, name, *p, usage)
+	case *bool:
+		fs.BoolVar(p, name, *p, usage)
+	case *int:
+		fs.IntVar(p, name, *p, usage)
+	case *int64:
+		fs.Int64Var(p, name, *p, usage)
+	case *uint:
+		fs.UintVar(p, name, *p, usage)
+	case *uint64:
+		fs.Uint64Var(p, name, *p, usage)
+	case *float64:
+		fs.Float64Var(p, name, *p, usage)
+	case *time.Duration:
+		fs.DurationVar(p, name, *p, usage)
+	default:
+		value, err := newRepeatableValue(fv)
+		if err != nil {
+			return nil, err
+		}
+		fs.Var(value, name, usage)
+	}
+
+	return fs.Lookup(name).Value, nil
+}
+
+// enumValue restricts a flag.Value, or each element of a slice flag, to a
+// set of permitted values.
+type enumValue struct {
+	wrappedValue
+	allowed []string
+	slice   bool
+}
+
+func (v *enumValue) Set(s string) error {
+	values := []string{s}
+	if v.slice {
+		values = strings.Split(s, ",")
+	}
+
+	for _, value := range values {
+		if !v.isAllowed(strings.TrimSpace(value)) {
+			return fmt.Errorf("invalid value %q: must be %s", value, v.ValidValuesDescription())
+		}
+	}
+	return v.Value.Set(s)
+}
+
+func (v *enumValue) isAllowed(s string) bool {
+	for _, allowed := range v.allowed {
+		if s == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidValuesDescription implements tbnflag.ConstrainedValue.
+func (v *enumValue) ValidValuesDescription() string {
+	return describeValues(v.allowed)
+}
+
+// describeValues quotes and lists the values: "a", "b", or "c".
+func describeValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	switch len(quoted) {
+	case 1:
+		return quoted[0]
+	case 2:
+		return quoted[0] + " or " + quoted[1]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + ", or " + quoted[len(quoted)-1]
+}
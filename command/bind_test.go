@@ -0,0 +1,315 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+	"github.com/turbinelabs/test/assert"
+)
+
+type ipValue struct {
+	ip net.IP
+}
+
+func (v *ipValue) String() string {
+	if v.ip == nil {
+		return ""
+	}
+	return v.ip.String()
+}
+
+func (v *ipValue) Set(s string) error {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return fmt.Errorf("invalid IP address %q", s)
+	}
+	v.ip = ip
+	return nil
+}
+
+type dbOptions struct {
+	Host     string `flag:"host" usage:"the database host" default:"localhost"`
+	Password string `flag:"password" usage:"the database password" sensitive:"true" env:"DB_PASSWORD"`
+}
+
+type logOptions struct {
+	Level string `flag:"log-level" usage:"the log level" enum:"debug,info,warn" default:"info" category:"Logging"`
+	Trace bool   `flag:"log-trace" usage:"log everything" advanced:"true" env:"-"`
+	Debug bool   `flag:"log-debug" usage:"log internals" hidden:"true"`
+}
+
+type bindTarget struct {
+	Name     string            `flag:"name" usage:"the name" required:"true"`
+	Verbose  bool              `flag:"verbose" usage:"be verbose"`
+	Count    int               `flag:"count" default:"3"`
+	Big      int64             `flag:"big"`
+	Size     uint              `flag:"size"`
+	BigSize  uint64            `flag:"big-size"`
+	Ratio    float64           `flag:"ratio" default:"0.5"`
+	Timeout  time.Duration     `flag:"timeout" default:"1m"`
+	Hosts    []string          `flag:"host" default:"a,b"`
+	Ports    []int             `flag:"port"`
+	Modes    []string          `flag:"mode" enum:"fast,safe"`
+	Addr     ipValue           `flag:"addr" default:"127.0.0.1"`
+	AddrPtr  *ipValue          `flag:"addr-ptr"`
+	Addrs    []ipValue         `flag:"addrs"`
+	Labels   map[string]string `flag:"label" default:"env=dev"`
+	DB       dbOptions         `flag:"db"`
+	Ignored  string            `flag:"-"`
+	Untagged string
+	logOptions
+
+	unexported string
+}
+
+func TestBindFlags(t *testing.T) {
+	var target bindTarget
+	target.Untagged = "x"
+
+	var fs flag.FlagSet
+	assert.Nil(t, BindFlags(&fs, &target))
+
+	names := []string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	assert.DeepEqual(t, names, []string{
+		"addr", "addr-ptr", "addrs", "big", "big-size", "count", "db-host", "db-password",
+		"host", "label", "log-debug", "log-level", "log-trace", "mode", "name", "port", "ratio", "size", "timeout", "verbose",
+	})
+
+	// defaults
+	assert.Equal(t, target.Count, 3)
+	assert.Equal(t, target.Ratio, 0.5)
+	assert.Equal(t, target.Timeout, time.Minute)
+	assert.DeepEqual(t, target.Hosts, []string{"a", "b"})
+	assert.DeepEqual(t, target.Labels, map[string]string{"env": "dev"})
+	assert.Equal(t, target.Addr.String(), "127.0.0.1")
+	assert.Equal(t, target.DB.Host, "localhost")
+	assert.Equal(t, target.Level, "info")
+	assert.Equal(t, fs.Lookup("host").DefValue, "a,b")
+	assert.Equal(t, fs.Lookup("timeout").DefValue, "1m0s")
+
+	// usage
+	assert.True(t, usage.New(fs.Lookup("name").Usage).IsRequired())
+	assert.True(t, usage.New(fs.Lookup("db-password").Usage).IsSensitive())
+	assert.False(t, usage.New(fs.Lookup("db-host").Usage).IsSensitive())
+	typeName, _ := flag.UnquoteUsage(&flag.Flag{Value: UnwrapValue(fs.Lookup("log-level").Value)})
+	assert.Equal(t, typeName, "string")
+
+	assert.Equal(t, FlagCategory(fs.Lookup("log-level")), "Logging")
+	assert.True(t, FlagAdvanced(fs.Lookup("log-trace")))
+	assert.True(t, FlagHidden(fs.Lookup("log-debug")))
+	assert.False(t, FlagHidden(fs.Lookup("log-level")))
+
+	// env
+	key, ok := EnvKeyOverride(fs.Lookup("db-password"))
+	assert.Equal(t, key, "DB_PASSWORD")
+	assert.True(t, ok)
+	_, ok = EnvKeyOverride(fs.Lookup("db-host"))
+	assert.False(t, ok)
+	_, ok = FlagEnvKey("APP_", fs.Lookup("log-trace"))
+	assert.False(t, ok)
+
+	fs.SetOutput(ioutil.Discard)
+	assert.Nil(t, fs.Parse([]string{
+		"--name=n",
+		"--verbose",
+		"--big=-5",
+		"--size=7",
+		"--big-size=8",
+		"--host=c",
+		"--host", "d,e",
+		"--port=80,443",
+		"--mode=safe",
+		"--addr-ptr=::1",
+		"--addrs=10.0.0.1,10.0.0.2",
+		"--label=team=ops",
+		"--label=tier=web",
+		"--db-host=db",
+		"--db-password=s3cr3t",
+		"--log-level=debug",
+		"arg",
+	}))
+
+	assert.Equal(t, target.Name, "n")
+	assert.True(t, target.Verbose)
+	assert.Equal(t, target.Big, int64(-5))
+	assert.Equal(t, target.Size, uint(7))
+	assert.Equal(t, target.BigSize, uint64(8))
+	assert.DeepEqual(t, target.Hosts, []string{"c", "d", "e"})
+	assert.DeepEqual(t, target.Ports, []int{80, 443})
+	assert.DeepEqual(t, target.Modes, []string{"safe"})
+	assert.Equal(t, target.AddrPtr.String(), "::1")
+	assert.Equal(t, fs.Lookup("addrs").Value.String(), "10.0.0.1,10.0.0.2")
+	assert.DeepEqual(t, target.Labels, map[string]string{"team": "ops", "tier": "web"})
+	assert.Equal(t, target.DB.Host, "db")
+	assert.Equal(t, target.DB.Password, "s3cr3t")
+	assert.Equal(t, target.Level, "debug")
+	assert.Equal(t, target.Untagged, "x")
+	assert.DeepEqual(t, fs.Args(), []string{"arg"})
+
+	// restoring defaults
+	for _, name := range []string{"host", "port", "log-level"} {
+		f := fs.Lookup(name)
+		assert.Nil(t, ResetValue(f.Value, f.DefValue))
+	}
+	assert.DeepEqual(t, target.Hosts, []string{"a", "b"})
+	assert.DeepEqual(t, target.Ports, []int{})
+	assert.Equal(t, target.Level, "info")
+	assert.Nil(t, fs.Set("host", "z"))
+	assert.DeepEqual(t, target.Hosts, []string{"z"})
+}
+
+func TestBindFlagsInvalidValues(t *testing.T) {
+	for _, tc := range []struct {
+		args    []string
+		wantErr string
+	}{
+		{args: []string{"--log-level=trace"}, wantErr: `invalid value "trace": must be "debug", "info", or "warn"`},
+		{args: []string{"--mode=safe,slow"}, wantErr: `invalid value "slow": must be "fast" or "safe"`},
+		{args: []string{"--port=http"}, wantErr: `invalid value "http" for flag -port`},
+		{args: []string{"--addrs=localhost"}, wantErr: `invalid IP address "localhost"`},
+	} {
+		assert.Group(
+			fmt.Sprintf("Parse(%q)", tc.args),
+			t,
+			func(g *assert.G) {
+				var fs flag.FlagSet
+				fs.SetOutput(ioutil.Discard)
+				assert.Nil(g, BindFlags(&fs, &bindTarget{}))
+				assert.ErrorContains(g, fs.Parse(tc.args), tc.wantErr)
+			},
+		)
+	}
+}
+
+func TestBindFlagsErrors(t *testing.T) {
+	var fs flag.FlagSet
+	fs.String("name", "", "")
+
+	for _, tc := range []struct {
+		target  interface{}
+		wantErr string
+	}{
+		{target: bindTarget{}, wantErr: "BindFlags requires a pointer to a struct, not command.bindTarget"},
+		{target: (*bindTarget)(nil), wantErr: "BindFlags requires a pointer to a struct"},
+		{target: &struct{ C chan int }{}, wantErr: ""},
+		{
+			target: &struct {
+				C chan int `flag:"c"`
+			}{},
+			wantErr: "field C: unsupported type chan int",
+		},
+		{
+			target: &struct {
+				C []chan int `flag:"c"`
+			}{},
+			wantErr: "field C: unsupported type []chan int",
+		},
+		{
+			target: &struct {
+				M map[int]string `flag:"m"`
+			}{},
+			wantErr: "field M: unsupported type map[int]string",
+		},
+		{
+			target: &struct {
+				N int `flag:""`
+			}{},
+			wantErr: "field N: empty flag name",
+		},
+		{
+			target: &struct {
+				n int `flag:"n"`
+			}{},
+			wantErr: "field n: flag tag on unexported field",
+		},
+		{
+			target: &struct {
+				n int `flag:"-"`
+			}{},
+			wantErr: "",
+		},
+		{
+			target: &struct {
+				N int `flag:"n" default:"x"`
+			}{},
+			wantErr: `field N: invalid default "x"`,
+		},
+		{
+			target: &struct {
+				N string `flag:"name"`
+			}{},
+			wantErr: "field N: flag redefined: name",
+		},
+		{
+			target: &struct {
+				N string `flag:"n" alias:"name"`
+			}{},
+			wantErr: "field N: flag redefined: name",
+		},
+		{
+			target: &struct {
+				DB struct {
+					Host string `flag:"host" alias:"hostname"`
+				} `flag:"db"`
+			}{},
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("BindFlags(%T)", tc.target),
+			t,
+			func(g *assert.G) {
+				fs := copyFlags(&fs)
+				err := BindFlags(fs, tc.target)
+				if tc.wantErr == "" {
+					assert.Nil(g, err)
+				} else {
+					assert.ErrorContains(g, err, tc.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func copyFlags(fs *flag.FlagSet) *flag.FlagSet {
+	fsCopy := &flag.FlagSet{}
+	fs.VisitAll(func(f *flag.Flag) {
+		fsCopy.Var(f.Value, f.Name, f.Usage)
+	})
+	return fsCopy
+}
+
+func TestEnumValueDescription(t *testing.T) {
+	v := &enumValue{allowed: []string{"a"}}
+	assert.Equal(t, v.ValidValuesDescription(), `"a"`)
+	v.allowed = []string{"a", "b"}
+	assert.Equal(t, v.ValidValuesDescription(), `"a" or "b"`)
+	assert.True(t, strings.HasSuffix(
+		(&enumValue{allowed: []string{"a", "b", "c"}}).ValidValuesDescription(),
+		`"b", or "c"`,
+	))
+}
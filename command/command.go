@@ -23,8 +23,13 @@ package command
 //go:generate mockgen -source $GOFILE -destination mock_$GOFILE -package $GOPACKAGE --write_package_comment=false
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+
+	"github.com/turbinelabs/cli/style"
 )
 
 // A Runner represents the executable code associated with a Cmd. Typically
@@ -37,14 +42,84 @@ type Runner interface {
 	Run(cmd *Cmd, args []string) CmdErr
 }
 
+// An Example illustrates a typical invocation of a Cmd.
+type Example struct {
+	CommandLine string // The complete command line, beginning with the executable name; rendered verbatim
+	Explanation string // What the example does
+}
+
+// Stdio holds the input and output streams available to a Runner. Nil
+// streams are replaced by the corresponding streams of the process.
+type Stdio struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
 // A Cmd represents a named sub-command for a command-line application.
 type Cmd struct {
 	Name        string       // Name of the Command and the string to use to invoke it
 	Summary     string       // One-sentence summary of what the Command does
 	Usage       string       // Usage options/arguments
 	Description string       // Detailed description of command
+	Examples    []Example    // Example invocations of the command
+	Category    string       // Heading under which the Cmd is listed in global usage; if empty, it is listed under COMMANDS
+	Hidden      bool         // If true, the Cmd is omitted from global usage, but may still be invoked
+	Deprecated  string       // If non-empty, the Cmd is deprecated; this message, which should name the replacement, is printed when it is invoked
 	Flags       flag.FlagSet // Set of flags associated with this Cmd, which typically configure the Runner
 	Runner      Runner       // The code to run when this Cmd is invoked
+	ColorMode   style.Mode   // Controls output styling; set from the --color flag before the Runner is invoked
+	Stdio       Stdio        // Input and output streams for the Runner; set by the CLI before the Runner is invoked
+
+	ctx context.Context
+}
+
+// Context returns the context.Context in which the Runner is invoked,
+// which is canceled if the caller of CLI.Run cancels it. If none has been
+// set, context.Background is returned.
+func (c *Cmd) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// SetContext sets the context.Context returned by Context. It is called by
+// the CLI before the Runner is invoked.
+func (c *Cmd) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// Stdin returns the input stream the Runner should read from.
+func (c *Cmd) Stdin() io.Reader {
+	if c.Stdio.Stdin == nil {
+		return os.Stdin
+	}
+	return c.Stdio.Stdin
+}
+
+// Stdout returns the output stream the Runner should write to. Runners
+// that write to Stdout, rather than directly to os.Stdout, may be tested
+// with the clitest package.
+func (c *Cmd) Stdout() io.Writer {
+	if c.Stdio.Stdout == nil {
+		return os.Stdout
+	}
+	return c.Stdio.Stdout
+}
+
+// Stderr returns the error stream the Runner should write to.
+func (c *Cmd) Stderr() io.Writer {
+	if c.Stdio.Stderr == nil {
+		return os.Stderr
+	}
+	return c.Stdio.Stderr
+}
+
+// Style returns a style.Style appropriate for output written by the Runner
+// to the given io.Writer, according to the Cmd's ColorMode.
+func (c *Cmd) Style(w io.Writer) style.Style {
+	return style.For(w, c.ColorMode)
 }
 
 // Run invokes the Runner associated with this Cmd, passing the args remaining
@@ -75,13 +150,30 @@ func (c *Cmd) Errorf(format string, args ...interface{}) CmdErr {
 // BadInput produces a Cmd-scoped CmdErr with an exit code of 2, based on the
 // given args, which are passed to fmt.Sprint.
 func (c *Cmd) BadInput(args ...interface{}) CmdErr {
-	return CmdErr{c, CmdErrCodeBadInput, fmt.Sprintf("%s: %s", c.Name, fmt.Sprint(args...))}
+	return CmdErr{Cmd: c, Code: CmdErrCodeBadInput, Message: fmt.Sprintf("%s: %s", c.Name, fmt.Sprint(args...))}
 }
 
 // Error produces a Cmd-scoped CmdErr with an exit code of 1, based on the
 // given args, which are passed to fmt.Sprint.
 func (c *Cmd) Error(args ...interface{}) CmdErr {
-	return CmdErr{c, CmdErrCodeError, fmt.Sprintf("%s: %s", c.Name, fmt.Sprint(args...))}
+	return CmdErr{Cmd: c, Code: CmdErrCodeError, Message: fmt.Sprintf("%s: %s", c.Name, fmt.Sprint(args...))}
+}
+
+// Wrap produces a Cmd-scoped CmdErr with an exit code of 1, wrapping the
+// given error, which is available via errors.Is and errors.As.
+func (c *Cmd) Wrap(cause error) CmdErr {
+	err := c.Error(cause)
+	err.Cause = cause
+	return err
+}
+
+// Wrapf produces a Cmd-scoped CmdErr with an exit code of 1, wrapping the
+// given error. The message is based on the given format string and args,
+// which are passed to fmt.Sprintf, followed by the error.
+func (c *Cmd) Wrapf(cause error, format string, args ...interface{}) CmdErr {
+	err := c.Errorf("%s: %s", fmt.Sprintf(format, args...), cause)
+	err.Cause = cause
+	return err
 }
 
 // CmdErrCode is the exit code for the application
@@ -96,13 +188,38 @@ const (
 
 	// CmdErrCodeBadInput is the CmdErrorCode returned for bad input
 	CmdErrCodeBadInput = 2 // Bad Input Error
+
+	// MaxExitCode is the largest CmdErrCode an application may define.
+	// Larger exit codes are reserved by shells.
+	MaxExitCode CmdErrCode = 125
 )
 
-// CmdErr represents the exit status of a Cmd.
+// An ExitCode describes the meaning of a CmdErrCode, for display in usage.
+type ExitCode struct {
+	Code        CmdErrCode
+	Description string
+}
+
+// DefaultExitCodes returns the ExitCodes for CmdErrCodeNoError,
+// CmdErrCodeError and CmdErrCodeBadInput.
+func DefaultExitCodes() []ExitCode {
+	return []ExitCode{
+		{CmdErrCodeNoError, "Success."},
+		{CmdErrCodeError, "An error occurred."},
+		{CmdErrCodeBadInput, "The command line or environment contained invalid input."},
+	}
+}
+
+// CmdErr represents the exit status of a Cmd. CmdErr implements error; if
+// it wraps an underlying error, that error is available via errors.Is and
+// errors.As.
 type CmdErr struct {
 	Cmd     *Cmd       // The Cmd that produced the exit status. Can be nil for global errors
 	Code    CmdErrCode // The exit code
 	Message string     // Additional information if the Code is non-zero
+	Cause   error      // The underlying error, if any
+	Hint    string     // An optional suggestion for resolving the error
+	DocURL  string     // An optional URL of documentation relevant to the error
 }
 
 // IsError returns true if the exit code is non-zero
@@ -110,7 +227,41 @@ func (err CmdErr) IsError() bool {
 	return err.Code != CmdErrCodeNoError
 }
 
-var cmdErrNoErr = CmdErr{nil, CmdErrCodeNoError, ""}
+// Error returns the Message, or if it is empty, the message of the Cause.
+func (err CmdErr) Error() string {
+	if err.Message == "" && err.Cause != nil {
+		return err.Cause.Error()
+	}
+	return err.Message
+}
+
+// Unwrap returns the Cause, if any.
+func (err CmdErr) Unwrap() error {
+	return err.Cause
+}
+
+// WithCode returns a copy of the CmdErr with the given exit code, which
+// is typically one registered with the CLI.
+func (err CmdErr) WithCode(code CmdErrCode) CmdErr {
+	err.Code = code
+	return err
+}
+
+// WithHint returns a copy of the CmdErr with the given hint, which is
+// displayed to the user along with the Message.
+func (err CmdErr) WithHint(hint string) CmdErr {
+	err.Hint = hint
+	return err
+}
+
+// WithDocURL returns a copy of the CmdErr with the given documentation
+// URL, which is displayed to the user along with the Message.
+func (err CmdErr) WithDocURL(url string) CmdErr {
+	err.DocURL = url
+	return err
+}
+
+var cmdErrNoErr = CmdErr{Cmd: nil, Code: CmdErrCodeNoError}
 
 // NoError returns the singleton unscoped CmdErr with an exit code of 0
 func NoError() CmdErr {
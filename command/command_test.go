@@ -17,9 +17,14 @@ limitations under the License.
 package command
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"flag"
+	"os"
 	"testing"
 
+	"github.com/turbinelabs/cli/style"
 	"github.com/turbinelabs/test/assert"
 )
 
@@ -41,12 +46,12 @@ func TestCmdRun(t *testing.T) {
 
 	cmd := Cmd{Name: "bar", Flags: fs, Runner: testRunner{"bar", []string{"foo"}, t}}
 	err := cmd.Run()
-	assert.Equal(t, err, CmdErr{&cmd, CmdErrCodeError, "bar: baz"})
+	assert.Equal(t, err, CmdErr{Cmd: &cmd, Code: CmdErrCodeError, Message: "bar: baz"})
 }
 
 func TestCmdBadInputf(t *testing.T) {
 	cmd := Cmd{Name: "bar"}
-	want := CmdErr{&cmd, CmdErrCodeBadInput, "bar: 1-2-3"}
+	want := CmdErr{Cmd: &cmd, Code: CmdErrCodeBadInput, Message: "bar: 1-2-3"}
 	got := cmd.BadInputf("%d-%d-%d", 1, 2, 3)
 	assert.Equal(t, got, want)
 	assert.True(t, got.IsError())
@@ -54,7 +59,7 @@ func TestCmdBadInputf(t *testing.T) {
 
 func TestCmdErrorf(t *testing.T) {
 	cmd := Cmd{Name: "bar"}
-	want := CmdErr{&cmd, CmdErrCodeError, "bar: 1-2-3"}
+	want := CmdErr{Cmd: &cmd, Code: CmdErrCodeError, Message: "bar: 1-2-3"}
 	got := cmd.Errorf("%d-%d-%d", 1, 2, 3)
 	assert.Equal(t, got, want)
 	assert.True(t, got.IsError())
@@ -62,7 +67,7 @@ func TestCmdErrorf(t *testing.T) {
 
 func TestCmdBadInput(t *testing.T) {
 	cmd := Cmd{Name: "bar"}
-	want := CmdErr{&cmd, CmdErrCodeBadInput, "bar: baz:1 2 3"}
+	want := CmdErr{Cmd: &cmd, Code: CmdErrCodeBadInput, Message: "bar: baz:1 2 3"}
 	got := cmd.BadInput("baz:", 1, 2, 3)
 	assert.Equal(t, got, want)
 	assert.True(t, got.IsError())
@@ -70,15 +75,99 @@ func TestCmdBadInput(t *testing.T) {
 
 func TestCmdError(t *testing.T) {
 	cmd := Cmd{Name: "bar"}
-	want := CmdErr{&cmd, CmdErrCodeError, "bar: baz:1 2 3"}
+	want := CmdErr{Cmd: &cmd, Code: CmdErrCodeError, Message: "bar: baz:1 2 3"}
 	got := cmd.Error("baz:", 1, 2, 3)
 	assert.Equal(t, got, want)
 	assert.True(t, got.IsError())
 }
 
 func TestNoError(t *testing.T) {
-	want := CmdErr{nil, CmdErrCodeNoError, ""}
+	want := CmdErr{Cmd: nil, Code: CmdErrCodeNoError, Message: ""}
 	got := NoError()
 	assert.Equal(t, got, want)
 	assert.False(t, NoError().IsError())
 }
+
+func TestCmdStyle(t *testing.T) {
+	cmd := Cmd{Name: "bar", ColorMode: style.Always}
+	assert.True(t, cmd.Style(&bytes.Buffer{}).Enabled())
+
+	cmd.ColorMode = style.Never
+	assert.False(t, cmd.Style(&bytes.Buffer{}).Enabled())
+}
+
+func TestCmdWrap(t *testing.T) {
+	cmd := Cmd{Name: "bar"}
+	cause := &os.PathError{Op: "open", Path: "/nope", Err: os.ErrNotExist}
+
+	got := cmd.Wrap(cause)
+	assert.Equal(t, got, CmdErr{
+		Cmd:     &cmd,
+		Code:    CmdErrCodeError,
+		Message: "bar: open /nope: file does not exist",
+		Cause:   cause,
+	})
+	assert.Equal(t, got.Error(), got.Message)
+	assert.True(t, errors.Is(got, os.ErrNotExist))
+
+	var pathErr *os.PathError
+	assert.True(t, errors.As(got, &pathErr))
+	assert.Equal(t, pathErr, cause)
+}
+
+func TestCmdWrapf(t *testing.T) {
+	cmd := Cmd{Name: "bar"}
+	cause := errors.New("boom")
+
+	got := cmd.Wrapf(cause, "reading %s", "config")
+	assert.Equal(t, got.Message, "bar: reading config: boom")
+	assert.Equal(t, got.Unwrap(), cause)
+	assert.True(t, errors.Is(got, cause))
+}
+
+func TestCmdErrWith(t *testing.T) {
+	cmd := Cmd{Name: "bar"}
+	orig := cmd.Error("baz")
+
+	got := orig.WithCode(7).WithHint("try harder").WithDocURL("http://example.com")
+	assert.Equal(t, got, CmdErr{
+		Cmd:     &cmd,
+		Code:    7,
+		Message: "bar: baz",
+		Hint:    "try harder",
+		DocURL:  "http://example.com",
+	})
+	assert.Equal(t, orig, CmdErr{Cmd: &cmd, Code: CmdErrCodeError, Message: "bar: baz"})
+}
+
+func TestCmdErrErrorFromCause(t *testing.T) {
+	err := CmdErr{Code: 3, Cause: errors.New("boom")}
+	assert.Equal(t, err.Error(), "boom")
+	assert.Equal(t, CmdErr{Code: 3}.Error(), "")
+}
+
+func TestCmdStdio(t *testing.T) {
+	cmd := Cmd{Name: "bar"}
+	assert.Equal(t, cmd.Stdin(), os.Stdin)
+	assert.Equal(t, cmd.Stdout(), os.Stdout)
+	assert.Equal(t, cmd.Stderr(), os.Stderr)
+
+	in := &bytes.Buffer{}
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	cmd.Stdio = Stdio{Stdin: in, Stdout: out, Stderr: errOut}
+	assert.Equal(t, cmd.Stdin(), in)
+	assert.Equal(t, cmd.Stdout(), out)
+	assert.Equal(t, cmd.Stderr(), errOut)
+}
+
+func TestCmdContext(t *testing.T) {
+	cmd := Cmd{Name: "bar"}
+	assert.Equal(t, cmd.Context(), context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cmd.SetContext(ctx)
+	assert.Equal(t, cmd.Context(), ctx)
+}
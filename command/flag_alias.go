@@ -0,0 +1,136 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+)
+
+// AliasFlag defines a flag named alias which sets the flag with the given
+// name, as for a flag which has been renamed. The alias is filled from its
+// own environment variable, unless the flag is set WithoutEnv, and is
+// omitted from usage. It takes effect once ApplyAliases is called, and
+// only if the flag itself was not set, so that the new name takes
+// precedence over the old. AliasFlag panics if the flag is not defined.
+func AliasFlag(fs *flag.FlagSet, alias, name string) {
+	f := fs.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("flag provided but not defined: -%s", name))
+	}
+
+	u := usage.New(fmt.Sprintf("Deprecated; use --%s.", name))
+	if usage.New(f.Usage).IsSensitive() {
+		u = u.SetSensitive()
+	}
+
+	fs.Var(
+		&optionsValue{
+			wrappedValue: wrappedValue{&aliasValue{wrappedValue: wrappedValue{f.Value}}},
+			options: flagOptions{
+				aliasOf: name,
+				noEnv:   options(f).noEnv,
+				hidden:  true,
+			},
+		},
+		alias,
+		u.String(),
+	)
+	fs.Lookup(alias).DefValue = f.DefValue
+}
+
+// FlagAliasOf returns the name of the flag which the flag sets, if it was
+// defined with AliasFlag.
+func FlagAliasOf(f *flag.Flag) (string, bool) {
+	name := options(f).aliasOf
+	return name, name != ""
+}
+
+// ApplyAliases sets each flag from the values given for its aliases since
+// ApplyAliases was last called, unless the flag itself has been set. It is
+// called after each step which may set flags, such as parsing or filling
+// flags from the environment.
+func ApplyAliases(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		av := findAliasValue(f.Value)
+		if err != nil || av == nil || len(av.pending) == 0 {
+			return
+		}
+
+		name, _ := FlagAliasOf(f)
+		pending := av.pending
+		av.pending = nil
+		if set[name] {
+			return
+		}
+
+		for _, value := range pending {
+			if setErr := fs.Set(name, value); setErr != nil {
+				// don't echo secrets
+				if usage.New(f.Usage).IsSensitive() {
+					err = fmt.Errorf("invalid value for flag -%s: %s", f.Name, setErr)
+				} else {
+					err = fmt.Errorf("invalid value %q for flag -%s: %s", value, f.Name, setErr)
+				}
+				return
+			}
+		}
+		set[name] = true
+	})
+
+	return err
+}
+
+func findAliasValue(v flag.Value) *aliasValue {
+	for {
+		if av, ok := v.(*aliasValue); ok {
+			return av
+		}
+		w, ok := v.(valueWrapper)
+		if !ok {
+			return nil
+		}
+		v = w.unwrap()
+	}
+}
+
+// aliasValue records the values given for an alias until ApplyAliases
+// sets them on the flag whose flag.Value it wraps, which otherwise
+// describes the alias.
+type aliasValue struct {
+	wrappedValue
+	pending []string
+}
+
+func (v *aliasValue) Set(s string) error {
+	v.pending = append(v.pending, s)
+	return nil
+}
+
+// ResetDefault discards the values given for the alias, leaving the
+// aliased flag to be reset on its own.
+func (v *aliasValue) ResetDefault(string) {
+	v.pending = nil
+}
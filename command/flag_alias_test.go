@@ -0,0 +1,145 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestAliasFlag(t *testing.T) {
+	var fs flag.FlagSet
+	fs.String("log-level", "info", "The log level.")
+	fs.String("password", "", usage.Sensitive("The password."))
+	fs.Bool("dry-run", false, "Do nothing.")
+	SetFlagOptions(&fs, "dry-run", WithoutEnv())
+	AliasFlag(&fs, "loglevel", "log-level")
+	AliasFlag(&fs, "passwd", "password")
+	AliasFlag(&fs, "dryrun", "dry-run")
+
+	f := fs.Lookup("loglevel")
+	name, ok := FlagAliasOf(f)
+	assert.Equal(t, name, "log-level")
+	assert.True(t, ok)
+	assert.True(t, FlagHidden(f))
+	assert.Equal(t, f.DefValue, "info")
+	key, ok := FlagEnvKey("APP_", f)
+	assert.Equal(t, key, "APP_LOGLEVEL")
+	assert.True(t, ok)
+
+	_, ok = FlagAliasOf(fs.Lookup("log-level"))
+	assert.False(t, ok)
+
+	assert.True(t, usage.New(fs.Lookup("passwd").Usage).IsSensitive())
+	assert.False(t, usage.New(f.Usage).IsSensitive())
+	_, ok = FlagEnvKey("APP_", fs.Lookup("dryrun"))
+	assert.False(t, ok)
+
+	// values are held until applied
+	assert.Nil(t, fs.Parse([]string{"--loglevel=debug", "--dryrun"}))
+	assert.Equal(t, fs.Lookup("log-level").Value.String(), "info")
+	assert.Nil(t, ApplyAliases(&fs))
+	assert.Equal(t, fs.Lookup("log-level").Value.String(), "debug")
+	assert.Equal(t, fs.Lookup("dry-run").Value.String(), "true")
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	assert.DeepEqual(t, set, map[string]bool{
+		"loglevel":  true,
+		"log-level": true,
+		"dryrun":    true,
+		"dry-run":   true,
+	})
+
+	defer func() {
+		assert.NonNil(t, recover())
+	}()
+	AliasFlag(&fs, "old", "undefined")
+}
+
+func TestApplyAliases(t *testing.T) {
+	for _, tc := range []struct {
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			args: []string{},
+			want: "[a]",
+		},
+		{
+			args: []string{"--old-host=b", "--old-host=c"},
+			want: "[b c]",
+		},
+		{
+			args: []string{"--old-host=b", "--host=c"},
+			want: "[c]",
+		},
+		{
+			args: []string{"--old-host=b", "--older-host=c"},
+			want: "[b]",
+		},
+		{
+			args:    []string{"--old-host=x"},
+			wantErr: `invalid value "x" for flag -old-host: invalid value "x": must be`,
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("ApplyAliases(%q)", tc.args),
+			t,
+			func(g *assert.G) {
+				type runner struct {
+					Hosts []string `flag:"host" default:"a" enum:"a,b,c" alias:"old-host,older-host"`
+				}
+				r := &runner{}
+				var fs flag.FlagSet
+				assert.Nil(g, BindFlags(&fs, r))
+				fs.SetOutput(ioutil.Discard)
+
+				assert.Nil(g, fs.Parse(tc.args))
+				err := ApplyAliases(&fs)
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+					return
+				}
+				assert.Nil(g, err)
+				assert.Equal(g, fmt.Sprint(r.Hosts), tc.want)
+
+				// applying again has no further effect
+				assert.Nil(g, ApplyAliases(&fs))
+				assert.Equal(g, fmt.Sprint(r.Hosts), tc.want)
+			},
+		)
+	}
+}
+
+func TestApplyAliasesAfterReset(t *testing.T) {
+	var fs flag.FlagSet
+	name := fs.String("name", "x", "The name.")
+	AliasFlag(&fs, "old-name", "name")
+
+	assert.Nil(t, fs.Set("old-name", "y"))
+	f := fs.Lookup("old-name")
+	assert.Nil(t, ResetValue(f.Value, f.DefValue))
+	assert.Nil(t, ApplyAliases(&fs))
+	assert.Equal(t, *name, "x")
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"fmt"
+
+	tbnflag "github.com/turbinelabs/nonstdlib/flag"
+)
+
+// A FlagOption configures a flag beyond what flag.FlagSet records. Options
+// are stored with the flag's flag.Value, so they survive copying the
+// flag.FlagSet, and apply equally to global and command flags.
+type FlagOption func(*flagOptions)
+
+type flagOptions struct {
+	envKey     string
+	noEnv      bool
+	validators []Validator
+	hidden     bool
+	advanced   bool
+	category   string
+	aliasOf    string
+}
+
+// WithEnvKey fills the flag from the given environment variable, in place
+// of the variable named for the application, command and flag.
+func WithEnvKey(key string) FlagOption {
+	return func(o *flagOptions) {
+		o.envKey = key
+	}
+}
+
+// WithoutEnv prevents the flag from being filled from the environment, as
+// for flags which only make sense on the command line.
+func WithoutEnv() FlagOption {
+	return func(o *flagOptions) {
+		o.noEnv = true
+	}
+}
+
+// WithHidden omits the flag from usage. It can still be set as usual, as
+// for flags which are deprecated or internal.
+func WithHidden() FlagOption {
+	return func(o *flagOptions) {
+		o.hidden = true
+	}
+}
+
+// WithAdvanced omits the flag from the brief usage shown for -h, though
+// not from the full usage shown for --help. Advanced flags without a
+// category are listed under Advanced.
+func WithAdvanced() FlagOption {
+	return func(o *flagOptions) {
+		o.advanced = true
+	}
+}
+
+// WithCategory lists the flag under the given heading in usage, for
+// example "Connection" or "Output", rather than with uncategorized flags.
+func WithCategory(category string) FlagOption {
+	return func(o *flagOptions) {
+		o.category = category
+	}
+}
+
+// SetFlagOptions applies the given FlagOptions to the named flag, which
+// must already be defined in the flag.FlagSet.
+func SetFlagOptions(fs *flag.FlagSet, name string, opts ...FlagOption) {
+	f := fs.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("flag provided but not defined: -%s", name))
+	}
+
+	ov := findOptionsValue(f.Value)
+	if ov == nil {
+		ov = &optionsValue{wrappedValue: wrappedValue{f.Value}}
+		f.Value = ov
+	}
+
+	for _, opt := range opts {
+		opt(&ov.options)
+	}
+}
+
+// EnvKeyOverride returns the environment variable set with WithEnvKey for
+// the flag, if any.
+func EnvKeyOverride(f *flag.Flag) (string, bool) {
+	o := options(f)
+	return o.envKey, o.envKey != ""
+}
+
+// FlagEnvKey returns the environment variable from which the flag is
+// filled: that set with WithEnvKey, or otherwise the flag's name, as by
+// tbnflag.EnvKey, with the given prefix. It returns false for flags set
+// WithoutEnv.
+func FlagEnvKey(prefix string, f *flag.Flag) (string, bool) {
+	o := options(f)
+	switch {
+	case o.noEnv:
+		return "", false
+	case o.envKey != "":
+		return o.envKey, true
+	}
+	return prefix + tbnflag.EnvKey(f.Name), true
+}
+
+// FlagHidden indicates whether the flag is omitted from usage, as set with
+// WithHidden.
+func FlagHidden(f *flag.Flag) bool {
+	return options(f).hidden
+}
+
+// FlagAdvanced indicates whether the flag is omitted from brief usage, as
+// set with WithAdvanced.
+func FlagAdvanced(f *flag.Flag) bool {
+	return options(f).advanced
+}
+
+// FlagCategory returns the heading under which the flag is listed in
+// usage, as set with WithCategory. Advanced flags without a category are
+// listed under AdvancedCategory. Uncategorized flags return "".
+func FlagCategory(f *flag.Flag) string {
+	o := options(f)
+	if o.category == "" && o.advanced {
+		return AdvancedCategory
+	}
+	return o.category
+}
+
+// AdvancedCategory is the category of advanced flags for which none is
+// given, listed after all others in usage.
+const AdvancedCategory = "Advanced"
+
+// UnwrapValue returns the flag.Value underlying any wrappers added by this
+// package, to record FlagOptions or constrain values. It allows the type
+// of a flag's value to be inspected, as by flag.UnquoteUsage.
+func UnwrapValue(v flag.Value) flag.Value {
+	for {
+		w, ok := v.(valueWrapper)
+		if !ok {
+			return v
+		}
+		v = w.unwrap()
+	}
+}
+
+// ResetValue restores the flag.Value to the given default value. Unlike
+// Set, which may append to values given earlier, as for repeatable flags,
+// the default value replaces the current value.
+func ResetValue(v flag.Value, defValue string) error {
+	if r, ok := v.(interface {
+		ResetDefault(string)
+	}); ok {
+		r.ResetDefault(defValue)
+		return nil
+	}
+	return v.Set(defValue)
+}
+
+func options(f *flag.Flag) flagOptions {
+	if ov := findOptionsValue(f.Value); ov != nil {
+		return ov.options
+	}
+	return flagOptions{}
+}
+
+func findOptionsValue(v flag.Value) *optionsValue {
+	for {
+		if ov, ok := v.(*optionsValue); ok {
+			return ov
+		}
+		w, ok := v.(valueWrapper)
+		if !ok {
+			return nil
+		}
+		v = w.unwrap()
+	}
+}
+
+type valueWrapper interface {
+	flag.Value
+	unwrap() flag.Value
+}
+
+// wrappedValue forwards the optional interfaces a flag.Value may
+// implement, which have no effect when the wrapped flag.Value does not
+// implement them.
+type wrappedValue struct {
+	flag.Value
+}
+
+func (v wrappedValue) unwrap() flag.Value {
+	return v.Value
+}
+
+// Get implements flag.Getter.
+func (v wrappedValue) Get() interface{} {
+	if g, ok := v.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+	return nil
+}
+
+// IsBoolFlag allows boolean flags to be given without a value.
+func (v wrappedValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+// ValidValuesDescription implements tbnflag.ConstrainedValue.
+func (v wrappedValue) ValidValuesDescription() string {
+	if cv, ok := v.Value.(interface {
+		ValidValuesDescription() string
+	}); ok {
+		return cv.ValidValuesDescription()
+	}
+	return ""
+}
+
+// ResetDefault allows ResetValue to restore the wrapped flag.Value.
+func (v wrappedValue) ResetDefault(defValue string) {
+	ResetValue(v.Value, defValue)
+}
+
+// optionsValue wraps a flag.Value to record its FlagOptions.
+type optionsValue struct {
+	wrappedValue
+	options flagOptions
+}
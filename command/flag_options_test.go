@@ -0,0 +1,127 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/turbinelabs/test/assert"
+)
+
+type plainValue struct {
+	s string
+}
+
+func (v *plainValue) String() string     { return v.s }
+func (v *plainValue) Set(s string) error { v.s = s; return nil }
+
+func TestSetFlagOptions(t *testing.T) {
+	var fs flag.FlagSet
+	str := fs.String("str", "x", "a string")
+	b := fs.Bool("bool", false, "a bool")
+	plain := &plainValue{}
+	fs.Var(plain, "plain", "a plain value")
+
+	SetFlagOptions(&fs, "str", WithEnvKey("STR"))
+	SetFlagOptions(&fs, "bool", WithEnvKey("BOOL"))
+	SetFlagOptions(&fs, "plain")
+	SetFlagOptions(&fs, "str", WithEnvKey("OTHER_STR"))
+
+	key, ok := EnvKeyOverride(fs.Lookup("str"))
+	assert.Equal(t, key, "OTHER_STR")
+	assert.True(t, ok)
+	_, ok = EnvKeyOverride(fs.Lookup("plain"))
+	assert.False(t, ok)
+
+	// wrapping is transparent to parsing and usage
+	assert.Nil(t, fs.Parse([]string{"--bool", "--str=y", "--plain=z"}))
+	assert.True(t, *b)
+	assert.Equal(t, *str, "y")
+	assert.Equal(t, plain.s, "z")
+
+	typeName, _ := flag.UnquoteUsage(&flag.Flag{Value: UnwrapValue(fs.Lookup("str").Value)})
+	assert.Equal(t, typeName, "string")
+	assert.Equal(t, fs.Lookup("str").Value.(flag.Getter).Get(), "y")
+	assert.Nil(t, fs.Lookup("plain").Value.(flag.Getter).Get())
+	assert.Equal(t, UnwrapValue(fs.Lookup("plain").Value), flag.Value(plain))
+
+	assert.Nil(t, ResetValue(fs.Lookup("str").Value, "x"))
+	assert.Equal(t, *str, "x")
+
+	defer func() {
+		assert.Equal(t, recover(), "flag provided but not defined: -missing")
+	}()
+	SetFlagOptions(&fs, "missing")
+}
+
+func TestFlagUsageOptions(t *testing.T) {
+	var fs flag.FlagSet
+	for _, name := range []string{"plain", "hidden", "advanced", "categorized", "both"} {
+		fs.String(name, "", "")
+	}
+
+	SetFlagOptions(&fs, "hidden", WithHidden())
+	SetFlagOptions(&fs, "advanced", WithAdvanced())
+	SetFlagOptions(&fs, "categorized", WithCategory("Output"))
+	SetFlagOptions(&fs, "both", WithCategory("Output"), WithAdvanced())
+
+	for _, tc := range []struct {
+		name     string
+		hidden   bool
+		advanced bool
+		category string
+	}{
+		{name: "plain"},
+		{name: "hidden", hidden: true},
+		{name: "advanced", advanced: true, category: AdvancedCategory},
+		{name: "categorized", category: "Output"},
+		{name: "both", advanced: true, category: "Output"},
+	} {
+		assert.Group(
+			tc.name,
+			t,
+			func(g *assert.G) {
+				f := fs.Lookup(tc.name)
+				assert.Equal(g, FlagHidden(f), tc.hidden)
+				assert.Equal(g, FlagAdvanced(f), tc.advanced)
+				assert.Equal(g, FlagCategory(f), tc.category)
+			},
+		)
+	}
+}
+
+func TestFlagEnvKey(t *testing.T) {
+	var fs flag.FlagSet
+	fs.String("log-level", "", "")
+	fs.String("password", "", "")
+	fs.String("dry-run", "", "")
+	SetFlagOptions(&fs, "password", WithEnvKey("DB_PASSWORD"))
+	SetFlagOptions(&fs, "dry-run", WithoutEnv())
+
+	key, ok := FlagEnvKey("APP_CMD_", fs.Lookup("log-level"))
+	assert.Equal(t, key, "APP_CMD_LOG_LEVEL")
+	assert.True(t, ok)
+
+	key, ok = FlagEnvKey("APP_CMD_", fs.Lookup("password"))
+	assert.Equal(t, key, "DB_PASSWORD")
+	assert.True(t, ok)
+
+	key, ok = FlagEnvKey("APP_CMD_", fs.Lookup("dry-run"))
+	assert.Equal(t, key, "")
+	assert.False(t, ok)
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A Validator checks the value of a flag after flags are parsed and filled
+// from the environment, so that Runners need not.
+type Validator interface {
+	// Validate returns an error if the value is not permitted.
+	Validate(value string) error
+
+	// Description describes the permitted values, as shown in usage: for
+	// example, "must be between 1 and 10".
+	Description() string
+}
+
+// WithValidators checks the flag's value with the given Validators. Empty
+// values, and the values of flags that are neither set nor defaulted to
+// anything but the zero value, are not checked; mark the flag as required
+// to disallow them. Each element of a
+// RepeatableValue is checked separately; for maps, the values are checked,
+// but not the keys.
+func WithValidators(validators ...Validator) FlagOption {
+	return func(o *flagOptions) {
+		o.validators = append(o.validators, validators...)
+	}
+}
+
+// FlagValidators returns the Validators set with WithValidators for the
+// flag, if any.
+func FlagValidators(f *flag.Flag) []Validator {
+	return options(f).validators
+}
+
+// ValidateFlag checks the flag's value with its Validators, returning the
+// first value which is not permitted, and the error describing why. A flag
+// which was not set, by the command line, environment, config file, or
+// profile, and whose default is the zero value for its type, is optional,
+// and is not checked.
+func ValidateFlag(f *flag.Flag, set bool) (string, error) {
+	validators := FlagValidators(f)
+	if len(validators) == 0 || !set && isZeroDefault(f) {
+		return "", nil
+	}
+
+	for _, value := range flagElems(UnwrapValue(f.Value)) {
+		if value == "" {
+			continue
+		}
+		for _, v := range validators {
+			if err := v.Validate(value); err != nil {
+				return value, err
+			}
+		}
+	}
+	return "", nil
+}
+
+// isZeroDefault reports whether the flag's default is the zero value for
+// the type of its flag.Value, as for flag.PrintDefaults.
+func isZeroDefault(f *flag.Flag) (zero bool) {
+	typ := reflect.TypeOf(UnwrapValue(f.Value))
+	var z reflect.Value
+	if typ.Kind() == reflect.Ptr {
+		z = reflect.New(typ.Elem())
+	} else {
+		z = reflect.Zero(typ)
+	}
+
+	// the String method of a zero flag.Value may panic
+	defer func() {
+		if recover() != nil {
+			zero = false
+		}
+	}()
+	return f.DefValue == z.Interface().(flag.Value).String()
+}
+
+// flagElems returns the value of a flag.Value, or each of the elements of
+// a RepeatableValue.
+func flagElems(value flag.Value) []string {
+	switch v := value.(type) {
+	case *sliceValue:
+		elems := make([]string, v.slice.Len())
+		for i := range elems {
+			elems[i] = formatElem(v.slice.Index(i))
+		}
+		return elems
+
+	case *mapValue:
+		elems := make([]string, 0, v.m.Len())
+		for _, key := range v.m.MapKeys() {
+			elems = append(elems, formatElem(v.m.MapIndex(key)))
+		}
+		return elems
+
+	case RepeatableValue:
+		return strings.Split(v.String(), ",")
+	}
+	return []string{value.String()}
+}
+
+// NewValidator returns a Validator which permits the values for which
+// valid returns true, and otherwise returns an error with the given
+// description.
+func NewValidator(description string, valid func(string) bool) Validator {
+	return validator{description: description, valid: valid}
+}
+
+type validator struct {
+	description string
+	valid       func(string) bool
+}
+
+func (v validator) Validate(value string) error {
+	if !v.valid(value) {
+		return errors.New(v.description)
+	}
+	return nil
+}
+
+func (v validator) Description() string {
+	return v.description
+}
+
+// Range permits numbers between min and max, inclusive.
+func Range(min, max float64) Validator {
+	return NewValidator(
+		fmt.Sprintf("must be between %v and %v", min, max),
+		func(s string) bool {
+			f, err := strconv.ParseFloat(s, 64)
+			return err == nil && f >= min && f <= max
+		},
+	)
+}
+
+// Regexp permits values which the regular expression matches in their
+// entirety, as if it were anchored at both ends. Regexp panics if the
+// expression cannot be parsed.
+func Regexp(expr string) Validator {
+	re := regexp.MustCompile(`^(?:` + expr + `)$`)
+	return NewValidator("must match "+expr, re.MatchString)
+}
+
+// OneOf permits only the given values.
+func OneOf(values ...string) Validator {
+	return NewValidator(
+		"must be "+describeValues(values),
+		func(s string) bool {
+			for _, value := range values {
+				if s == value {
+					return true
+				}
+			}
+			return false
+		},
+	)
+}
+
+// ExistingFile permits paths to existing files other than directories.
+func ExistingFile() Validator {
+	return NewValidator(
+		"must be an existing file",
+		func(s string) bool {
+			info, err := os.Stat(s)
+			return err == nil && !info.IsDir()
+		},
+	)
+}
+
+// ExistingDir permits paths to existing directories.
+func ExistingDir() Validator {
+	return NewValidator(
+		"must be an existing directory",
+		func(s string) bool {
+			info, err := os.Stat(s)
+			return err == nil && info.IsDir()
+		},
+	)
+}
+
+// URL permits absolute URLs, with a scheme and host.
+func URL() Validator {
+	return NewValidator(
+		"must be an absolute URL",
+		func(s string) bool {
+			u, err := url.Parse(s)
+			return err == nil && u.Scheme != "" && u.Host != ""
+		},
+	)
+}
+
+// HostPort permits host:port addresses with numeric ports, as accepted by
+// net.Dial. The host may be omitted, as for addresses to listen on.
+func HostPort() Validator {
+	return NewValidator(
+		"must be a host:port address",
+		func(s string) bool {
+			_, port, err := net.SplitHostPort(s)
+			if err != nil {
+				return false
+			}
+			_, err = strconv.ParseUint(port, 10, 16)
+			return err == nil
+		},
+	)
+}
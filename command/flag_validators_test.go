@@ -0,0 +1,206 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestValidators(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validators")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	assert.Nil(t, ioutil.WriteFile(file, nil, 0600))
+
+	for _, tc := range []struct {
+		validator   Validator
+		valid       []string
+		invalid     []string
+		description string
+	}{
+		{
+			validator:   Range(1, 65535),
+			valid:       []string{"1", "80", "65535", "1.5"},
+			invalid:     []string{"0", "65536", "-1", "http"},
+			description: "must be between 1 and 65535",
+		},
+		{
+			validator:   Range(0, 0.5),
+			valid:       []string{"0", "0.25"},
+			invalid:     []string{"0.75"},
+			description: "must be between 0 and 0.5",
+		},
+		{
+			validator:   Regexp(`^[a-z]+$`),
+			valid:       []string{"abc"},
+			invalid:     []string{"ABC", "a1"},
+			description: "must match ^[a-z]+$",
+		},
+		{
+			validator:   Regexp(`[a-z]+|[0-9]+`),
+			valid:       []string{"abc", "123"},
+			invalid:     []string{"ABCx", "abc1", "1a"},
+			description: "must match [a-z]+|[0-9]+",
+		},
+		{
+			validator:   OneOf("a", "b", "c"),
+			valid:       []string{"a", "c"},
+			invalid:     []string{"d", "A"},
+			description: `must be "a", "b", or "c"`,
+		},
+		{
+			validator:   ExistingFile(),
+			valid:       []string{file},
+			invalid:     []string{dir, filepath.Join(dir, "missing")},
+			description: "must be an existing file",
+		},
+		{
+			validator:   ExistingDir(),
+			valid:       []string{dir},
+			invalid:     []string{file, filepath.Join(dir, "missing")},
+			description: "must be an existing directory",
+		},
+		{
+			validator:   URL(),
+			valid:       []string{"https://example.com", "http://localhost:8080/api?x=y"},
+			invalid:     []string{"example.com", "/api", "http://", "%"},
+			description: "must be an absolute URL",
+		},
+		{
+			validator:   HostPort(),
+			valid:       []string{"localhost:80", ":8080", "[::1]:443", "10.0.0.1:0"},
+			invalid:     []string{"localhost", "localhost:http", "localhost:65536", "::1:80"},
+			description: "must be a host:port address",
+		},
+	} {
+		assert.Group(
+			tc.description,
+			t,
+			func(g *assert.G) {
+				assert.Equal(g, tc.validator.Description(), tc.description)
+				for _, value := range tc.valid {
+					assert.Nil(g, tc.validator.Validate(value))
+				}
+				for _, value := range tc.invalid {
+					assert.ErrorContains(g, tc.validator.Validate(value), tc.description)
+				}
+			},
+		)
+	}
+}
+
+func TestValidateFlag(t *testing.T) {
+	var fs flag.FlagSet
+	fs.Int("port", 80, "")
+	fs.String("url", "", "")
+	var hosts []string
+	SliceVar(&fs, &hosts, "host", "")
+	var limits map[string]int
+	MapVar(&fs, &limits, "limit", "")
+	fs.String("unchecked", "x", "")
+	fs.Int("admin-port", 0, "")
+
+	SetFlagOptions(&fs, "port", WithValidators(Range(1, 1024)))
+	SetFlagOptions(&fs, "admin-port", WithValidators(Range(1, 1024)))
+	SetFlagOptions(&fs, "url", WithValidators(URL()))
+	SetFlagOptions(&fs, "host", WithValidators(HostPort()))
+	SetFlagOptions(&fs, "limit", WithValidators(Range(0, 10)))
+	SetFlagOptions(&fs, "limit", WithValidators(OneOf("1", "2", "20")))
+
+	assert.Equal(t, len(FlagValidators(fs.Lookup("limit"))), 2)
+	assert.Equal(t, len(FlagValidators(fs.Lookup("unchecked"))), 0)
+
+	for _, tc := range []struct {
+		args      []string
+		name      string
+		wantValue string
+		wantErr   string
+	}{
+		{name: "port"},
+		{name: "url"},
+		{name: "host"},
+		{name: "limit"},
+		{name: "unchecked"},
+		{name: "admin-port"},
+		{
+			args:      []string{"--admin-port=0"},
+			name:      "admin-port",
+			wantValue: "0",
+			wantErr:   "must be between 1 and 1024",
+		},
+		{
+			args:      []string{"--port=8080"},
+			name:      "port",
+			wantValue: "8080",
+			wantErr:   "must be between 1 and 1024",
+		},
+		{
+			args: []string{"--host=a:1,b:2"},
+			name: "host",
+		},
+		{
+			args:      []string{"--host=a:1", "--host=b"},
+			name:      "host",
+			wantValue: "b",
+			wantErr:   "must be a host:port address",
+		},
+		{
+			args: []string{"--limit=x=1,y=2"},
+			name: "limit",
+		},
+		{
+			args:      []string{"--limit=x=20"},
+			name:      "limit",
+			wantValue: "20",
+			wantErr:   "must be between 0 and 10",
+		},
+		{
+			args:      []string{"--limit=x=3"},
+			name:      "limit",
+			wantValue: "3",
+			wantErr:   `must be "1", "2", or "20"`,
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("%s %q", tc.name, tc.args),
+			t,
+			func(g *assert.G) {
+				fs.VisitAll(func(f *flag.Flag) {
+					assert.Nil(g, ResetValue(f.Value, f.DefValue))
+				})
+				assert.Nil(g, fs.Parse(tc.args))
+
+				value, err := ValidateFlag(fs.Lookup(tc.name), len(tc.args) > 0)
+				assert.Equal(g, value, tc.wantValue)
+				if tc.wantErr == "" {
+					assert.Nil(g, err)
+				} else {
+					assert.ErrorContains(g, err, tc.wantErr)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// A RepeatableValue is a flag.Value which may be given more than once, each
+// value adding to those given before. Each value may also be a
+// comma-separated list, which allows repeatable flags to be configured by
+// a single environment variable. The first value given replaces the
+// default, rather than adding to it.
+type RepeatableValue interface {
+	flag.Getter
+
+	// ElemType returns the name of the type of each element, as shown in
+	// usage: for example, "string", or "key=duration" for maps.
+	ElemType() string
+
+	// ResetDefault replaces the value with the given comma-separated
+	// default, which the next value given will replace.
+	ResetDefault(string)
+}
+
+// NewSliceValue returns a RepeatableValue which sets the slice to which p
+// points. Elements may be strings, bools, ints, int64s, uints, uint64s,
+// float64s, time.Durations, or types whose pointers implement flag.Value.
+// The slice's contents when NewSliceValue is called are its default.
+// NewSliceValue panics if p is not a pointer to such a slice.
+func NewSliceValue(p interface{}) RepeatableValue {
+	rv := reflect.ValueOf(p)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		panic(fmt.Sprintf("NewSliceValue requires a pointer to a slice, not %T", p))
+	}
+	return mustRepeatableValue(rv.Elem())
+}
+
+// NewMapValue returns a RepeatableValue which sets the map to which p
+// points. Each value given is a key=value pair, or a comma-separated list
+// of them. Keys are strings, and values may be of any type supported by
+// NewSliceValue. The map's contents when NewMapValue is called are its
+// default. NewMapValue panics if p is not a pointer to such a map.
+func NewMapValue(p interface{}) RepeatableValue {
+	rv := reflect.ValueOf(p)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Map {
+		panic(fmt.Sprintf("NewMapValue requires a pointer to a map, not %T", p))
+	}
+	return mustRepeatableValue(rv.Elem())
+}
+
+// SliceVar defines a repeatable flag with the given name and usage, which
+// sets the slice to which p points, as described by NewSliceValue.
+func SliceVar(fs *flag.FlagSet, p interface{}, name, usage string) {
+	fs.Var(NewSliceValue(p), name, usage)
+}
+
+// MapVar defines a repeatable flag with the given name and usage, which
+// sets the map to which p points, as described by NewMapValue.
+func MapVar(fs *flag.FlagSet, p interface{}, name, usage string) {
+	fs.Var(NewMapValue(p), name, usage)
+}
+
+func mustRepeatableValue(v reflect.Value) RepeatableValue {
+	value, err := newRepeatableValue(v)
+	if err != nil {
+		panic(err.Error())
+	}
+	return value
+}
+
+// newRepeatableValue returns a RepeatableValue for the given settable
+// slice or map.
+func newRepeatableValue(v reflect.Value) (RepeatableValue, error) {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Slice && isElemSupported(t.Elem()):
+		return &sliceValue{slice: v}, nil
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		isElemSupported(t.Elem()):
+		return &mapValue{m: v}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func isElemSupported(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(flagValueType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64,
+		reflect.Uint, reflect.Uint64, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// elemTypeName names an element type as flag.UnquoteUsage names the
+// types of flags.
+func elemTypeName(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	if reflect.PtrTo(t).Implements(flagValueType) {
+		return "value"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint64:
+		return "uint"
+	case reflect.Float64:
+		return "float"
+	}
+	return t.Kind().String()
+}
+
+// sliceValue is a RepeatableValue which appends to a slice.
+type sliceValue struct {
+	slice   reflect.Value
+	changed bool
+}
+
+func (v *sliceValue) String() string {
+	if !v.slice.IsValid() {
+		return ""
+	}
+
+	strs := make([]string, v.slice.Len())
+	for i := range strs {
+		strs[i] = formatElem(v.slice.Index(i))
+	}
+	return strings.Join(strs, ",")
+}
+
+func (v *sliceValue) Set(s string) error {
+	if !v.changed {
+		v.slice.Set(reflect.MakeSlice(v.slice.Type(), 0, 0))
+		v.changed = true
+	}
+	if s == "" {
+		return nil
+	}
+
+	for _, str := range strings.Split(s, ",") {
+		elem := reflect.New(v.slice.Type().Elem())
+		if err := parseElem(elem, strings.TrimSpace(str)); err != nil {
+			return err
+		}
+		v.slice.Set(reflect.Append(v.slice, elem.Elem()))
+	}
+	return nil
+}
+
+func (v *sliceValue) Get() interface{} {
+	return v.slice.Interface()
+}
+
+func (v *sliceValue) ElemType() string {
+	return elemTypeName(v.slice.Type().Elem())
+}
+
+func (v *sliceValue) ResetDefault(defValue string) {
+	v.changed = false
+	v.Set(defValue)
+	v.changed = false
+}
+
+// mapValue is a RepeatableValue which adds key=value pairs to a map.
+type mapValue struct {
+	m       reflect.Value
+	changed bool
+}
+
+func (v *mapValue) String() string {
+	if !v.m.IsValid() {
+		return ""
+	}
+
+	strs := make([]string, 0, v.m.Len())
+	for _, key := range v.m.MapKeys() {
+		strs = append(strs, key.String()+"="+formatElem(v.m.MapIndex(key)))
+	}
+	sort.Strings(strs)
+	return strings.Join(strs, ",")
+}
+
+func (v *mapValue) Set(s string) error {
+	if !v.changed || v.m.IsNil() {
+		v.m.Set(reflect.MakeMap(v.m.Type()))
+		v.changed = true
+	}
+	if s == "" {
+		return nil
+	}
+
+	for _, str := range strings.Split(s, ",") {
+		str = strings.TrimSpace(str)
+		eq := strings.Index(str, "=")
+		if eq <= 0 {
+			return fmt.Errorf("expected key=value: %q", str)
+		}
+
+		elem := reflect.New(v.m.Type().Elem())
+		if err := parseElem(elem, str[eq+1:]); err != nil {
+			return err
+		}
+		key := reflect.New(v.m.Type().Key()).Elem()
+		key.SetString(str[:eq])
+		v.m.SetMapIndex(key, elem.Elem())
+	}
+	return nil
+}
+
+func (v *mapValue) Get() interface{} {
+	return v.m.Interface()
+}
+
+func (v *mapValue) ElemType() string {
+	return "key=" + elemTypeName(v.m.Type().Elem())
+}
+
+func (v *mapValue) ResetDefault(defValue string) {
+	v.changed = false
+	v.Set(defValue)
+	v.changed = false
+}
+
+// formatElem formats an element of a slice or map as parseElem parses it.
+func formatElem(elem reflect.Value) string {
+	if elem.CanAddr() {
+		if fv, ok := elem.Addr().Interface().(flag.Value); ok {
+			return fv.String()
+		}
+	} else if reflect.PtrTo(elem.Type()).Implements(flagValueType) {
+		// map elements are not addressable
+		p := reflect.New(elem.Type())
+		p.Elem().Set(elem)
+		return p.Interface().(flag.Value).String()
+	}
+	return fmt.Sprint(elem.Interface())
+}
+
+// parseElem parses a string into the value to which elem points.
+func parseElem(elem reflect.Value, s string) error {
+	if fv, ok := elem.Interface().(flag.Value); ok {
+		return fv.Set(s)
+	}
+
+	e := elem.Elem()
+	if e.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		e.SetInt(int64(d))
+		return nil
+	}
+
+	switch e.Kind() {
+	case reflect.String:
+		e.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		e.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, e.Type().Bits())
+		if err != nil {
+			return err
+		}
+		e.SetInt(i)
+	case reflect.Uint, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, e.Type().Bits())
+		if err != nil {
+			return err
+		}
+		e.SetUint(u)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		e.SetFloat(f)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestSliceValue(t *testing.T) {
+	for _, tc := range []struct {
+		p        interface{}
+		args     []string
+		want     interface{}
+		wantStr  string
+		elemType string
+	}{
+		{
+			p:        &[]string{"x"},
+			args:     []string{"--f=a", "--f", "b, c"},
+			want:     []string{"a", "b", "c"},
+			wantStr:  "a,b,c",
+			elemType: "string",
+		},
+		{
+			p:        &[]string{"x"},
+			want:     []string{"x"},
+			wantStr:  "x",
+			elemType: "string",
+		},
+		{
+			p:        &[]string{"x"},
+			args:     []string{"--f="},
+			want:     []string{},
+			elemType: "string",
+		},
+		{
+			p:        &[]int{},
+			args:     []string{"--f=1,0x10"},
+			want:     []int{1, 16},
+			wantStr:  "1,16",
+			elemType: "int",
+		},
+		{
+			p:        &[]bool{},
+			args:     []string{"--f=true", "--f=0"},
+			want:     []bool{true, false},
+			wantStr:  "true,false",
+			elemType: "bool",
+		},
+		{
+			p:        &[]float64{},
+			args:     []string{"--f=1.5"},
+			want:     []float64{1.5},
+			wantStr:  "1.5",
+			elemType: "float",
+		},
+		{
+			p:        &[]uint64{},
+			args:     []string{"--f=5"},
+			want:     []uint64{5},
+			wantStr:  "5",
+			elemType: "uint",
+		},
+		{
+			p:        &[]time.Duration{},
+			args:     []string{"--f=1s,2m"},
+			want:     []time.Duration{time.Second, 2 * time.Minute},
+			wantStr:  "1s,2m0s",
+			elemType: "duration",
+		},
+		{
+			p:        &[]ipValue{},
+			args:     []string{"--f=::1"},
+			wantStr:  "::1",
+			elemType: "value",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("%T %q", tc.p, tc.args),
+			t,
+			func(g *assert.G) {
+				var fs flag.FlagSet
+				fs.SetOutput(ioutil.Discard)
+				SliceVar(&fs, tc.p, "f", "usage")
+				assert.Nil(g, fs.Parse(tc.args))
+
+				value := fs.Lookup("f").Value.(RepeatableValue)
+				if tc.want != nil {
+					assert.DeepEqual(g, value.Get(), tc.want)
+				}
+				assert.Equal(g, value.String(), tc.wantStr)
+				assert.Equal(g, value.ElemType(), tc.elemType)
+			},
+		)
+	}
+}
+
+func TestMapValue(t *testing.T) {
+	labels := map[string]string{"team": "ops"}
+	var fs flag.FlagSet
+	fs.SetOutput(ioutil.Discard)
+	MapVar(&fs, &labels, "label", "usage")
+
+	f := fs.Lookup("label")
+	assert.Equal(t, f.DefValue, "team=ops")
+	assert.Equal(t, f.Value.(RepeatableValue).ElemType(), "key=string")
+
+	assert.Nil(t, fs.Parse([]string{"--label=env=dev", "--label", "tier=web, url=http://x/?a=b"}))
+	assert.DeepEqual(t, labels, map[string]string{"env": "dev", "tier": "web", "url": "http://x/?a=b"})
+	assert.Equal(t, f.Value.String(), "env=dev,tier=web,url=http://x/?a=b")
+
+	assert.Nil(t, ResetValue(f.Value, f.DefValue))
+	assert.DeepEqual(t, labels, map[string]string{"team": "ops"})
+	assert.Nil(t, f.Value.Set("env=prod"))
+	assert.DeepEqual(t, labels, map[string]string{"env": "prod"})
+
+	var limits map[string]time.Duration
+	value := NewMapValue(&limits)
+	assert.Equal(t, value.String(), "")
+	assert.Equal(t, value.ElemType(), "key=duration")
+	assert.Nil(t, value.Set("read=1s,write=2m"))
+	assert.DeepEqual(t, limits, map[string]time.Duration{"read": time.Second, "write": 2 * time.Minute})
+	assert.Equal(t, value.String(), "read=1s,write=2m0s")
+
+	var addrs map[string]ipValue
+	value = NewMapValue(&addrs)
+	assert.Nil(t, value.Set("db=10.0.0.1"))
+	assert.Equal(t, value.String(), "db=10.0.0.1")
+
+	for _, s := range []string{"env", "=dev", "a=1,b"} {
+		assert.ErrorContains(t, NewMapValue(&labels).Set(s), "expected key=value")
+	}
+	assert.ErrorContains(t, value.Set("db=localhost"), `invalid IP address "localhost"`)
+}
+
+func TestRepeatableValuePanics(t *testing.T) {
+	for _, tc := range []struct {
+		f         func()
+		wantPanic string
+	}{
+		{
+			f:         func() { NewSliceValue([]string{}) },
+			wantPanic: "NewSliceValue requires a pointer to a slice, not []string",
+		},
+		{
+			f:         func() { NewSliceValue(&map[string]string{}) },
+			wantPanic: "NewSliceValue requires a pointer to a slice, not *map[string]string",
+		},
+		{
+			f:         func() { NewSliceValue(&[]chan int{}) },
+			wantPanic: "unsupported type []chan int",
+		},
+		{
+			f:         func() { NewMapValue(&[]string{}) },
+			wantPanic: "NewMapValue requires a pointer to a map, not *[]string",
+		},
+		{
+			f:         func() { NewMapValue(&map[int]string{}) },
+			wantPanic: "unsupported type map[int]string",
+		},
+	} {
+		assert.Group(
+			tc.wantPanic,
+			t,
+			func(g *assert.G) {
+				defer func() {
+					assert.Equal(g, recover(), tc.wantPanic)
+				}()
+				tc.f()
+			},
+		)
+	}
+}
@@ -0,0 +1,348 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The config package reads and writes INI-style configuration files, as
+// used for per-user CLI settings:
+//
+//	# the profile used when none is specified
+//	profile = staging
+//
+//	[profile staging]
+//	api-key = s3cr3t
+//	deploy.region = "us-west-1"
+//
+// Keys preceding the first section header belong to the unnamed section.
+// Lines beginning with # or ; are comments. Values extend to the end of
+// the line, and are trimmed of surrounding whitespace; a value may be
+// double-quoted, with Go escape sequences, to preserve whitespace. Files
+// modified with Set or Unset retain their comments and layout when
+// written.
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// File is a parsed configuration file. The zero value is an empty file.
+type File struct {
+	lines []line
+}
+
+type line struct {
+	text    string // the unparsed text, for comments, blank lines and headers
+	section string // the section to which the line belongs
+	key     string // empty unless the line assigns a value
+	value   string
+	header  bool
+}
+
+// DefaultPath returns the default path of the configuration file for the
+// named application: config in a directory named for the application in
+// $XDG_CONFIG_HOME, or ~/.config if unset. On Windows, %APPDATA% is used.
+func DefaultPath(appName string) string {
+	return DefaultPathInEnv(appName, os.LookupEnv)
+}
+
+// DefaultPathInEnv is like DefaultPath, but looks up environment variables
+// with the given function rather than in the process environment. It
+// returns "" if neither the configuration directory nor the home directory
+// is set.
+func DefaultPathInEnv(appName string, lookupEnv func(string) (string, bool)) string {
+	getenv := func(key string) string {
+		value, _ := lookupEnv(key)
+		return value
+	}
+
+	dir := getenv("XDG_CONFIG_HOME")
+	if runtime.GOOS == "windows" {
+		dir = getenv("APPDATA")
+	}
+	if dir == "" {
+		home := getenv("HOME")
+		if runtime.GOOS == "windows" {
+			home = getenv("USERPROFILE")
+		}
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, appName, "config")
+}
+
+// Parse reads a configuration file from the given io.Reader.
+func Parse(r io.Reader) (*File, error) {
+	f := &File{}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';':
+			f.lines = append(f.lines, line{text: text, section: section})
+
+		case trimmed[0] == '[':
+			if !strings.HasSuffix(trimmed, "]") {
+				return nil, fmt.Errorf("line %d: expected ] at end of section header", n)
+			}
+			section = normalizeSection(trimmed[1 : len(trimmed)-1])
+			if section == "" {
+				return nil, fmt.Errorf("line %d: empty section name", n)
+			}
+			f.lines = append(f.lines, line{text: text, section: section, header: true})
+
+		default:
+			eq := strings.Index(trimmed, "=")
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected = after %s", n, trimmed)
+			}
+
+			key := strings.TrimSpace(trimmed[:eq])
+			if key == "" {
+				return nil, fmt.Errorf("line %d: missing key before =", n)
+			}
+
+			value := strings.TrimSpace(trimmed[eq+1:])
+			if strings.HasPrefix(value, `"`) {
+				unquoted, err := strconv.Unquote(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid quoted value for %s", n, key)
+				}
+				value = unquoted
+			}
+
+			f.lines = append(
+				f.lines,
+				line{text: text, section: section, key: key, value: value},
+			)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Read parses the configuration file at the given path, as by Parse. A
+// missing file is treated as empty.
+func Read(path string) (*File, error) {
+	r, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	f, err := Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return f, nil
+}
+
+// Write writes the file to the given path, creating its directory if
+// necessary. Since configuration files may contain secrets, they are
+// readable only by their owner.
+func (f *File) Write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// WriteTo writes the file to the given io.Writer.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, l := range f.lines {
+		n, err := fmt.Fprintln(w, l.text)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Sections returns the names of the file's named sections, in order.
+func (f *File) Sections() []string {
+	sections := []string{}
+	seen := map[string]bool{}
+	for _, l := range f.lines {
+		if l.header && !seen[l.section] {
+			seen[l.section] = true
+			sections = append(sections, l.section)
+		}
+	}
+	return sections
+}
+
+// HasSection indicates whether the file contains the named section.
+func (f *File) HasSection(section string) bool {
+	section = normalizeSection(section)
+	for _, l := range f.lines {
+		if l.header && l.section == section {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys returns the keys assigned in the named section, in order. If a key
+// is assigned more than once, it is returned once.
+func (f *File) Keys(section string) []string {
+	section = normalizeSection(section)
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, l := range f.lines {
+		if l.key != "" && l.section == section && !seen[l.key] {
+			seen[l.key] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Get returns the value of the given key in the named section. If the key
+// is assigned more than once, the last assignment wins.
+func (f *File) Get(section, key string) (string, bool) {
+	section = normalizeSection(section)
+	value, found := "", false
+	for _, l := range f.lines {
+		if l.key == key && l.section == section {
+			value, found = l.value, true
+		}
+	}
+	return value, found
+}
+
+// GetAll returns the values of every assignment of the given key in the
+// named section, in order, as for repeatable flags.
+func (f *File) GetAll(section, key string) []string {
+	section = normalizeSection(section)
+	values := []string{}
+	for _, l := range f.lines {
+		if l.key == key && l.section == section {
+			values = append(values, l.value)
+		}
+	}
+	return values
+}
+
+// Set assigns the value of the given key in the named section, replacing
+// its last assignment, if any, or otherwise adding it as by Add.
+func (f *File) Set(section, key, value string) {
+	section = normalizeSection(section)
+	for i := len(f.lines) - 1; i >= 0; i-- {
+		if l := f.lines[i]; l.key == key && l.section == section {
+			f.lines[i] = assignment(section, key, value)
+			return
+		}
+	}
+	f.Add(section, key, value)
+}
+
+// Add adds an assignment of the given key in the named section after the
+// last assignment in the section, keeping any earlier assignments of the
+// key, as for repeatable flags. A missing section is added to the end of
+// the file.
+func (f *File) Add(section, key, value string) {
+	section = normalizeSection(section)
+	a := assignment(section, key, value)
+
+	end := -1
+	for i, l := range f.lines {
+		if l.section == section && (l.key != "" || l.header) {
+			end = i
+		}
+	}
+
+	if end < 0 && section != "" {
+		if len(f.lines) > 0 && strings.TrimSpace(f.lines[len(f.lines)-1].text) != "" {
+			f.lines = append(f.lines, line{section: f.lines[len(f.lines)-1].section})
+		}
+		header := line{text: fmt.Sprintf("[%s]", section), section: section, header: true}
+		f.lines = append(f.lines, header, a)
+		return
+	}
+
+	f.lines = append(f.lines, line{})
+	copy(f.lines[end+2:], f.lines[end+1:])
+	f.lines[end+1] = a
+}
+
+func assignment(section, key, value string) line {
+	return line{
+		text:    fmt.Sprintf("%s = %s", key, quote(value)),
+		section: section,
+		key:     key,
+		value:   value,
+	}
+}
+
+// Unset removes all assignments of the given key in the named section,
+// returning false if there were none.
+func (f *File) Unset(section, key string) bool {
+	section = normalizeSection(section)
+	lines := f.lines[:0]
+	found := false
+	for _, l := range f.lines {
+		if l.key == key && l.section == section {
+			found = true
+			continue
+		}
+		lines = append(lines, l)
+	}
+	f.lines = lines
+	return found
+}
+
+// normalizeSection collapses whitespace within a section name, so that
+// "[profile  staging]" and "[profile staging]" are the same section.
+func normalizeSection(section string) string {
+	return strings.Join(strings.Fields(section), " ")
+}
+
+// quote double-quotes values which would not otherwise be read back
+// unchanged.
+func quote(value string) string {
+	if value != strings.TrimSpace(value) || strings.HasPrefix(value, `"`) ||
+		strings.ContainsAny(value, "\r\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/test/assert"
+)
+
+const testFile = `# defaults
+profile = dev
+
+[profile dev]
+; the local control plane
+api-url = http://localhost
+deploy.region = "  padded  "
+
+[profile  prod]
+api-url = https://prod
+api-url = https://prod2
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse(strings.NewReader(testFile))
+	assert.Nil(t, err)
+
+	assert.DeepEqual(t, f.Sections(), []string{"profile dev", "profile prod"})
+	assert.True(t, f.HasSection("profile dev"))
+	assert.True(t, f.HasSection("profile   prod"))
+	assert.False(t, f.HasSection("profile test"))
+	assert.False(t, f.HasSection(""))
+
+	assert.DeepEqual(t, f.Keys(""), []string{"profile"})
+	assert.DeepEqual(t, f.Keys("profile dev"), []string{"api-url", "deploy.region"})
+	assert.DeepEqual(t, f.Keys("profile prod"), []string{"api-url"})
+	assert.DeepEqual(t, f.Keys("profile test"), []string{})
+
+	for _, tc := range []struct {
+		section, key string
+		want         string
+		wantFound    bool
+	}{
+		{"", "profile", "dev", true},
+		{"profile dev", "api-url", "http://localhost", true},
+		{"profile dev", "deploy.region", "  padded  ", true},
+		{"profile prod", "api-url", "https://prod2", true},
+		{"profile prod", "profile", "", false},
+		{"", "api-url", "", false},
+	} {
+		assert.Group(
+			fmt.Sprintf("Get(%q, %q)", tc.section, tc.key),
+			t,
+			func(g *assert.G) {
+				got, found := f.Get(tc.section, tc.key)
+				assert.Equal(g, got, tc.want)
+				assert.Equal(g, found, tc.wantFound)
+			},
+		)
+	}
+
+	assert.DeepEqual(t, f.GetAll("profile prod", "api-url"), []string{"https://prod", "https://prod2"})
+	assert.DeepEqual(t, f.GetAll("profile dev", "deploy.region"), []string{"  padded  "})
+	assert.DeepEqual(t, f.GetAll("profile dev", "profile"), []string{})
+
+	var buf bytes.Buffer
+	_, err = f.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), testFile)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		wantErr string
+	}{
+		{input: "[profile", wantErr: "line 1: expected ] at end of section header"},
+		{input: "\n[ ]", wantErr: "line 2: empty section name"},
+		{input: "a = 1\nb", wantErr: "line 2: expected = after b"},
+		{input: "= 1", wantErr: "line 1: missing key before ="},
+		{input: `a = "unterminated`, wantErr: "line 1: invalid quoted value for a"},
+	} {
+		assert.Group(
+			fmt.Sprintf("Parse(%q)", tc.input),
+			t,
+			func(g *assert.G) {
+				_, err := Parse(strings.NewReader(tc.input))
+				assert.ErrorContains(g, err, tc.wantErr)
+			},
+		)
+	}
+}
+
+func TestSetAndUnset(t *testing.T) {
+	f, err := Parse(strings.NewReader(testFile))
+	assert.Nil(t, err)
+
+	f.Set("", "profile", "prod")
+	f.Set("", "color", "never")
+	f.Set("profile dev", "deploy.region", "us-west-1")
+	f.Set("profile prod", "api-url", "https://prod3")
+	f.Set("profile  test", "api-url", " spaced ")
+	assert.True(t, f.Unset("profile dev", "api-url"))
+	assert.False(t, f.Unset("profile dev", "api-url"))
+
+	var buf bytes.Buffer
+	_, err = f.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), `# defaults
+profile = prod
+color = never
+
+[profile dev]
+; the local control plane
+deploy.region = us-west-1
+
+[profile  prod]
+api-url = https://prod
+api-url = https://prod3
+
+[profile test]
+api-url = " spaced "
+`)
+
+	got, err := Parse(&buf)
+	assert.Nil(t, err)
+	value, _ := got.Get("profile test", "api-url")
+	assert.Equal(t, value, " spaced ")
+
+	var empty File
+	empty.Set("", "profile", "dev")
+	empty.Set("alias", "d", "deploy")
+	buf.Reset()
+	_, err = empty.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "profile = dev\n\n[alias]\nd = deploy\n")
+}
+
+func TestAdd(t *testing.T) {
+	var f File
+	f.Add("profile dev", "host", "a")
+	f.Add("profile dev", "region", "eu")
+	f.Add("profile dev", "host", "b")
+	f.Set("profile dev", "host", "c")
+	f.Add("profile prod", "host", "d")
+
+	assert.DeepEqual(t, f.GetAll("profile dev", "host"), []string{"a", "c"})
+
+	var buf bytes.Buffer
+	_, err := f.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), `[profile dev]
+host = a
+region = eu
+host = c
+
+[profile prod]
+host = d
+`)
+}
+
+func TestReadWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app", "config")
+
+	f, err := Read(path)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, f.Sections(), []string{})
+
+	f.Set("profile dev", "api-url", "http://localhost")
+	assert.Nil(t, f.Write(path))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0600))
+
+	f, err = Read(path)
+	assert.Nil(t, err)
+	value, found := f.Get("profile dev", "api-url")
+	assert.Equal(t, value, "http://localhost")
+	assert.True(t, found)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("[x"), 0600))
+	_, err = Read(path)
+	assert.ErrorContains(t, err, path+": line 1: expected ]")
+}
+
+func TestDefaultPath(t *testing.T) {
+	if os.Getenv("XDG_CONFIG_HOME") != "" {
+		assert.Equal(t, DefaultPath("app"), filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "app", "config"))
+	} else {
+		assert.Equal(t, filepath.Base(DefaultPath("app")), "config")
+		assert.Equal(t, filepath.Base(filepath.Dir(DefaultPath("app"))), "app")
+	}
+}
+
+func TestDefaultPathInEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses APPDATA and USERPROFILE")
+	}
+
+	for _, tc := range []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"XDG_CONFIG_HOME": "/xdg", "HOME": "/home/u"}, "/xdg/app/config"},
+		{map[string]string{"HOME": "/home/u"}, "/home/u/.config/app/config"},
+		{map[string]string{"XDG_CONFIG_HOME": "", "HOME": "/home/u"}, "/home/u/.config/app/config"},
+		{map[string]string{}, ""},
+	} {
+		lookupEnv := func(key string) (string, bool) {
+			value, ok := tc.env[key]
+			return value, ok
+		}
+		assert.Equal(t, DefaultPathInEnv("app", lookupEnv), tc.want)
+	}
+}
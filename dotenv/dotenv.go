@@ -0,0 +1,256 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The dotenv package parses dotenv files, which assign environment
+// variables one per line, as commonly kept in a project's .env file.
+package dotenv
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Parse reads variable assignments from the given io.Reader. Each
+// assignment has the form KEY=value, optionally preceded by "export".
+// Blank lines, and comments beginning with #, are ignored. Unquoted
+// values extend to the end of the line, or to a # preceded by whitespace,
+// and are trimmed of surrounding whitespace. Within single quotes, all
+// characters are literal. Within double quotes, \n, \r and \t are
+// replaced by a newline, carriage return and tab, and a backslash escapes
+// a double quote, backslash or dollar sign, and is otherwise literal.
+// Quoted values may span lines. No variable expansion is performed. If a
+// variable is assigned more than once, the last assignment wins.
+func Parse(r io.Reader) (map[string]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{s: []rune(string(data)), line: 1}
+	vars := map[string]string{}
+	for {
+		p.skipBlankLinesAndComments()
+		if p.eof() {
+			return vars, nil
+		}
+
+		line := p.line
+		key, value, err := p.assignment()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		vars[key] = value
+	}
+}
+
+// Read parses the dotenv file at the given path, as by Parse.
+func Read(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return vars, nil
+}
+
+type parser struct {
+	s    []rune
+	pos  int
+	line int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) next() rune {
+	r := p.s[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+	}
+	return r
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.next()
+	}
+}
+
+func (p *parser) skipToEndOfLine() {
+	for !p.eof() && p.peek() != '\n' {
+		p.next()
+	}
+}
+
+func (p *parser) skipBlankLinesAndComments() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			p.next()
+		case '#':
+			p.skipToEndOfLine()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) word() string {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n=#", p.peek()) {
+		p.next()
+	}
+	return string(p.s[start:p.pos])
+}
+
+func (p *parser) assignment() (string, string, error) {
+	key := p.word()
+	if key == "export" {
+		p.skipSpaces()
+		if p.peek() != '=' {
+			key = p.word()
+		}
+	}
+
+	if !validKey(key) {
+		return "", "", fmt.Errorf("invalid variable name %q", key)
+	}
+
+	p.skipSpaces()
+	if p.eof() || p.peek() != '=' {
+		return "", "", fmt.Errorf("expected = after %s", key)
+	}
+	p.next()
+	p.skipSpaces()
+
+	var (
+		value string
+		err   error
+	)
+	switch p.peek() {
+	case '\'':
+		value, err = p.singleQuoted()
+	case '"':
+		value, err = p.doubleQuoted()
+	default:
+		return key, p.unquoted(), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	p.skipSpaces()
+	switch p.peek() {
+	case 0, '\r', '\n':
+	case '#':
+		p.skipToEndOfLine()
+	default:
+		return "", "", fmt.Errorf("unexpected %q after quoted value of %s", p.peek(), key)
+	}
+
+	return key, value, nil
+}
+
+func (p *parser) unquoted() string {
+	start := p.pos
+	for !p.eof() && p.peek() != '\n' {
+		if p.peek() == '#' && (p.pos == start || p.s[p.pos-1] == ' ' || p.s[p.pos-1] == '\t') {
+			value := string(p.s[start:p.pos])
+			p.skipToEndOfLine()
+			return strings.TrimSpace(value)
+		}
+		p.next()
+	}
+	return strings.TrimSpace(string(p.s[start:p.pos]))
+}
+
+func (p *parser) singleQuoted() (string, error) {
+	p.next()
+	start := p.pos
+	for !p.eof() {
+		if p.peek() == '\'' {
+			value := string(p.s[start:p.pos])
+			p.next()
+			return value, nil
+		}
+		p.next()
+	}
+	return "", fmt.Errorf("unterminated quoted value")
+}
+
+func (p *parser) doubleQuoted() (string, error) {
+	p.next()
+	var b strings.Builder
+	for !p.eof() {
+		r := p.next()
+		switch {
+		case r == '"':
+			return b.String(), nil
+
+		case r == '\\' && !p.eof():
+			escaped := p.next()
+			switch escaped {
+			case 'n':
+				b.WriteRune('\n')
+			case 'r':
+				b.WriteRune('\r')
+			case 't':
+				b.WriteRune('\t')
+			case '"', '\\', '$':
+				b.WriteRune(escaped)
+			default:
+				b.WriteRune('\\')
+				b.WriteRune(escaped)
+			}
+
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted value")
+}
+
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dotenv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		want    map[string]string
+		wantErr string
+	}{
+		{input: "", want: map[string]string{}},
+		{input: "\n# comment\n  \n", want: map[string]string{}},
+		{input: "A=1", want: map[string]string{"A": "1"}},
+		{input: "A=1\r\nB=2\r\n", want: map[string]string{"A": "1", "B": "2"}},
+		{input: "  A = spaced value  \n", want: map[string]string{"A": "spaced value"}},
+		{input: "A=\nB=", want: map[string]string{"A": "", "B": ""}},
+		{input: "export A=1\nexport=2", want: map[string]string{"A": "1", "export": "2"}},
+		{input: "A=1 # comment\nB=x#y", want: map[string]string{"A": "1", "B": "x#y"}},
+		{input: "A=1\nA=2", want: map[string]string{"A": "2"}},
+		{input: "A=$HOME", want: map[string]string{"A": "$HOME"}},
+		{input: `A='it''s' `, wantErr: `line 1: unexpected '\'' after quoted value of A`},
+		{input: `A='a \n "b" # c' # comment`, want: map[string]string{"A": `a \n "b" # c`}},
+		{input: `A="a\nb\t\"c\" \\ \$d \x"`, want: map[string]string{"A": "a\nb\t\"c\" \\ $d \\x"}},
+		{input: "A=\"multi\nline\"\nB='also\nmulti'", want: map[string]string{"A": "multi\nline", "B": "also\nmulti"}},
+		{input: "A=1\nB=\"unterminated\n", wantErr: "line 2: unterminated quoted value"},
+		{input: "A='unterminated", wantErr: "line 1: unterminated quoted value"},
+		{input: "A=1\n\n1A=2", wantErr: `line 3: invalid variable name "1A"`},
+		{input: "A-B=1", wantErr: `invalid variable name "A-B"`},
+		{input: "=1", wantErr: `line 1: invalid variable name ""`},
+		{input: "A 1", wantErr: "line 1: expected = after A"},
+		{input: "A", wantErr: "line 1: expected = after A"},
+	} {
+		assert.Group(
+			fmt.Sprintf("Parse(%q)", tc.input),
+			t,
+			func(g *assert.G) {
+				got, err := Parse(strings.NewReader(tc.input))
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+				} else {
+					assert.Nil(g, err)
+					assert.DeepEqual(g, got, tc.want)
+				}
+			},
+		)
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dotenv")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".env")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("A=1\n"), 0644))
+
+	got, err := Read(path)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, got, map[string]string{"A": "1"})
+
+	bad := filepath.Join(dir, "bad.env")
+	assert.Nil(t, ioutil.WriteFile(bad, []byte("A\n"), 0644))
+	_, err = Read(bad)
+	assert.ErrorContains(t, err, bad+": line 1: expected = after A")
+
+	_, err = Read(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/turbinelabs/cli/command"
+	tbnflag "github.com/turbinelabs/nonstdlib/flag"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+)
+
+// lookupFromEnv is a tbnflag.FromEnv which fills unset flags from the
+// environment via the given lookup function, rather than directly from the
+// process environment. Keys are constructed, and sensitive values
+// redacted, as by tbnflag.FromEnv, except that keys may be overridden with
+// command.WithEnvKey, and flags set command.WithoutEnv are skipped. Keys
+// loaded from an env file are noted in Filled, along with its path.
+type lookupFromEnv struct {
+	tbnflag.FromEnv
+
+	fs        *flag.FlagSet
+	lookupEnv func(string) (string, bool)
+	origins   map[string]string
+	filled    map[string]string
+}
+
+func newLookupFromEnv(
+	fe tbnflag.FromEnv,
+	fs *flag.FlagSet,
+	lookupEnv func(string) (string, bool),
+	origins map[string]string,
+) tbnflag.FromEnv {
+	return &lookupFromEnv{
+		FromEnv:   fe,
+		fs:        fs,
+		lookupEnv: lookupEnv,
+		origins:   origins,
+		filled:    map[string]string{},
+	}
+}
+
+func (fe *lookupFromEnv) Fill() error {
+	set := map[string]bool{}
+	fe.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, f := range tbnflag.Enumerate(fe.fs) {
+		if set[f.Name] {
+			continue
+		}
+
+		key, ok := envKey(fe, f)
+		if !ok {
+			continue
+		}
+		value, ok := fe.lookupEnv(key)
+		if !ok {
+			continue
+		}
+
+		sensitive := usage.New(f.Usage).IsSensitive()
+		if sensitive {
+			fe.filled[key] = "<redacted>"
+		} else {
+			fe.filled[key] = value
+		}
+		if origin, ok := fe.origins[key]; ok {
+			fe.filled[key] += fmt.Sprintf(" (from %s)", origin)
+		}
+
+		if err := fe.fs.Set(f.Name, value); err != nil {
+			// don't echo secrets
+			if sensitive {
+				return fmt.Errorf("invalid value for %s: %s", key, err)
+			}
+			return fmt.Errorf("invalid value %q for %s: %s", value, key, err)
+		}
+	}
+
+	return nil
+}
+
+func (fe *lookupFromEnv) Filled() map[string]string {
+	return fe.filled
+}
+
+// envKey returns the environment variable from which the given FromEnv
+// fills the flag, as by command.FlagEnvKey with the FromEnv's prefix.
+func envKey(fe tbnflag.FromEnv, f *flag.Flag) (string, bool) {
+	return command.FlagEnvKey(fe.Prefix(), f)
+}
+
+// hasEnvOptions indicates whether any global or command flag has an
+// environment variable set with command.WithEnvKey, or is set
+// command.WithoutEnv, either of which tbnflag.FromEnv ignores.
+func (cli *cli) hasEnvOptions() bool {
+	found := false
+	check := func(f *flag.Flag) {
+		_, overridden := command.EnvKeyOverride(f)
+		_, filled := command.FlagEnvKey("", f)
+		found = found || overridden || !filled
+	}
+
+	cli.flags.VisitAll(check)
+	for _, cmd := range cli.commands {
+		cmd.Flags.VisitAll(check)
+	}
+	return found
+}
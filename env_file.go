@@ -0,0 +1,143 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/turbinelabs/cli/dotenv"
+	tbnflag "github.com/turbinelabs/nonstdlib/flag"
+)
+
+const EnvFileSummary = "Load environment variables from the dotenv `file`, unless already set in the environment"
+
+func (cli *cli) EnableEnvFile(defaultPath string) {
+	cli.envFileEnabled = true
+	cli.envFileDefault = defaultPath
+}
+
+func (cli *cli) addEnvFileFlagIfMissing(fs *flag.FlagSet, path *string) {
+	if cli.envFileEnabled && fs.Lookup("env-file") == nil {
+		fs.StringVar(path, "env-file", cli.envFileDefault, EnvFileSummary)
+	}
+}
+
+// loadEnvFile returns the given environment, or if it is nil, the process
+// environment, extended with the variables in the env file named by the
+// env-file flag in args, the corresponding environment variable, or the
+// default path. Variables already in the environment are not overridden.
+// The keys of loaded variables are recorded in envFileKeys. If no env
+// file is loaded, env is returned unchanged. A missing file is only an
+// error if its path was given explicitly.
+func (cli *cli) loadEnvFile(args []string, env map[string]string) (map[string]string, error) {
+	cli.envFileKeys = nil
+	if !cli.envFileEnabled {
+		return env, nil
+	}
+
+	lookupEnv := os.LookupEnv
+	if env != nil {
+		lookupEnv = func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		}
+	}
+
+	fs := &cli.flags
+	if !cli.app.HasSubCmds {
+		fs = &cli.commands[0].Flags
+	}
+
+	path, explicit := flagArg(fs, args, "env-file")
+	if !explicit {
+		path, explicit = lookupEnv(tbnflag.EnvKey(cli.name, "env-file"))
+	}
+	if !explicit {
+		path = cli.envFileDefault
+	}
+	if path == "" {
+		return env, nil
+	}
+
+	vars, err := dotenv.Read(path)
+	if os.IsNotExist(err) && !explicit {
+		return env, nil
+	}
+	if err != nil {
+		return env, fmt.Errorf("env file: %s", err)
+	}
+
+	merged := map[string]string{}
+	if env == nil {
+		for _, kv := range os.Environ() {
+			if eq := strings.Index(kv, "="); eq > 0 {
+				merged[kv[:eq]] = kv[eq+1:]
+			}
+		}
+	} else {
+		for key, value := range env {
+			merged[key] = value
+		}
+	}
+
+	cli.envFileKeys = map[string]string{}
+	for key, value := range vars {
+		if _, ok := merged[key]; !ok {
+			merged[key] = value
+			cli.envFileKeys[key] = path
+		}
+	}
+
+	return merged, nil
+}
+
+// flagArg returns the value of the named string flag in args, without
+// parsing them. As with flag.FlagSet, flags end at the first non-flag
+// argument or --.
+func flagArg(fs *flag.FlagSet, args []string, name string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			break
+		}
+
+		argName := strings.TrimLeft(arg, "-")
+		if eq := strings.Index(argName, "="); eq >= 0 {
+			if argName[:eq] == name {
+				return argName[eq+1:], true
+			}
+			continue
+		}
+
+		if argName == name {
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", false
+		}
+
+		// skip the value of other non-boolean flags
+		if f := fs.Lookup(argName); f != nil && !isBoolFlag(f) {
+			i++
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestFlagArg(t *testing.T) {
+	var fs flag.FlagSet
+	fs.String("region", "", "")
+	fs.Bool("debug", false, "")
+
+	for _, tc := range []struct {
+		args      []string
+		want      string
+		wantFound bool
+	}{
+		{args: nil},
+		{args: []string{"--env-file=a.env"}, want: "a.env", wantFound: true},
+		{args: []string{"-env-file", "a.env"}, want: "a.env", wantFound: true},
+		{args: []string{"--debug", "--env-file", "a.env"}, want: "a.env", wantFound: true},
+		{args: []string{"--region", "--env-file", "--env-file=b.env"}, want: "b.env", wantFound: true},
+		{args: []string{"cmd", "--env-file=a.env"}},
+		{args: []string{"--", "--env-file=a.env"}},
+		{args: []string{"--env-file"}},
+	} {
+		assert.Group(
+			fmt.Sprintf("flagArg(%q)", tc.args),
+			t,
+			func(g *assert.G) {
+				got, found := flagArg(&fs, tc.args, "env-file")
+				assert.Equal(g, got, tc.want)
+				assert.Equal(g, found, tc.wantFound)
+			},
+		)
+	}
+}
+
+func TestCLILoadEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	defaultFile := writeFlagFile(t, dir, ".env", "APP_REGION=us-west-1\nAPP_PORT=8080\n")
+	other := writeFlagFile(t, dir, "other.env", "APP_REGION=eu\n")
+	invalid := writeFlagFile(t, dir, "invalid.env", "APP_REGION\n")
+	missing := filepath.Join(dir, "missing.env")
+
+	for _, tc := range []struct {
+		name        string
+		defaultPath string
+		args        []string
+		env         map[string]string
+		want        map[string]string
+		wantKeys    map[string]string
+		wantErr     string
+	}{
+		{
+			name:        "default",
+			defaultPath: defaultFile,
+			env:         map[string]string{"APP_PORT": "80"},
+			want:        map[string]string{"APP_REGION": "us-west-1", "APP_PORT": "80"},
+			wantKeys:    map[string]string{"APP_REGION": defaultFile},
+		},
+		{
+			name:        "missing default",
+			defaultPath: missing,
+			env:         map[string]string{"APP_PORT": "80"},
+			want:        map[string]string{"APP_PORT": "80"},
+		},
+		{
+			name:        "flag",
+			defaultPath: defaultFile,
+			args:        []string{"--env-file", other, "cmd"},
+			env:         map[string]string{},
+			want:        map[string]string{"APP_REGION": "eu"},
+			wantKeys:    map[string]string{"APP_REGION": other},
+		},
+		{
+			name:     "environment",
+			env:      map[string]string{"APP_ENV_FILE": other},
+			want:     map[string]string{"APP_ENV_FILE": other, "APP_REGION": "eu"},
+			wantKeys: map[string]string{"APP_REGION": other},
+		},
+		{
+			name:        "disabled by empty flag",
+			defaultPath: defaultFile,
+			args:        []string{"--env-file="},
+			env:         map[string]string{},
+			want:        map[string]string{},
+		},
+		{
+			name:    "missing explicit file",
+			args:    []string{"--env-file=" + missing},
+			env:     map[string]string{},
+			want:    map[string]string{},
+			wantErr: "env file: open " + missing,
+		},
+		{
+			name:    "invalid file",
+			env:     map[string]string{"APP_ENV_FILE": invalid},
+			want:    map[string]string{"APP_ENV_FILE": invalid},
+			wantErr: "env file: " + invalid + ": line 1:",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("loadEnvFile(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				c := mkNew(app.App{Name: "app", HasSubCmds: true}, &command.Cmd{Name: "cmd"}).(*cli)
+				c.EnableEnvFile(tc.defaultPath)
+
+				got, err := c.loadEnvFile(tc.args, tc.env)
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+				} else {
+					assert.Nil(g, err)
+				}
+				assert.DeepEqual(g, got, tc.want)
+				if tc.wantKeys == nil {
+					assert.Equal(g, len(c.envFileKeys), 0)
+				} else {
+					assert.DeepEqual(g, c.envFileKeys, tc.wantKeys)
+				}
+			},
+		)
+	}
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, &command.Cmd{Name: "cmd"}).(*cli)
+	env := map[string]string{"APP_ENV_FILE": other}
+	got, err := c.loadEnvFile(nil, env)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, got, env)
+}
+
+func TestCLIRunEnvFileAfterBuiltinFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	envFile := writeFlagFile(t, dir, ".env", "APP_NAME=fromfile\n")
+
+	for _, tc := range []struct {
+		hasSubCmds bool
+		args       []string
+	}{
+		{true, []string{"--color", "never", "--error-format", "json", "--env-file", envFile, "show"}},
+		{false, []string{"--color", "never", "--env-file", envFile}},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q)", tc.args),
+			t,
+			func(g *assert.G) {
+				var name string
+				cmd := &command.Cmd{
+					Name: "show",
+					Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+						fmt.Fprintf(cmd.Stdout(), "name=%q\n", name)
+						return command.NoError()
+					}),
+				}
+
+				var c *cli
+				if tc.hasSubCmds {
+					c = mkNew(app.App{Name: "app", HasSubCmds: true}, cmd).(*cli)
+					c.flags.StringVar(&name, "name", "", "the name")
+				} else {
+					c = mkNew(app.App{Name: "app"}, cmd).(*cli)
+					cmd.Flags.StringVar(&name, "name", "", "the name")
+				}
+				c.EnableEnvFile("")
+
+				// the first run, before the built-in flags have been added
+				var stdout bytes.Buffer
+				cmdErr := c.Run(context.Background(), tc.args, map[string]string{}, command.Stdio{Stdout: &stdout})
+				assert.Equal(g, cmdErr, command.NoError())
+				assert.Equal(g, stdout.String(), `name="fromfile"`+"\n")
+			},
+		)
+	}
+}
+
+func TestCLIRunEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	envFile := writeFlagFile(t, dir, ".env", "APP_API_KEY=s3cr3t\nAPP_SHOW_REGION=us-west-1\n")
+
+	var apiKey, region string
+	cmd := &command.Cmd{
+		Name: "show",
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			fmt.Fprintf(cmd.Stdout(), "%s %s\n", apiKey, region)
+			return command.NoError()
+		}),
+	}
+	cmd.Flags.StringVar(&region, "region", "", "the region")
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, cmd).(*cli)
+	c.flags.StringVar(&apiKey, "api-key", "", usage.New("the key").SetSensitive().String())
+	c.EnableEnvFile("")
+
+	var stdout, stderr bytes.Buffer
+	cmdErr := c.Run(
+		context.Background(),
+		[]string{"--env-file", envFile, "show"},
+		map[string]string{"APP_SHOW_REGION": "eu"},
+		command.Stdio{Stdout: &stdout, Stderr: &stderr},
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout.String(), "s3cr3t eu\n")
+
+	// loaded keys are noted in usage
+	c.newUsage = func(w io.Writer, mode style.Mode) app.Usage {
+		return c.app.StyledRedirectedUsage(w, style.Never)
+	}
+
+	stdout.Reset()
+	cmdErr = c.Run(
+		context.Background(),
+		[]string{"show", "--help"},
+		map[string]string{"APP_ENV_FILE": envFile},
+		command.Stdio{Stdout: &stdout, Stderr: &stderr},
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.True(t, strings.Contains(stdout.String(), "APP_API_KEY=<redacted> (from "+envFile+")"))
+	assert.True(t, strings.Contains(stdout.String(), "APP_SHOW_REGION=us-west-1 (from "+envFile+")"))
+	assert.False(t, strings.Contains(stdout.String(), "s3cr3t"))
+
+	// a missing env file is bad input
+	stdout.Reset()
+	stderr.Reset()
+	cmdErr = c.Run(
+		context.Background(),
+		[]string{"--env-file", filepath.Join(dir, "missing"), "show"},
+		map[string]string{},
+		command.Stdio{Stdout: &stdout, Stderr: &stderr},
+	)
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+	assert.True(t, strings.Contains(stderr.String(), "env file: open "))
+}
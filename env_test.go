@@ -0,0 +1,142 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+	"github.com/turbinelabs/test/assert"
+)
+
+type boundRunner struct {
+	Region string   `flag:"region" default:"us-east-1"`
+	Token  string   `flag:"token" env:"DEPLOY_TOKEN" sensitive:"true"`
+	Hosts  []string `flag:"host" default:"a"`
+	DryRun bool     `flag:"dry-run" env:"-"`
+}
+
+func (r *boundRunner) Run(cmd *command.Cmd, args []string) command.CmdErr {
+	if r.DryRun {
+		fmt.Fprint(cmd.Stdout(), "dry run: ")
+	}
+	fmt.Fprintf(cmd.Stdout(), "%s %s %q\n", r.Region, r.Token, r.Hosts)
+	return command.NoError()
+}
+
+func mkBoundCLI(t *testing.T) *cli {
+	runner := &boundRunner{}
+	cmd := &command.Cmd{Name: "deploy", Runner: runner}
+	assert.Nil(t, command.BindFlags(&cmd.Flags, runner))
+	return mkNew(app.App{Name: "app", HasSubCmds: true}, cmd).(*cli)
+}
+
+func TestCLIRunEnvKeyOverride(t *testing.T) {
+	c := mkBoundCLI(t)
+
+	for _, tc := range []struct {
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{
+			args: []string{"deploy"},
+			env:  map[string]string{"DEPLOY_TOKEN": "t", "APP_DEPLOY_REGION": "eu"},
+			want: `eu t ["a"]` + "\n",
+		},
+		{
+			args: []string{"deploy", "--host=b", "--host=c"},
+			env:  map[string]string{"APP_DEPLOY_TOKEN": "ignored"},
+			want: `us-east-1  ["b" "c"]` + "\n",
+		},
+		{
+			args: []string{"deploy"},
+			env:  map[string]string{"APP_DEPLOY_DRY_RUN": "true"},
+			want: `us-east-1  ["a"]` + "\n",
+		},
+		{
+			args: []string{"deploy", "--dry-run"},
+			want: `dry run: us-east-1  ["a"]` + "\n",
+		},
+		{
+			args: []string{"deploy", "--token=x"},
+			env:  map[string]string{"DEPLOY_TOKEN": "t", "APP_DEPLOY_HOST": "d,e"},
+			want: `us-east-1 x ["d" "e"]` + "\n",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q, %v)", tc.args, tc.env),
+			t,
+			func(g *assert.G) {
+				var stdout bytes.Buffer
+				cmdErr := c.Run(context.Background(), tc.args, tc.env, command.Stdio{Stdout: &stdout})
+				assert.Equal(g, cmdErr, command.NoError())
+				assert.Equal(g, stdout.String(), tc.want)
+			},
+		)
+	}
+
+	// overrides and opt-outs apply to the process environment, too
+	os.Setenv("DEPLOY_TOKEN", "from-process")
+	defer os.Unsetenv("DEPLOY_TOKEN")
+	os.Setenv("APP_DEPLOY_DRY_RUN", "true")
+	defer os.Unsetenv("APP_DEPLOY_DRY_RUN")
+
+	var stdout bytes.Buffer
+	cmdErr := c.Run(context.Background(), []string{"deploy"}, nil, command.Stdio{Stdout: &stdout})
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout.String(), `us-east-1 from-process ["a"]`+"\n")
+}
+
+func TestCLIRunEnvInvalidValues(t *testing.T) {
+	deploy := &command.Cmd{Name: "deploy", Runner: &boundRunner{}}
+	deploy.Flags.Int("replicas", 1, "")
+	deploy.Flags.Int("pin", 0, usage.Sensitive(""))
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, deploy)
+
+	for _, tc := range []struct {
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			env:     map[string]string{"APP_DEPLOY_REPLICAS": "many"},
+			wantErr: `invalid value "many" for APP_DEPLOY_REPLICAS`,
+		},
+		{
+			env:     map[string]string{"APP_DEPLOY_PIN": "s3cr3t"},
+			wantErr: "invalid value for APP_DEPLOY_PIN",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(deploy, %v)", tc.env),
+			t,
+			func(g *assert.G) {
+				cmdErr, _, stderr := runCLI(c, []string{"deploy"}, tc.env, "")
+				assert.Equal(g, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+				assert.True(g, strings.Contains(stderr, tc.wantErr))
+				assert.False(g, strings.Contains(stderr, "s3cr3t"))
+			},
+		)
+	}
+}
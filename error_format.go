@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/turbinelabs/cli/command"
+)
+
+// ErrorFormat determines how Main reports errors. ErrorFormat implements
+// flag.Value, and may be used directly as a command-line flag.
+type ErrorFormat int
+
+const (
+	// ErrorFormatText reports errors as styled text, followed by usage
+	// when the error is due to bad input.
+	ErrorFormatText ErrorFormat = iota
+
+	// ErrorFormatJSON reports errors as a single JSON object, and does
+	// not print usage. See JSONError.
+	ErrorFormatJSON
+)
+
+var errorFormatNames = []string{"text", "json"}
+
+// String returns the name of the ErrorFormat.
+func (f ErrorFormat) String() string {
+	if f < ErrorFormatText || int(f) >= len(errorFormatNames) {
+		return fmt.Sprintf("ErrorFormat(%d)", int(f))
+	}
+	return errorFormatNames[f]
+}
+
+// Set sets the ErrorFormat from its name.
+func (f *ErrorFormat) Set(s string) error {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range errorFormatNames {
+		if s == name {
+			*f = ErrorFormat(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid error format %q: must be one of %s", s, f.ValidValuesDescription())
+}
+
+// Get returns the name of the ErrorFormat.
+func (f *ErrorFormat) Get() interface{} {
+	return f.String()
+}
+
+// ValidValuesDescription describes the valid names of an ErrorFormat.
+func (f *ErrorFormat) ValidValuesDescription() string {
+	return `"text" or "json"`
+}
+
+// JSONError is the object written to stderr by Main when the error format
+// is ErrorFormatJSON.
+type JSONError struct {
+	Command string   `json:"command,omitempty"` // the command name, if the error is Cmd-scoped
+	Code    int      `json:"code"`              // the exit code
+	Message string   `json:"message"`           // the error message
+	Causes  []string `json:"causes,omitempty"`  // the messages of the wrapped errors, outermost first
+	Hint    string   `json:"hint,omitempty"`    // a suggestion for resolving the error
+	DocURL  string   `json:"doc_url,omitempty"` // a link to relevant documentation
+}
+
+func newJSONError(cmdErr command.CmdErr) JSONError {
+	jsonErr := JSONError{
+		Code:    int(cmdErr.Code),
+		Message: strings.TrimSpace(cmdErr.Error()),
+		Hint:    cmdErr.Hint,
+		DocURL:  cmdErr.DocURL,
+	}
+
+	if cmdErr.Cmd != nil {
+		jsonErr.Command = cmdErr.Cmd.Name
+	}
+
+	for cause := cmdErr.Cause; cause != nil; cause = errors.Unwrap(cause) {
+		jsonErr.Causes = append(jsonErr.Causes, cause.Error())
+	}
+
+	return jsonErr
+}
+
+func writeJSONError(w io.Writer, cmdErr command.CmdErr) {
+	// JSONError contains only strings and ints, and cannot fail to encode
+	json.NewEncoder(w).Encode(newJSONError(cmdErr))
+}
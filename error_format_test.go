@@ -0,0 +1,82 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestErrorFormatSet(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		want    ErrorFormat
+		wantErr string
+	}{
+		{value: "text", want: ErrorFormatText},
+		{value: "JSON", want: ErrorFormatJSON},
+		{value: " json ", want: ErrorFormatJSON},
+		{value: "xml", wantErr: `invalid error format "xml": must be one of "text" or "json"`},
+	} {
+		assert.Group(
+			fmt.Sprintf("ErrorFormat.Set(%q)", tc.value),
+			t,
+			func(g *assert.G) {
+				var f ErrorFormat
+				err := f.Set(tc.value)
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+				} else {
+					assert.Nil(g, err)
+					assert.Equal(g, f, tc.want)
+					assert.Equal(g, f.Get(), tc.want.String())
+				}
+			},
+		)
+	}
+}
+
+func TestErrorFormatString(t *testing.T) {
+	assert.Equal(t, ErrorFormatText.String(), "text")
+	assert.Equal(t, ErrorFormatJSON.String(), "json")
+	assert.Equal(t, ErrorFormat(7).String(), "ErrorFormat(7)")
+}
+
+func TestWriteJSONError(t *testing.T) {
+	cmd := &command.Cmd{Name: "foo"}
+	inner := errors.New("connection refused")
+	outer := fmt.Errorf("dialing: %w", inner)
+
+	buf := &bytes.Buffer{}
+	writeJSONError(buf, cmd.Wrap(outer).WithHint("is the server up?").WithCode(5))
+	assert.Equal(
+		t,
+		buf.String(),
+		`{"command":"foo","code":5,"message":"foo: dialing: connection refused",`+
+			`"causes":["dialing: connection refused","connection refused"],`+
+			`"hint":"is the server up?"}`+"\n",
+	)
+
+	buf.Reset()
+	writeJSONError(buf, mkBadInput("no command specified\n"))
+	assert.Equal(t, buf.String(), `{"code":2,"message":"no command specified"}`+"\n")
+}
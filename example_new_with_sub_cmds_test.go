@@ -41,7 +41,14 @@ func CmdSplit() *command.Cmd {
 		Summary:     "split strings",
 		Usage:       "[OPTIONS] <string>",
 		Description: "split strings using the specified delimiter",
-		Runner:      runner,
+		// Examples are rendered verbatim in the command's help text
+		Examples: []command.Example{
+			{
+				CommandLine: `stringy split --delim=: "a:b:c"`,
+				Explanation: "Split a string on colons.",
+			},
+		},
+		Runner: runner,
 	}
 
 	// The flag.FlagSet is a member of the command.Cmd, and the flag
@@ -67,15 +74,18 @@ func (f *splitRunner) Run(cmd *command.Cmd, args []string) command.CmdErr {
 		return cmd.BadInput("missing \"string\" argument.")
 	}
 	str := args[0]
+	// output should be written to cmd.Stdout(), rather than os.Stdout,
+	// so that it may be captured in tests with the clitest package
+	out := cmd.Stdout()
 	if globalFlags.verbose {
-		fmt.Printf("Splitting \"%s\"\n", str)
+		fmt.Fprintf(out, "Splitting \"%s\"\n", str)
 	}
 	split := strings.Split(str, f.delim)
 	for i, term := range split {
 		if globalFlags.verbose {
-			fmt.Printf("[%d] ", i)
+			fmt.Fprintf(out, "[%d] ", i)
 		}
-		fmt.Println(term)
+		fmt.Fprintln(out, term)
 	}
 
 	// In this case, there was no error. Errors should be returned via the
@@ -107,10 +117,10 @@ type joinRunner struct {
 
 func (f *joinRunner) Run(cmd *command.Cmd, args []string) command.CmdErr {
 	if globalFlags.verbose {
-		fmt.Printf("Joining \"%v\"\n", args)
+		fmt.Fprintf(cmd.Stdout(), "Joining \"%v\"\n", args)
 	}
 	joined := strings.Join(args, f.delim)
-	fmt.Println(joined)
+	fmt.Fprintln(cmd.Stdout(), joined)
 
 	return command.NoError()
 }
@@ -147,7 +157,7 @@ func Example_subCommands() {
 }
 
 // Add the following to your tests to validate that there are no collisions
-// between command flags:
+// between command flags, and that each command's examples parse:
 
 // package main
 
@@ -158,5 +168,5 @@ func Example_subCommands() {
 // )
 
 // func TestCLI(t *testing.T) {
-// 	assert.Nil(t, mkCLI().Validate())
+// 	assert.Nil(t, mkCLI().Validate(cli.ValidateExamples))
 // }
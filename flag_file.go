@@ -0,0 +1,241 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	tbnflag "github.com/turbinelabs/nonstdlib/flag"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+)
+
+// FileEnvSuffix is appended to the environment variable for a flag to
+// name a file from which the flag's value is read. See
+// CLI.EnableFlagFiles.
+const FileEnvSuffix = "_FILE"
+
+func (cli *cli) EnableFlagFiles() {
+	cli.flagFiles = true
+}
+
+// expandFlagFiles replaces the value of each flag in args given as @path
+// with the contents of the file at path, if flag files are enabled.
+// Values beginning with @@ are replaced by the value without its first @.
+// Parsing of args stops, as with flag.FlagSet, at the first non-flag
+// argument or --. Flags not defined in the FlagSet are left for the
+// FlagSet to report.
+func (cli *cli) expandFlagFiles(fs *flag.FlagSet, args []string) ([]string, error) {
+	if !cli.flagFiles {
+		return args, nil
+	}
+
+	expanded := append([]string(nil), args...)
+
+	for i := 0; i < len(expanded); i++ {
+		arg := expanded[i]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			break
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if eq := strings.Index(name, "="); eq >= 0 {
+			value, err := flagFileValue(name[:eq], name[eq+1:])
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = arg[:len(arg)-len(name)] + name[:eq+1] + value
+			continue
+		}
+
+		f := fs.Lookup(name)
+		if f == nil || isBoolFlag(f) || i+1 >= len(expanded) {
+			continue
+		}
+
+		// the flag's value is the next argument
+		i++
+		value, err := flagFileValue(name, expanded[i])
+		if err != nil {
+			return nil, err
+		}
+		expanded[i] = value
+	}
+
+	return expanded, nil
+}
+
+// flagValueArgs returns the indices of the arguments which are the values
+// of flags given as separate arguments, as in "--key @path". Global flags
+// are followed by the command's flags, once the command is named, as when
+// the arguments are parsed.
+func (cli *cli) flagValueArgs(args []string) map[int]bool {
+	values := map[int]bool{}
+	fs, inCmd := &cli.flags, false
+	if !cli.app.HasSubCmds {
+		fs, inCmd = &cli.commands[0].Flags, true
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			cmd := cli.command(arg)
+			if inCmd || cmd == nil {
+				break
+			}
+			fs, inCmd = &cmd.Flags, true
+			continue
+		}
+
+		f := fs.Lookup(strings.TrimLeft(arg, "-"))
+		if f != nil && !isBoolFlag(f) {
+			i++
+			values[i] = true
+		}
+	}
+
+	return values
+}
+
+// flagFileValue returns the given flag value, or if it is @path, the
+// contents of the file at path.
+func flagFileValue(name, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "@@"):
+		return value[1:], nil
+
+	case strings.HasPrefix(value, "@") && len(value) > 1:
+		contents, err := readFlagFile(value[1:])
+		if err != nil {
+			return "", fmt.Errorf("reading --%s: %s", name, err)
+		}
+		return contents, nil
+	}
+
+	return value, nil
+}
+
+// readFlagFile reads a flag value from the file at the given path,
+// removing a single trailing newline.
+func readFlagFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	bf, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
+
+// fileFromEnv is a tbnflag.FromEnv which, after filling flags from the
+// environment, fills flags which remain unset from files named by
+// environment variables with FileEnvSuffix. Command-line flags take
+// precedence over files; it is an error to set a flag both directly and
+// by file in the environment.
+type fileFromEnv struct {
+	tbnflag.FromEnv
+
+	fs        *flag.FlagSet
+	lookupEnv func(string) (string, bool)
+	filled    map[string]string
+}
+
+func newFileFromEnv(
+	fe tbnflag.FromEnv,
+	fs *flag.FlagSet,
+	lookupEnv func(string) (string, bool),
+) tbnflag.FromEnv {
+	return &fileFromEnv{FromEnv: fe, fs: fs, lookupEnv: lookupEnv, filled: map[string]string{}}
+}
+
+func (fe *fileFromEnv) Fill() error {
+	fromArgs := visited(fe.fs)
+
+	if err := fe.FromEnv.Fill(); err != nil {
+		return err
+	}
+
+	fromEnv := visited(fe.fs)
+
+	for _, f := range tbnflag.Enumerate(fe.fs) {
+		if fromArgs[f.Name] {
+			continue
+		}
+
+		key, ok := envKey(fe, f)
+		if !ok {
+			continue
+		}
+		path, ok := fe.lookupEnv(key + FileEnvSuffix)
+		if !ok {
+			continue
+		}
+
+		if fromEnv[f.Name] {
+			return fmt.Errorf("%s and %s%s are both set", key, key, FileEnvSuffix)
+		}
+
+		value, err := readFlagFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s%s: %s", key, FileEnvSuffix, err)
+		}
+
+		if usage.New(f.Usage).IsSensitive() {
+			fe.filled[key+FileEnvSuffix] = "<redacted>"
+		} else {
+			fe.filled[key+FileEnvSuffix] = path
+		}
+
+		// the value may be secret, so is not included in the error
+		if err := fe.fs.Set(f.Name, value); err != nil {
+			return fmt.Errorf("invalid value in %s for %s%s: %s", path, key, FileEnvSuffix, err)
+		}
+	}
+
+	return nil
+}
+
+func (fe *fileFromEnv) Filled() map[string]string {
+	filled := map[string]string{}
+	for key, value := range fe.FromEnv.Filled() {
+		filled[key] = value
+	}
+	for key, value := range fe.filled {
+		filled[key] = value
+	}
+	return filled
+}
+
+// visited returns the names of the flags which have been set.
+func visited(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
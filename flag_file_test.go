@@ -0,0 +1,330 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
+	tbnflag "github.com/turbinelabs/nonstdlib/flag"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+	"github.com/turbinelabs/test/assert"
+)
+
+func writeFlagFile(t *testing.T, dir, name, contents string) string {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestExpandFlagFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	key := writeFlagFile(t, dir, "key", "s3cr3t\n")
+	crlf := writeFlagFile(t, dir, "crlf", "a b\r\n")
+	missing := filepath.Join(dir, "missing")
+
+	var fs flag.FlagSet
+	fs.String("key", "", "")
+	fs.Bool("debug", false, "")
+
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "equals",
+			args: []string{"--key=@" + key, "cmd", "--key=@" + key},
+			want: []string{"--key=s3cr3t", "cmd", "--key=@" + key},
+		},
+		{
+			name: "single dash",
+			args: []string{"-key=@" + crlf},
+			want: []string{"-key=a b"},
+		},
+		{
+			name: "separate value",
+			args: []string{"--debug", "--key", "@" + key, "@" + key},
+			want: []string{"--debug", "--key", "s3cr3t", "@" + key},
+		},
+		{
+			name: "escaped",
+			args: []string{"--key=@@handle", "--key", "@@x"},
+			want: []string{"--key=@handle", "--key", "@x"},
+		},
+		{
+			name: "not a file",
+			args: []string{"--key=x@y", "--key", "@", "--unknown", "@" + missing},
+			want: []string{"--key=x@y", "--key", "@", "--unknown", "@" + missing},
+		},
+		{
+			name: "end of flags",
+			args: []string{"--", "--key=@" + missing},
+			want: []string{"--", "--key=@" + missing},
+		},
+		{
+			name:    "missing file",
+			args:    []string{"--key=@" + missing},
+			wantErr: "reading --key: open " + missing,
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("expandFlagFiles(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				c := &cli{flagFiles: true}
+				got, err := c.expandFlagFiles(&fs, tc.args)
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+				} else {
+					assert.Nil(g, err)
+					assert.DeepEqual(g, got, tc.want)
+				}
+			},
+		)
+	}
+
+	c := &cli{}
+	args := []string{"--key=@" + missing}
+	got, err := c.expandFlagFiles(&fs, args)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, got, args)
+}
+
+func TestFileFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	key := writeFlagFile(t, dir, "key", "s3cr3t\n")
+	region := writeFlagFile(t, dir, "region", "us-west-1\n")
+	port := writeFlagFile(t, dir, "port", "eighty\n")
+	missing := filepath.Join(dir, "missing")
+
+	for _, tc := range []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		wantKey    string
+		wantRegion string
+		wantFilled map[string]string
+		wantErr    string
+	}{
+		{
+			name: "from files",
+			env: map[string]string{
+				"APP_API_KEY_FILE": key,
+				"APP_REGION_FILE":  region,
+			},
+			wantKey:    "s3cr3t",
+			wantRegion: "us-west-1",
+			wantFilled: map[string]string{
+				"APP_API_KEY_FILE": "<redacted>",
+				"APP_REGION_FILE":  region,
+			},
+		},
+		{
+			name:       "command line takes precedence",
+			args:       []string{"--region=eu"},
+			env:        map[string]string{"APP_REGION_FILE": region},
+			wantRegion: "eu",
+			wantFilled: map[string]string{},
+		},
+		{
+			name:       "environment and file",
+			env:        map[string]string{"APP_REGION": "eu", "APP_API_KEY_FILE": key},
+			wantKey:    "s3cr3t",
+			wantRegion: "eu",
+			wantFilled: map[string]string{
+				"APP_REGION":       "eu",
+				"APP_API_KEY_FILE": "<redacted>",
+			},
+		},
+		{
+			name:    "both set",
+			env:     map[string]string{"APP_REGION": "eu", "APP_REGION_FILE": region},
+			wantErr: "APP_REGION and APP_REGION_FILE are both set",
+		},
+		{
+			name:    "missing file",
+			env:     map[string]string{"APP_REGION_FILE": missing},
+			wantErr: "reading APP_REGION_FILE: open " + missing,
+		},
+		{
+			name:    "invalid value",
+			env:     map[string]string{"APP_PORT_FILE": port},
+			wantErr: "invalid value in " + port + " for APP_PORT_FILE",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("fileFromEnv.Fill(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				var (
+					fs     flag.FlagSet
+					apiKey string
+					region string
+				)
+				fs.StringVar(&apiKey, "api-key", "", usage.New("the key").SetSensitive().String())
+				fs.StringVar(&region, "region", "", "the region")
+				fs.Int("port", 80, "the port")
+				assert.Nil(g, fs.Parse(tc.args))
+
+				lookupEnv := func(key string) (string, bool) {
+					value, ok := tc.env[key]
+					return value, ok
+				}
+				fe := newFileFromEnv(
+					newLookupFromEnv(tbnflag.NewFromEnv(&fs, "app"), &fs, lookupEnv, nil),
+					&fs,
+					lookupEnv,
+				)
+
+				err := fe.Fill()
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+					assert.False(g, strings.Contains(err.Error(), "eighty"))
+					return
+				}
+
+				assert.Nil(g, err)
+				assert.Equal(g, apiKey, tc.wantKey)
+				assert.Equal(g, region, tc.wantRegion)
+				assert.DeepEqual(g, fe.Filled(), tc.wantFilled)
+			},
+		)
+	}
+}
+
+func TestCLIRunFlagFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	key := writeFlagFile(t, dir, "key", "s3cr3t\n")
+
+	var apiKey, cmdKey string
+	cmd := &command.Cmd{
+		Name: "show",
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			fmt.Fprintf(cmd.Stdout(), "%s %s\n", apiKey, cmdKey)
+			return command.NoError()
+		}),
+	}
+	cmd.Flags.StringVar(&cmdKey, "key", "", usage.New("a key").SetSensitive().String())
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, cmd).(*cli)
+	c.flags.StringVar(&apiKey, "api-key", "", usage.New("the key").SetSensitive().String())
+	c.EnableFlagFiles()
+
+	var stdout, stderr bytes.Buffer
+	cmdErr := c.Run(
+		context.Background(),
+		[]string{"--api-key=@" + key, "show", "--key", "@@literal"},
+		map[string]string{},
+		command.Stdio{Stdout: &stdout, Stderr: &stderr},
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout.String(), "s3cr3t @literal\n")
+
+	// values read from files for sensitive flags are redacted in usage
+	c.newUsage = func(w io.Writer, mode style.Mode) app.Usage {
+		return c.app.StyledRedirectedUsage(w, style.Never)
+	}
+
+	stdout.Reset()
+	cmdErr = c.Run(
+		context.Background(),
+		[]string{"show", "--help"},
+		map[string]string{"APP_API_KEY_FILE": key, "APP_SHOW_KEY_FILE": key},
+		command.Stdio{Stdout: &stdout, Stderr: &stderr},
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.True(t, strings.Contains(stdout.String(), "APP_API_KEY_FILE=<redacted>"))
+	assert.True(t, strings.Contains(stdout.String(), "APP_SHOW_KEY_FILE=<redacted>"))
+	assert.False(t, strings.Contains(stdout.String(), "s3cr3t"))
+}
+
+func TestCLIRunFlagAndResponseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	key := writeFlagFile(t, dir, "key", "s3cr3t with spaces\n")
+	args := writeFlagFile(t, dir, "args", "show --key @@literal\n")
+
+	var apiKey, cmdKey string
+	cmd := &command.Cmd{
+		Name: "show",
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			fmt.Fprintf(cmd.Stdout(), "%s %s %q\n", apiKey, cmdKey, args)
+			return command.NoError()
+		}),
+	}
+	cmd.Flags.StringVar(&cmdKey, "key", "", usage.New("a key").SetSensitive().String())
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, cmd).(*cli)
+	c.flags.StringVar(&apiKey, "api-key", "", usage.New("the key").SetSensitive().String())
+	c.EnableFlagFiles()
+	c.EnableResponseFiles()
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"--api-key", "@" + key, "@" + args},
+			want: `s3cr3t with spaces @literal []` + "\n",
+		},
+		{
+			args: []string{"show", "--key", "@" + key, "@" + args},
+			want: ` s3cr3t with spaces ["show" "--key" "@@literal"]` + "\n",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q)", tc.args),
+			t,
+			func(g *assert.G) {
+				var stdout, stderr bytes.Buffer
+				cmdErr := c.Run(
+					context.Background(),
+					tc.args,
+					map[string]string{},
+					command.Stdio{Stdout: &stdout, Stderr: &stderr},
+				)
+				assert.Equal(g, cmdErr, command.NoError())
+				assert.Equal(g, stdout.String(), tc.want)
+				assert.Equal(g, stderr.String(), "")
+			},
+		)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+)
+
+// pluginExited is the Cause of the CmdErr returned when a plugin exits
+// with a non-zero status. The plugin is expected to have reported its own
+// error.
+type pluginExited struct {
+	*exec.ExitError
+}
+
+func (e pluginExited) Unwrap() error {
+	return e.ExitError
+}
+
+func isPluginExit(cmdErr command.CmdErr) bool {
+	_, ok := cmdErr.Cause.(pluginExited)
+	return ok
+}
+
+func (cli *cli) EnablePlugins() {
+	cli.pluginsEnabled = true
+}
+
+// pluginPath returns the directories in which plugins are found.
+func (cli *cli) pluginPath() []string {
+	path, _ := cli.lookupEnv("PATH")
+
+	dirs := []string{}
+	for _, dir := range filepath.SplitList(path) {
+		// an empty entry means the working directory, which is not
+		// searched for plugins
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// findPlugin returns the path of the executable for the plugin with the
+// given name, if plugins are enabled and it exists.
+func (cli *cli) findPlugin(name string) (string, bool) {
+	if !cli.pluginsEnabled || name == "" || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+
+	for _, dir := range cli.pluginPath() {
+		if path, err := exec.LookPath(filepath.Join(dir, cli.name+"-"+name)); err == nil {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// discoverPlugins returns the plugins found on the PATH, sorted by name.
+// Where several executables provide the same plugin, the first on the
+// PATH is used, as by findPlugin. Plugins which would be shadowed by a
+// command, or by help or version, are omitted.
+func (cli *cli) discoverPlugins() []app.Plugin {
+	if !cli.pluginsEnabled {
+		return nil
+	}
+
+	prefix := cli.name + "-"
+	seen := map[string]bool{"help": true, "version": true}
+	plugins := []app.Plugin{}
+
+	for _, dir := range cli.pluginPath() {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name := strings.TrimPrefix(entry.Name(), prefix)
+			if name == entry.Name() {
+				continue
+			}
+
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+
+			if name == "" || seen[strings.ToLower(name)] || cli.command(name) != nil {
+				continue
+			}
+
+			path, err := exec.LookPath(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				continue
+			}
+
+			seen[strings.ToLower(name)] = true
+			plugins = append(plugins, app.Plugin{Name: name, Path: path})
+		}
+	}
+
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// runPlugin runs the plugin executable at the given path with the given
+// arguments, the CLI's streams, and its environment extended with the
+// global flags.
+func (cli *cli) runPlugin(
+	ctx context.Context,
+	name string,
+	path string,
+	args []string,
+) command.CmdErr {
+	pluginCmd := &command.Cmd{Name: name}
+
+	// don't start the plugin if the context is already done
+	if err := ctx.Err(); err != nil {
+		return pluginCmd.Wrap(err)
+	}
+
+	c := exec.CommandContext(ctx, path, args...)
+	c.Stdin = cli.stdio.Stdin
+	c.Stdout = cli.stdio.Stdout
+	c.Stderr = cli.stdio.Stderr
+	c.Env = cli.pluginEnv()
+
+	err := c.Run()
+	if err == nil {
+		return command.NoError()
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+		return command.CmdErr{
+			Cmd:   pluginCmd,
+			Code:  command.CmdErrCode(exitErr.ExitCode()),
+			Cause: pluginExited{exitErr},
+		}
+	}
+
+	return pluginCmd.Wrapf(err, "running %s", path)
+}
+
+// pluginEnv returns the environment for plugins: the environment given to
+// Run, or the process environment, plus an environment variable for each
+// global flag which has been set, other than help, version, aliases, and
+// flags which are not filled from the environment.
+func (cli *cli) pluginEnv() []string {
+	env := os.Environ()
+	if cli.env != nil {
+		env = make([]string, 0, len(cli.env))
+		for key, value := range cli.env {
+			env = append(env, key+"="+value)
+		}
+		sort.Strings(env)
+	}
+
+	cli.flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "h", "help", "v", "version":
+			return
+		}
+		if _, ok := command.FlagAliasOf(f); ok {
+			return
+		}
+		if key, ok := envKey(cli.flagsFromEnv, f); ok {
+			env = append(env, key+"="+f.Value.String())
+		}
+	})
+
+	return env
+}
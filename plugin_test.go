@@ -0,0 +1,216 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+	"github.com/turbinelabs/test/assert"
+)
+
+const greetPlugin = `#!/bin/sh
+echo "hello $* ($APP_VERBOSE,$APP_NAME,$OTHER)"
+exit $APP_EXIT
+`
+
+type pluginFixture struct {
+	dir1, dir2 string
+}
+
+func newPluginFixture(t *testing.T) *pluginFixture {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin tests use shell scripts")
+	}
+
+	root, err := ioutil.TempDir("", "plugins")
+	assert.Nil(t, err)
+
+	f := &pluginFixture{filepath.Join(root, "1"), filepath.Join(root, "2")}
+	assert.Nil(t, os.Mkdir(f.dir1, 0755))
+	assert.Nil(t, os.Mkdir(f.dir2, 0755))
+
+	f.write(t, f.dir1, "app-greet", greetPlugin, 0755)
+	f.write(t, f.dir1, "app-echo", greetPlugin, 0755)
+	f.write(t, f.dir1, "app-data", "not executable", 0644)
+	f.write(t, f.dir2, "app-greet", "#!/bin/sh\necho shadowed\n", 0755)
+	f.write(t, f.dir2, "app-zap", "#!/bin/sh\necho zap\n", 0755)
+	f.write(t, f.dir2, "other-tool", "#!/bin/sh\n", 0755)
+
+	return f
+}
+
+func (f *pluginFixture) write(t *testing.T, dir, name, contents string, mode os.FileMode) {
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), mode))
+}
+
+func (f *pluginFixture) cleanup() {
+	os.RemoveAll(filepath.Dir(f.dir1))
+}
+
+func (f *pluginFixture) env(extra ...string) map[string]string {
+	env := map[string]string{
+		"PATH": f.dir1 + string(filepath.ListSeparator) + f.dir2,
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		env[extra[i]] = extra[i+1]
+	}
+	return env
+}
+
+func mkPluginCLI() *cli {
+	echo := &command.Cmd{Name: "echo", Summary: "echo", Runner: &echoRunner{}}
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, echo).(*cli)
+
+	var verbose bool
+	c.flags.BoolVar(&verbose, "verbose", false, "verbose")
+	var name string
+	c.flags.StringVar(&name, "name", "", "name")
+
+	c.EnablePlugins()
+	return c
+}
+
+func TestPluginRun(t *testing.T) {
+	f := newPluginFixture(t)
+	defer f.cleanup()
+
+	cmdErr, stdout, stderr := runCLI(
+		mkPluginCLI(),
+		[]string{"--verbose", "greet", "a", "--b"},
+		f.env("APP_NAME", "bob", "OTHER", "x", "APP_EXIT", "0"),
+		"",
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, "hello a --b (true,bob,x)\n")
+	assert.Equal(t, stderr, "")
+}
+
+func TestPluginRunHelp(t *testing.T) {
+	f := newPluginFixture(t)
+	defer f.cleanup()
+
+	cmdErr, stdout, _ := runCLI(mkPluginCLI(), []string{"help", "greet"}, f.env(), "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, "hello --help (,,)\n")
+}
+
+func TestPluginExitStatus(t *testing.T) {
+	f := newPluginFixture(t)
+	defer f.cleanup()
+
+	for _, code := range []string{"1", "2", "42"} {
+		cmdErr, stdout, stderr := runCLI(
+			mkPluginCLI(),
+			[]string{"greet"},
+			f.env("APP_EXIT", code),
+			"",
+		)
+		assert.Equal(t, cmdErr.Code, map[string]command.CmdErrCode{"1": 1, "2": 2, "42": 42}[code])
+		assert.True(t, isPluginExit(cmdErr))
+		assert.Equal(t, cmdErr.Cmd.Name, "greet")
+
+		// the plugin reports its own errors, and no usage is shown
+		assert.Equal(t, stdout, "hello  (,,)\n")
+		assert.Equal(t, stderr, "")
+	}
+}
+
+func TestPluginCommandTakesPrecedence(t *testing.T) {
+	f := newPluginFixture(t)
+	defer f.cleanup()
+
+	cmdErr, stdout, _ := runCLI(mkPluginCLI(), []string{"echo", "x"}, f.env(), "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, "x\n")
+}
+
+func TestPluginUnknownCommand(t *testing.T) {
+	f := newPluginFixture(t)
+	defer f.cleanup()
+
+	for _, args := range [][]string{{"data"}, {"tool"}, {"../1/app-greet"}} {
+		cmdErr, _, stderr := runCLI(mkPluginCLI(), args, f.env(), "")
+		assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+		assert.True(t, strings.Contains(stderr, "unknown command"))
+	}
+
+	c := mkPluginCLI()
+	c.pluginsEnabled = false
+	cmdErr, _, stderr := runCLI(c, []string{"greet"}, f.env(), "")
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+	assert.True(t, strings.Contains(stderr, `unknown command: "greet"`))
+}
+
+func TestPluginRequiredGlobalFlags(t *testing.T) {
+	f := newPluginFixture(t)
+	defer f.cleanup()
+
+	c := mkPluginCLI()
+	c.flags.String("token", "", usage.New("the token").SetRequired().String())
+
+	cmdErr, stdout, stderr := runCLI(c, []string{"greet"}, f.env(), "")
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+	assert.False(t, strings.Contains(stdout, "hello"))
+	assert.True(t, strings.Contains(stderr, "--token is a required global flag"))
+}
+
+func TestDiscoverPlugins(t *testing.T) {
+	f := newPluginFixture(t)
+	defer f.cleanup()
+
+	c := mkPluginCLI()
+	c.env = f.env()
+	assert.DeepEqual(t, c.discoverPlugins(), []app.Plugin{
+		{Name: "greet", Path: filepath.Join(f.dir1, "app-greet")},
+		{Name: "zap", Path: filepath.Join(f.dir2, "app-zap")},
+	})
+
+	c.pluginsEnabled = false
+	assert.Equal(t, len(c.discoverPlugins()), 0)
+}
+
+func TestPluginUsage(t *testing.T) {
+	f := newPluginFixture(t)
+	defer f.cleanup()
+
+	c := mkPluginCLI()
+	c.newUsage = func(w io.Writer, mode style.Mode) app.Usage {
+		return c.app.StyledRedirectedUsage(w, style.Never)
+	}
+
+	_, stdout, _ := runCLI(c, []string{"--help"}, f.env(), "")
+	assert.True(t, strings.Contains(stdout, "PLUGINS\n    greet"))
+	assert.True(t, strings.Contains(stdout, filepath.Join(f.dir2, "app-zap")))
+}
+
+func TestValidatePlugins(t *testing.T) {
+	assert.Nil(t, mkPluginCLI().Validate())
+
+	c := mkNew(app.App{Name: "app"}, &command.Cmd{Name: "app"}).(*cli)
+	c.EnablePlugins()
+	assert.ErrorContains(t, c.Validate(), "plugins require sub-commands")
+}
@@ -0,0 +1,404 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/config"
+	"github.com/turbinelabs/cli/shellwords"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+)
+
+const ProfileSummary = "Apply the flag values saved in the named configuration `profile`"
+const ProfileCmdSummary = "List, select, show, or save configuration profiles"
+
+// currentProfileKey names the profile used when none is specified, in the
+// unnamed section of the config file.
+const currentProfileKey = "profile"
+
+const profileUsage = "list | use <profile> | show [<profile>] | set <profile> <flag>=<value>..."
+
+const profileDescription = `Profiles are named sets of flag values, saved in the config file, which
+are applied to flags not given on the command line or in the environment.
+Global flags are saved by name, and command flags by the command name and
+flag name, separated by a dot: for example, deploy.region.
+
+The profile applied is named by the --profile flag or its environment
+variable, or otherwise by the profile last selected with "profile use".
+
+    list    List profiles, marking the selected profile with *
+    use     Select the profile applied when none is specified
+    show    Show the flag values in a profile, or in the selected profile
+    set     Save flag values in a profile, creating it if necessary`
+
+type profileRunner struct {
+	cli *cli
+}
+
+func (r profileRunner) Run(cmd *command.Cmd, args []string) command.CmdErr {
+	if len(args) == 0 {
+		return cmd.BadInput("no sub-command specified")
+	}
+
+	cfg, err := r.cli.readConfig()
+	if err != nil {
+		return cmd.Error(err)
+	}
+
+	subCmd, args := args[0], args[1:]
+	switch subCmd {
+	case "list":
+		return r.list(cmd, cfg, args)
+	case "use":
+		return r.use(cmd, cfg, args)
+	case "show":
+		return r.show(cmd, cfg, args)
+	case "set":
+		return r.set(cmd, cfg, args)
+	default:
+		return cmd.BadInputf("unknown sub-command: %q", subCmd)
+	}
+}
+
+func (r profileRunner) list(cmd *command.Cmd, cfg *config.File, args []string) command.CmdErr {
+	if len(args) > 0 {
+		return cmd.BadInputf("unexpected arguments: %s", shellwords.Join(args))
+	}
+
+	selected := r.cli.selectedProfile(cfg)
+	for _, name := range profileNames(cfg) {
+		marker := " "
+		if name == selected {
+			marker = "*"
+		}
+		fmt.Fprintf(cmd.Stdout(), "%s %s\n", marker, name)
+	}
+	return command.NoError()
+}
+
+func (r profileRunner) use(cmd *command.Cmd, cfg *config.File, args []string) command.CmdErr {
+	if len(args) != 1 {
+		return cmd.BadInput("use requires a profile name")
+	}
+
+	name := args[0]
+	if !cfg.HasSection(profileSection(name)) {
+		return cmd.BadInputf("profile %q not found", name)
+	}
+
+	cfg.Set("", currentProfileKey, name)
+	if err := r.cli.writeConfig(cfg); err != nil {
+		return cmd.Error(err)
+	}
+	return command.NoError()
+}
+
+func (r profileRunner) show(cmd *command.Cmd, cfg *config.File, args []string) command.CmdErr {
+	var name string
+	switch len(args) {
+	case 0:
+		name = r.cli.selectedProfile(cfg)
+		if name == "" {
+			return cmd.BadInput("no profile selected")
+		}
+	case 1:
+		name = args[0]
+	default:
+		return cmd.BadInputf("unexpected arguments: %s", shellwords.Join(args[1:]))
+	}
+
+	section := profileSection(name)
+	if !cfg.HasSection(section) {
+		return cmd.BadInputf("profile %q not found", name)
+	}
+
+	for _, key := range cfg.Keys(section) {
+		f := r.cli.profileFlag(key)
+		for _, value := range profileValues(cfg, section, key, f) {
+			if f != nil && usage.New(f.Usage).IsSensitive() {
+				value = "<redacted>"
+			}
+			fmt.Fprintf(cmd.Stdout(), "%s = %s\n", key, value)
+		}
+	}
+	return command.NoError()
+}
+
+func (r profileRunner) set(cmd *command.Cmd, cfg *config.File, args []string) command.CmdErr {
+	if len(args) < 2 {
+		return cmd.BadInput("set requires a profile name and at least one flag=value")
+	}
+
+	name := args[0]
+	if len(strings.Fields(name)) != 1 || strings.ContainsAny(name, "[]") {
+		return cmd.BadInputf("invalid profile name: %q", name)
+	}
+
+	section := profileSection(name)
+	replaced := map[string]bool{}
+	for _, arg := range args[1:] {
+		eq := strings.Index(arg, "=")
+		if eq < 0 {
+			return cmd.BadInputf("expected flag=value: %q", arg)
+		}
+
+		key, value := strings.TrimLeft(arg[:eq], "-"), arg[eq+1:]
+		f := r.cli.profileFlag(key)
+		if f == nil {
+			return cmd.BadInputf("unknown flag: %s", key)
+		}
+		if err := checkFlagValue(f, value); err != nil {
+			// don't echo secrets
+			if usage.New(f.Usage).IsSensitive() {
+				return cmd.BadInputf("invalid value for %s: %s", key, err)
+			}
+			return cmd.BadInputf("invalid value %q for %s: %s", value, key, err)
+		}
+
+		// repeatable flags take one assignment per value, replacing
+		// those saved before
+		if _, ok := command.UnwrapValue(f.Value).(command.RepeatableValue); ok {
+			if !replaced[key] {
+				cfg.Unset(section, key)
+				replaced[key] = true
+			}
+			cfg.Add(section, key, value)
+		} else {
+			cfg.Set(section, key, value)
+		}
+	}
+
+	if err := r.cli.writeConfig(cfg); err != nil {
+		return cmd.Error(err)
+	}
+	return command.NoError()
+}
+
+func (cli *cli) SetConfigFile(path string) {
+	cli.configFile = path
+}
+
+// configPath returns the path of the config file: the path given to
+// SetConfigFile, or by default, config.DefaultPathInEnv, in the
+// environment given to Run.
+func (cli *cli) configPath() string {
+	if cli.configFile != "" {
+		return cli.configFile
+	}
+	return config.DefaultPathInEnv(cli.name, cli.lookupEnv)
+}
+
+// readConfig reads the config file. If there is no config file path, as
+// when no home directory is set, the file is empty.
+func (cli *cli) readConfig() (*config.File, error) {
+	path := cli.configPath()
+	if path == "" {
+		return &config.File{}, nil
+	}
+	return config.Read(path)
+}
+
+// writeConfig writes the config file.
+func (cli *cli) writeConfig(cfg *config.File) error {
+	path := cli.configPath()
+	if path == "" {
+		return errors.New("no config file: the home directory is not set")
+	}
+	return cfg.Write(path)
+}
+
+func (cli *cli) EnableProfiles() {
+	cli.profilesEnabled = true
+
+	if cli.profileCmd != nil || !cli.app.HasSubCmds {
+		return
+	}
+
+	cli.profileCmd = &command.Cmd{
+		Name:        "profile",
+		Summary:     ProfileCmdSummary,
+		Usage:       profileUsage,
+		Description: profileDescription,
+		Runner:      profileRunner{cli},
+	}
+	cli.commands = append(cli.commands, cli.profileCmd)
+	cli.initFlagsFromEnv()
+}
+
+func (cli *cli) validateProfiles() error {
+	if !cli.profilesEnabled {
+		return nil
+	}
+
+	if !cli.app.HasSubCmds {
+		return errors.New("profiles require sub-commands")
+	}
+
+	for _, cmd := range cli.commands {
+		if cmd != cli.profileCmd && strings.EqualFold(cmd.Name, cli.profileCmd.Name) {
+			return errors.New(`profiles conflict with the "profile" command`)
+		}
+	}
+
+	return nil
+}
+
+func (cli *cli) addProfileFlagIfMissing(fs *flag.FlagSet, profile *string) {
+	if cli.profilesEnabled && fs.Lookup("profile") == nil {
+		fs.StringVar(profile, "profile", "", ProfileSummary)
+	}
+}
+
+func profileSection(name string) string {
+	return "profile " + name
+}
+
+// profileNames returns the names of the profiles in the config file, in
+// sorted order.
+func profileNames(cfg *config.File) []string {
+	names := []string{}
+	for _, section := range cfg.Sections() {
+		if strings.HasPrefix(section, "profile ") {
+			names = append(names, strings.TrimPrefix(section, "profile "))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectedProfile returns the name of the profile to apply: that given by
+// the profile flag, which may have been set from the environment, or
+// otherwise the current profile in the config file.
+func (cli *cli) selectedProfile(cfg *config.File) string {
+	if cli.profile != "" {
+		return cli.profile
+	}
+	name, _ := cfg.Get("", currentProfileKey)
+	return name
+}
+
+// profileFlag returns the flag named by a profile key: a global flag, or a
+// command flag qualified by its command name.
+func (cli *cli) profileFlag(key string) *flag.Flag {
+	if dot := strings.Index(key, "."); dot >= 0 {
+		if cmd := cli.command(key[:dot]); cmd != nil {
+			return cmd.Flags.Lookup(key[dot+1:])
+		}
+		return nil
+	}
+
+	switch key {
+	case "profile", "help", "h", "version", "v":
+		return nil
+	}
+	return cli.flags.Lookup(key)
+}
+
+// checkFlagValue returns an error if the flag does not accept the value,
+// restoring the flag's previous value afterward.
+func checkFlagValue(f *flag.Flag, value string) error {
+	defer command.ResetValue(f.Value, f.Value.String())
+	return f.Value.Set(value)
+}
+
+// profileValues returns the values assigned to the key in the section.
+// Repeatable flags may be assigned more than once, and take every value;
+// otherwise, the last assignment wins.
+func profileValues(cfg *config.File, section, key string, f *flag.Flag) []string {
+	if f != nil {
+		if _, ok := command.UnwrapValue(f.Value).(command.RepeatableValue); ok {
+			return cfg.GetAll(section, key)
+		}
+	}
+	value, _ := cfg.Get(section, key)
+	return []string{value}
+}
+
+// applyProfile sets the unset global flags, and the unset flags of the
+// given command, if non-nil, to the values saved in the selected profile.
+// Since profiles are managed by the profile command, they are not applied
+// to it, so that a missing profile can be corrected.
+func (cli *cli) applyProfile(cmd *command.Cmd) error {
+	if !cli.profilesEnabled || (cmd != nil && cmd == cli.profileCmd) {
+		return nil
+	}
+
+	cfg, err := cli.readConfig()
+	if err != nil {
+		return err
+	}
+
+	name := cli.selectedProfile(cfg)
+	if name == "" {
+		return nil
+	}
+
+	section := profileSection(name)
+	if !cfg.HasSection(section) {
+		return fmt.Errorf("profile %q not found", name)
+	}
+
+	globalSet := visited(&cli.flags)
+	var cmdSet map[string]bool
+	if cmd != nil {
+		cmdSet = visited(&cmd.Flags)
+	}
+
+	for _, key := range cfg.Keys(section) {
+		fs, flagName, set := &cli.flags, key, globalSet
+		if dot := strings.Index(key, "."); dot >= 0 {
+			if cmd == nil || !strings.EqualFold(key[:dot], cmd.Name) {
+				continue
+			}
+			fs, flagName, set = &cmd.Flags, key[dot+1:], cmdSet
+		}
+
+		f := fs.Lookup(flagName)
+		if f == nil || set[flagName] || cli.profileFlag(key) == nil {
+			continue
+		}
+
+		// the value may be secret, so is not included in the error
+		for _, value := range profileValues(cfg, section, key, f) {
+			if err := fs.Set(flagName, value); err != nil {
+				return fmt.Errorf("invalid value in profile %s for %s: %s", name, key, err)
+			}
+		}
+
+		if cli.profileFlags == nil {
+			cli.profileFlags = map[*flag.Flag]string{}
+		}
+		cli.profileFlags[f] = name
+	}
+
+	if err := command.ApplyAliases(&cli.flags); err != nil {
+		return fmt.Errorf("invalid value in profile %s: %s", name, err)
+	}
+	if cmd != nil {
+		if err := command.ApplyAliases(&cmd.Flags); err != nil {
+			return fmt.Errorf("invalid value in profile %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+	"github.com/turbinelabs/test/assert"
+)
+
+const testProfiles = `profile = dev
+
+[profile dev]
+api-url = http://localhost
+api-key = devkey
+deploy.region = us-west-1
+
+[profile prod]
+api-url = https://prod
+deploy.replicas = three
+`
+
+type profileFixture struct {
+	dir    string
+	path   string
+	cli    *cli
+	apiURL string
+	apiKey string
+	region string
+}
+
+func newProfileFixture(t *testing.T, contents string) *profileFixture {
+	dir, err := ioutil.TempDir("", "profiles")
+	assert.Nil(t, err)
+
+	f := &profileFixture{dir: dir, path: filepath.Join(dir, "app", "config")}
+	if contents != "" {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(f.path), 0700))
+		assert.Nil(t, ioutil.WriteFile(f.path, []byte(contents), 0600))
+	}
+
+	deploy := &command.Cmd{
+		Name: "deploy",
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			fmt.Fprintf(cmd.Stdout(), "%s %s %s\n", f.apiURL, f.apiKey, f.region)
+			return command.NoError()
+		}),
+	}
+	deploy.Flags.StringVar(&f.region, "region", "us-east-1", "the region")
+	deploy.Flags.Int("replicas", 1, "the number of replicas")
+
+	f.cli = mkNew(app.App{Name: "app", HasSubCmds: true}, deploy).(*cli)
+	f.cli.flags.StringVar(&f.apiURL, "api-url", "", usage.New("the API URL").SetRequired().String())
+	f.cli.flags.StringVar(&f.apiKey, "api-key", "", usage.New("the API key").SetSensitive().String())
+	f.cli.SetConfigFile(f.path)
+	f.cli.EnableProfiles()
+
+	return f
+}
+
+func (f *profileFixture) cleanup() {
+	os.RemoveAll(f.dir)
+}
+
+func (f *profileFixture) config(t *testing.T) string {
+	contents, err := ioutil.ReadFile(f.path)
+	assert.Nil(t, err)
+	return string(contents)
+}
+
+func TestProfilesApplied(t *testing.T) {
+	f := newProfileFixture(t, testProfiles)
+	defer f.cleanup()
+
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "current profile",
+			args: []string{"deploy"},
+			want: "http://localhost devkey us-west-1\n",
+		},
+		{
+			name: "flags take precedence",
+			args: []string{"--api-key=k", "deploy", "--region=eu"},
+			want: "http://localhost k eu\n",
+		},
+		{
+			name: "environment takes precedence",
+			args: []string{"deploy"},
+			env:  map[string]string{"APP_API_URL": "http://env", "APP_DEPLOY_REGION": "eu"},
+			want: "http://env devkey eu\n",
+		},
+		{
+			name: "profile flag",
+			args: []string{"--profile=prod", "deploy", "--replicas=3"},
+			want: "https://prod  us-east-1\n",
+		},
+		{
+			name: "profile environment variable",
+			args: []string{"deploy", "--replicas=3"},
+			env:  map[string]string{"APP_PROFILE": "prod"},
+			want: "https://prod  us-east-1\n",
+		},
+		{
+			name:    "invalid value",
+			args:    []string{"--profile=prod", "deploy"},
+			wantErr: "deploy: invalid value in profile prod for deploy.replicas",
+		},
+		{
+			name:    "missing profile",
+			args:    []string{"--profile=test", "deploy"},
+			wantErr: `deploy: profile "test" not found`,
+		},
+	} {
+		assert.Group(
+			tc.name,
+			t,
+			func(g *assert.G) {
+				env := map[string]string{}
+				for k, v := range tc.env {
+					env[k] = v
+				}
+
+				cmdErr, stdout, stderr := runCLI(f.cli, tc.args, env, "")
+				if tc.wantErr != "" {
+					assert.Equal(g, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+					assert.True(g, strings.Contains(stderr, tc.wantErr))
+				} else {
+					assert.Equal(g, cmdErr, command.NoError())
+					assert.Equal(g, stdout, tc.want)
+				}
+			},
+		)
+	}
+}
+
+func TestProfilesSatisfyRequiredFlags(t *testing.T) {
+	f := newProfileFixture(t, "")
+	defer f.cleanup()
+
+	cmdErr, _, stderr := runCLI(f.cli, []string{"deploy"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+	assert.True(t, strings.Contains(stderr, "--api-url is a required global flag"))
+
+	cmdErr, _, _ = runCLI(f.cli, []string{"profile", "set", "dev", "api-url=http://localhost"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+
+	cmdErr, stdout, _ := runCLI(f.cli, []string{"--profile=dev", "deploy"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, "http://localhost  us-east-1\n")
+}
+
+func TestProfilesRepeatableFlags(t *testing.T) {
+	f := newProfileFixture(t, `[profile dev]
+api-url = http://localhost
+deploy.host = a
+deploy.host = b, c
+deploy.label = env=dev
+deploy.region = us-west-1
+deploy.region = eu
+`)
+	defer f.cleanup()
+
+	var hosts []string
+	labels := map[string]string{"team": "ops"}
+	deploy := f.cli.command("deploy")
+	command.SliceVar(&deploy.Flags, &hosts, "host", "a host")
+	command.MapVar(&deploy.Flags, &labels, "label", "a label")
+	deploy.Runner = runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+		fmt.Fprintf(cmd.Stdout(), "%s %q %v\n", f.region, hosts, labels)
+		return command.NoError()
+	})
+
+	cmdErr, stdout, _ := runCLI(f.cli, []string{"--profile=dev", "deploy"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `eu ["a" "b" "c"] map[env:dev]`+"\n")
+
+	cmdErr, stdout, _ = runCLI(f.cli, []string{"profile", "show", "dev"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `api-url = http://localhost
+deploy.host = a
+deploy.host = b, c
+deploy.label = env=dev
+deploy.region = eu
+`)
+
+	// repeatable flags are saved one value per line, replacing those saved
+	// before
+	cmdErr, _, _ = runCLI(
+		f.cli,
+		[]string{"profile", "set", "dev", "deploy.host=d", "deploy.label=env=qa", "deploy.host=e"},
+		map[string]string{},
+		"",
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, f.config(t), `[profile dev]
+api-url = http://localhost
+deploy.region = us-west-1
+deploy.region = eu
+deploy.host = d
+deploy.label = env=qa
+deploy.host = e
+`)
+
+	cmdErr, stdout, _ = runCLI(f.cli, []string{"--profile=dev", "deploy"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `eu ["d" "e"] map[env:qa]`+"\n")
+
+	cmdErr, _, stderr := runCLI(f.cli, []string{"profile", "set", "dev", "deploy.label=qa"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+	assert.True(t, strings.Contains(stderr, `invalid value "qa" for deploy.label`))
+}
+
+func TestProfileCommand(t *testing.T) {
+	f := newProfileFixture(t, testProfiles)
+	defer f.cleanup()
+
+	for _, tc := range []struct {
+		args    []string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{args: []string{"profile", "list"}, want: "* dev\n  prod\n"},
+		{args: []string{"--profile=prod", "profile", "list"}, want: "  dev\n* prod\n"},
+		{
+			args: []string{"profile", "show"},
+			want: "api-url = http://localhost\napi-key = <redacted>\ndeploy.region = us-west-1\n",
+		},
+		{
+			args: []string{"profile", "show", "prod"},
+			want: "api-url = https://prod\ndeploy.replicas = three\n",
+		},
+		{args: []string{"profile", "show", "test"}, wantErr: `profile "test" not found`},
+		{args: []string{"profile", "show", "a", "b"}, wantErr: "unexpected arguments: b"},
+		{args: []string{"profile", "use"}, wantErr: "use requires a profile name"},
+		{args: []string{"profile", "use", "test"}, wantErr: `profile "test" not found`},
+		{args: []string{"profile", "set", "dev"}, wantErr: "set requires a profile name"},
+		{args: []string{"profile", "set", "a b", "x=1"}, wantErr: `invalid profile name: "a b"`},
+		{args: []string{"profile", "set", "dev", "region"}, wantErr: `expected flag=value: "region"`},
+		{args: []string{"profile", "set", "dev", "region=eu"}, wantErr: "unknown flag: region"},
+		{args: []string{"profile", "set", "dev", "profile=prod"}, wantErr: "unknown flag: profile"},
+		{args: []string{"profile", "set", "dev", "other.region=eu"}, wantErr: "unknown flag: other.region"},
+		{
+			args:    []string{"profile", "set", "dev", "deploy.replicas=many"},
+			wantErr: `invalid value "many" for deploy.replicas`,
+		},
+		{args: []string{"profile"}, wantErr: "no sub-command specified"},
+		{args: []string{"profile", "rm"}, wantErr: `unknown sub-command: "rm"`},
+	} {
+		assert.Group(
+			fmt.Sprintf("Run(%q)", tc.args),
+			t,
+			func(g *assert.G) {
+				cmdErr, stdout, stderr := runCLI(f.cli, tc.args, map[string]string{}, "")
+				if tc.wantErr != "" {
+					assert.Equal(g, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+					assert.True(g, strings.Contains(stderr, tc.wantErr))
+				} else {
+					assert.Equal(g, cmdErr, command.NoError())
+					assert.Equal(g, stdout, tc.want)
+				}
+			},
+		)
+	}
+
+	// the profile command is usable when the selected profile is missing
+	cmdErr, stdout, _ := runCLI(f.cli, []string{"--profile=test", "profile", "list"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, "  dev\n  prod\n")
+
+	cmdErr, _, _ = runCLI(f.cli, []string{"profile", "use", "prod"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+
+	cmdErr, _, _ = runCLI(
+		f.cli,
+		[]string{"profile", "set", "prod", "--deploy.replicas=3", "api-key= s3cr3t", "deploy.region=eu"},
+		map[string]string{},
+		"",
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+
+	assert.Equal(t, f.config(t), `profile = prod
+
+[profile dev]
+api-url = http://localhost
+api-key = devkey
+deploy.region = us-west-1
+
+[profile prod]
+api-url = https://prod
+deploy.replicas = 3
+api-key = " s3cr3t"
+deploy.region = eu
+`)
+
+	cmdErr, stdout, _ = runCLI(f.cli, []string{"deploy"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, "https://prod  s3cr3t eu\n")
+}
+
+func TestValidateProfiles(t *testing.T) {
+	f := newProfileFixture(t, "")
+	defer f.cleanup()
+	assert.Nil(t, f.cli.Validate())
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, &command.Cmd{Name: "profile"}).(*cli)
+	c.EnableProfiles()
+	assert.ErrorContains(t, c.Validate(), `profiles conflict with the "profile" command`)
+
+	c = mkNew(app.App{Name: "app"}, &command.Cmd{Name: "app"}).(*cli)
+	c.EnableProfiles()
+	assert.ErrorContains(t, c.Validate(), "profiles require sub-commands")
+}
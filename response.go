@@ -0,0 +1,104 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/turbinelabs/cli/shellwords"
+)
+
+func (cli *cli) EnableResponseFiles() {
+	cli.responseFiles = true
+}
+
+// expandResponseFiles replaces each @file argument with the arguments read
+// from the file, and a - argument with the arguments read from stdin. An
+// argument beginning with @@ is replaced by the argument without its first
+// @. Arguments following --, arguments read from files, and the values of
+// flags given as separate arguments, as in "--name @alice", are not
+// expanded; with flag files enabled, such values are flag files instead.
+func (cli *cli) expandResponseFiles(args []string) ([]string, error) {
+	if !cli.responseFiles {
+		return args, nil
+	}
+
+	flagValues := cli.flagValueArgs(args)
+	expanded := make([]string, 0, len(args))
+	readStdin := false
+	for i, arg := range args {
+		switch {
+		case flagValues[i]:
+			expanded = append(expanded, arg)
+
+		case arg == "--":
+			return append(expanded, args[i:]...), nil
+
+		case arg == "-":
+			if readStdin {
+				return nil, errors.New("response file stdin: may only be read once")
+			}
+			readStdin = true
+
+			data, err := ioutil.ReadAll(cli.stdio.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("response file stdin: %s", err)
+			}
+
+			fileArgs, err := splitResponseFile("stdin", data)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, fileArgs...)
+
+		case strings.HasPrefix(arg, "@@"):
+			expanded = append(expanded, arg[1:])
+
+		case strings.HasPrefix(arg, "@") && len(arg) > 1:
+			name := arg[1:]
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("response file %s: %s", name, err)
+			}
+
+			fileArgs, err := splitResponseFile(name, data)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, fileArgs...)
+
+		default:
+			expanded = append(expanded, arg)
+		}
+	}
+
+	return expanded, nil
+}
+
+// splitResponseFile splits the contents of a response file into
+// arguments, separated by whitespace, including newlines, and quoted as
+// by shellwords.Split.
+func splitResponseFile(name string, data []byte) ([]string, error) {
+	args, err := shellwords.Split(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("response file %s: %s", name, err)
+	}
+	return args, nil
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestExpandResponseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "response")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ids := filepath.Join(dir, "ids")
+	assert.Nil(t, ioutil.WriteFile(ids, []byte("1 2\n3\n'four five'\n@nested\n"), 0644))
+
+	bad := filepath.Join(dir, "bad")
+	assert.Nil(t, ioutil.WriteFile(bad, []byte("'unterminated\n"), 0644))
+
+	missing := filepath.Join(dir, "missing")
+
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		stdin   string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "no expansion",
+			args: []string{"a", "--b=@c", "@"},
+			want: []string{"a", "--b=@c", "@"},
+		},
+		{
+			name: "file",
+			args: []string{"a", "@" + ids, "z"},
+			want: []string{"a", "1", "2", "3", "four five", "@nested", "z"},
+		},
+		{
+			name:  "stdin",
+			args:  []string{"a", "-", "z"},
+			stdin: "x \"y z\"\n",
+			want:  []string{"a", "x", "y z", "z"},
+		},
+		{
+			name: "escaped",
+			args: []string{"@@handle", "@@@x"},
+			want: []string{"@handle", "@@x"},
+		},
+		{
+			name:  "end of flags",
+			args:  []string{"@" + ids, "--", "@" + ids, "-"},
+			stdin: "unread",
+			want:  []string{"1", "2", "3", "four five", "@nested", "--", "@" + ids, "-"},
+		},
+		{
+			name:    "missing file",
+			args:    []string{"@" + missing},
+			wantErr: "response file " + missing + ": open " + missing + ": no such file or directory",
+		},
+		{
+			name:    "bad quoting",
+			args:    []string{"@" + bad},
+			wantErr: "response file " + bad + ": unterminated quoted string",
+		},
+		{
+			name:    "stdin twice",
+			args:    []string{"-", "-"},
+			wantErr: "response file stdin: may only be read once",
+		},
+	} {
+		assert.Group(
+			fmt.Sprintf("expandResponseFiles(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				c := mkNew(app.App{Name: "app", HasSubCmds: true}).(*cli)
+				c.EnableResponseFiles()
+				c.stdio.Stdin = strings.NewReader(tc.stdin)
+
+				got, err := c.expandResponseFiles(tc.args)
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+					assert.Nil(g, got)
+				} else {
+					assert.Nil(g, err)
+					assert.DeepEqual(g, got, tc.want)
+				}
+			},
+		)
+	}
+}
+
+func TestExpandResponseFilesDisabled(t *testing.T) {
+	c := &cli{}
+	args := []string{"@ids", "-"}
+	got, err := c.expandResponseFiles(args)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, got, args)
+}
+
+func TestCLIRunResponseFilesFlagValues(t *testing.T) {
+	var env, name string
+	deploy := &command.Cmd{
+		Name: "deploy",
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			fmt.Fprintf(cmd.Stdout(), "%s %s %q\n", env, name, args)
+			return command.NoError()
+		}),
+	}
+	deploy.Flags.StringVar(&env, "env", "dev", "the environment")
+	deploy.Flags.StringVar(&name, "name", "", "the name")
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, deploy).(*cli)
+	c.EnableResponseFiles()
+
+	// flag values are not response files, even without flag files
+	cmdErr, stdout, _ := runCLI(
+		c,
+		[]string{"deploy", "--env", "-", "--name", "@alice", "x"},
+		map[string]string{},
+		"a b c",
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `- @alice ["x"]`+"\n")
+
+	cmdErr, stdout, _ = runCLI(c, []string{"deploy", "--env=prod", "-"}, map[string]string{}, "a b")
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, `prod  ["a" "b"]`+"\n")
+}
+
+func TestCLIRunResponseFiles(t *testing.T) {
+	echo := &command.Cmd{Name: "echo", Runner: &echoRunner{}}
+	echo.Flags.BoolVar(&echo.Runner.(*echoRunner).upper, "upper", false, "upper case")
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, echo).(*cli)
+	c.EnableResponseFiles()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmdErr := c.Run(
+		context.Background(),
+		[]string{"-", "b"},
+		map[string]string{},
+		command.Stdio{
+			Stdin:  strings.NewReader("echo --upper\na\n"),
+			Stdout: stdout,
+			Stderr: stderr,
+		},
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout.String(), "A B\n")
+
+	stdout.Reset()
+	cmdErr = c.Run(
+		context.Background(),
+		[]string{"echo", "@/nonexistent/file"},
+		map[string]string{},
+		command.Stdio{Stdout: stdout, Stderr: stderr},
+	)
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+	assert.True(t, strings.Contains(stderr.String(), "response file /nonexistent/file"))
+}
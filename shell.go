@@ -0,0 +1,342 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/shellwords"
+	"github.com/turbinelabs/cli/terminal"
+)
+
+const ShellSummary = "Start an interactive shell"
+
+const shellDescription = `Reads command lines from standard input and runs each in turn, as though
+it had been given on the command line, without exiting between them.
+Global options given before the shell command apply to every command line.
+Arguments are split using shell quoting rules. Enter exit or quit, or press
+Ctrl-D, to leave the shell.
+
+When run in a terminal, command lines may be edited, earlier command lines
+recalled with the up and down arrows, and command and flag names completed
+with tab.`
+
+// ShellOptions configure the shell command added by CLI.EnableShell.
+type ShellOptions struct {
+	// Prompt is displayed before each command line. If empty, the
+	// application name followed by "> " is used.
+	Prompt string
+
+	// HistoryFile, if non-empty, is the path of a file from which the
+	// shell's history is loaded when it starts, and to which it is saved
+	// when it exits.
+	HistoryFile string
+}
+
+type shellRunner struct {
+	cli *cli
+}
+
+func (r shellRunner) Run(cmd *command.Cmd, args []string) command.CmdErr {
+	if r.cli.inShell {
+		return cmd.BadInput("already running a shell")
+	}
+
+	if len(args) > 0 {
+		return cmd.BadInputf("unexpected arguments: %s", shellwords.Join(args))
+	}
+
+	r.cli.shellRequested = true
+	return command.NoError()
+}
+
+func (cli *cli) EnableShell(options ShellOptions) {
+	cli.shellEnabled = true
+	cli.shellOptions = options
+
+	if cli.shellCmd != nil || !cli.app.HasSubCmds {
+		return
+	}
+
+	cli.shellCmd = &command.Cmd{
+		Name:        "shell",
+		Summary:     ShellSummary,
+		Description: shellDescription,
+		Runner:      shellRunner{cli},
+	}
+	cli.commands = append(cli.commands, cli.shellCmd)
+	cli.initFlagsFromEnv()
+}
+
+func (cli *cli) validateShell() error {
+	if !cli.shellEnabled {
+		return nil
+	}
+
+	if !cli.app.HasSubCmds {
+		return errors.New("the shell requires sub-commands")
+	}
+
+	for _, cmd := range cli.commands {
+		if cmd != cli.shellCmd && strings.EqualFold(cmd.Name, cli.shellCmd.Name) {
+			return errors.New(`the shell conflicts with the "shell" command`)
+		}
+	}
+
+	return nil
+}
+
+// runShell reads and runs command lines until the input ends, the user
+// exits, or the context is done. Each command line is run as by Run,
+// following the given global flags, and its errors reported likewise.
+func (cli *cli) runShell(
+	ctx context.Context,
+	globalArgs []string,
+	env map[string]string,
+	stdio command.Stdio,
+) command.CmdErr {
+	stdio = defaultStdio(stdio)
+
+	lr := terminal.NewLineReader(stdio.Stdin, stdio.Stdout)
+	lr.Complete = cli.completeShellLine
+
+	if err := cli.readShellHistory(lr); err != nil {
+		cli.stderrShellCmdErr(stdio, cli.shellCmd.Errorf("reading history: %s", err))
+	}
+	defer func() {
+		if err := cli.writeShellHistory(lr); err != nil {
+			cli.stderrShellCmdErr(stdio, cli.shellCmd.Errorf("writing history: %s", err))
+		}
+	}()
+
+	cli.inShell = true
+	defer func() { cli.inShell = false }()
+
+	prompt := cli.shellOptions.Prompt
+	if prompt == "" {
+		prompt = cli.name + "> "
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return cli.stderrShellCmdErr(stdio, cli.shellCmd.Wrap(err))
+		}
+
+		line, err := lr.ReadLine(prompt)
+		if err == terminal.ErrInterrupted {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return cli.stderrShellCmdErr(stdio, cli.shellCmd.Error(err))
+		}
+
+		if exit := cli.runShellLine(ctx, lr, line, globalArgs, env, stdio); exit || err == io.EOF {
+			return command.NoError()
+		}
+	}
+}
+
+// runShellLine runs a single command line, returning true if the shell
+// should exit.
+func (cli *cli) runShellLine(
+	ctx context.Context,
+	lr *terminal.LineReader,
+	line string,
+	globalArgs []string,
+	env map[string]string,
+	stdio command.Stdio,
+) bool {
+	words, err := shellwords.Split(line)
+	if err != nil {
+		cli.stderrShellCmdErr(stdio, mkBadInput(err))
+		return false
+	}
+
+	if len(words) == 0 {
+		return false
+	}
+
+	lr.AddHistory(line)
+
+	if (words[0] == "exit" || words[0] == "quit") && cli.command(words[0]) == nil {
+		return true
+	}
+
+	args := append(append([]string{}, globalArgs...), words...)
+	cli.run(ctx, args, env, stdio)
+	return false
+}
+
+// stderrShellCmdErr writes the given CmdErr to the given stderr, outside
+// of a run, and returns it.
+func (cli *cli) stderrShellCmdErr(stdio command.Stdio, cmdErr command.CmdErr) command.CmdErr {
+	defer cli.useStdio(stdio)()
+	cli.stderrCmdErr(cmdErr)
+	return cmdErr
+}
+
+func (cli *cli) readShellHistory(lr *terminal.LineReader) error {
+	if cli.shellOptions.HistoryFile == "" {
+		return nil
+	}
+
+	f, err := os.Open(cli.shellOptions.HistoryFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return lr.ReadHistory(f)
+}
+
+func (cli *cli) writeShellHistory(lr *terminal.LineReader) error {
+	if cli.shellOptions.HistoryFile == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(cli.shellOptions.HistoryFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := lr.WriteHistory(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// completeShellLine completes the last word of a shell command line:
+// global flags or a command name, or once a command is given, its flags.
+func (cli *cli) completeShellLine(line string) (int, []string) {
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	words := strings.Fields(line[:start])
+
+	if strings.Contains(word, "=") {
+		return start, nil
+	}
+
+	i := skipFlags(&cli.flags, words)
+	switch {
+	case i == len(words) && strings.HasPrefix(word, "-"):
+		return start, flagCandidates(&cli.flags, word)
+
+	case i == len(words):
+		return start, cli.commandCandidates(word, "exit", "help", "quit", "version")
+
+	case words[i] == "help" && i == len(words)-1:
+		return start, cli.commandCandidates(word)
+
+	case strings.HasPrefix(word, "-"):
+		cmd := cli.command(words[i])
+		if cmd == nil {
+			return start, nil
+		}
+		addHelpFlagIfMissing(&cmd.Flags, &cli.cmdHelpFlag)
+		addVersionFlagIfMissing(&cmd.Flags, &cli.cmdVersionFlag)
+		return start, flagCandidates(&cmd.Flags, word)
+	}
+
+	return start, nil
+}
+
+// commandCandidates returns the names of visible commands, excluding the
+// shell, plugins, and the given builtins, which begin with prefix.
+func (cli *cli) commandCandidates(prefix string, builtins ...string) []string {
+	names := builtins
+	for _, cmd := range cli.commands {
+		if !cmd.Hidden && cmd != cli.shellCmd {
+			names = append(names, cmd.Name)
+		}
+	}
+	for _, plugin := range cli.discoverPlugins() {
+		names = append(names, plugin.Name)
+	}
+	aliases, _, _ := cli.aliases()
+	for _, alias := range aliases {
+		names = append(names, alias.Name)
+	}
+
+	candidates := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// flagCandidates returns the flags in the FlagSet which match the given
+// partial flag, written as in usage. As in usage, the -h and -v
+// shorthands, hidden flags, and aliases are omitted.
+func flagCandidates(fs *flag.FlagSet, partial string) []string {
+	prefix := strings.TrimLeft(partial, "-")
+	candidates := []string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "h" || f.Name == "v" || !strings.HasPrefix(f.Name, prefix) {
+			return
+		}
+		if _, ok := command.FlagAliasOf(f); ok || command.FlagHidden(f) {
+			return
+		}
+		if len(f.Name) == 1 {
+			candidates = append(candidates, "-"+f.Name)
+		} else {
+			candidates = append(candidates, "--"+f.Name)
+		}
+	})
+	return candidates
+}
+
+// skipFlags returns the index of the first word which is neither a flag
+// in the FlagSet nor the value of one.
+func skipFlags(fs *flag.FlagSet, words []string) int {
+	i := 0
+	for i < len(words) && strings.HasPrefix(words[i], "-") {
+		name := strings.TrimLeft(words[i], "-")
+		i++
+
+		if strings.Contains(name, "=") {
+			continue
+		}
+
+		if f := fs.Lookup(name); f == nil || isBoolFlag(f) {
+			continue
+		}
+
+		// the flag's value is the next word
+		i++
+	}
+
+	if i > len(words) {
+		return len(words)
+	}
+	return i
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/cli/style"
+	"github.com/turbinelabs/test/assert"
+)
+
+type echoRunner struct {
+	upper bool
+}
+
+func (r *echoRunner) Run(cmd *command.Cmd, args []string) command.CmdErr {
+	if len(args) == 0 {
+		return cmd.BadInput("nothing to echo")
+	}
+
+	out := strings.Join(args, " ")
+	if r.upper {
+		out = strings.ToUpper(out)
+	}
+	fmt.Fprintln(cmd.Stdout(), out)
+	return command.NoError()
+}
+
+func mkShellCLI(options ShellOptions) *cli {
+	echo := &command.Cmd{Name: "echo", Summary: "echo", Runner: &echoRunner{}}
+	echo.Flags.BoolVar(&echo.Runner.(*echoRunner).upper, "upper", false, "upper case")
+	echo.Flags.Bool("uptight", false, "unused")
+	echo.Flags.Bool("upside-down", false, "hidden")
+	command.SetFlagOptions(&echo.Flags, "upside-down", command.WithHidden())
+
+	secret := &command.Cmd{Name: "secret", Hidden: true, Runner: &echoRunner{}}
+	status := &command.Cmd{Name: "status", Runner: &echoRunner{}}
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, echo, secret, status).(*cli)
+	var verbose bool
+	c.flags.BoolVar(&verbose, "verbose", false, "verbose")
+	var profile string
+	c.flags.StringVar(&profile, "profile", "", "profile")
+	c.EnableShell(options)
+	return c
+}
+
+func TestShell(t *testing.T) {
+	c := mkShellCLI(ShellOptions{})
+
+	cmdErr, stdout, stderr := runCLI(
+		c,
+		[]string{"shell"},
+		map[string]string{},
+		"echo --upper 'a  b'\n"+
+			"\n"+
+			"echo c\n"+
+			"echo\n"+
+			"bogus\n"+
+			"echo 'unterminated\n"+
+			"shell\n"+
+			"exit\n"+
+			"echo not run\n",
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.True(t, strings.HasPrefix(stdout, "app> A  B\napp> app> c\napp> "))
+	assert.False(t, strings.Contains(stdout, "not run"))
+	assert.True(t, strings.Contains(stderr, "echo: nothing to echo\n"))
+	assert.True(t, strings.Contains(stderr, `unknown command: "bogus"`))
+	assert.True(t, strings.Contains(stderr, "unterminated quoted string"))
+	assert.True(t, strings.Contains(stderr, "shell: already running a shell"))
+}
+
+func TestShellGlobalArgsAndEOF(t *testing.T) {
+	c := mkShellCLI(ShellOptions{Prompt: "$ "})
+
+	var seen []string
+	c.command("status").Runner = runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+		seen = append(seen, c.flags.Lookup("profile").Value.String())
+		return command.NoError()
+	})
+
+	cmdErr, stdout, stderr := runCLI(
+		c,
+		[]string{"--profile", "prod", "shell"},
+		map[string]string{},
+		"status\nstatus",
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+	assert.Equal(t, stdout, "$ $ ")
+	assert.Equal(t, stderr, "")
+	assert.DeepEqual(t, seen, []string{"prod", "prod"})
+}
+
+func TestShellUnexpectedArgs(t *testing.T) {
+	c := mkShellCLI(ShellOptions{})
+	cmdErr, _, stderr := runCLI(c, []string{"shell", "echo"}, map[string]string{}, "")
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeBadInput))
+	assert.True(t, strings.Contains(stderr, "shell: unexpected arguments: echo"))
+}
+
+func TestShellCanceled(t *testing.T) {
+	c := mkShellCLI(ShellOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.command("status").Runner = runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+		cancel()
+		return command.NoError()
+	})
+
+	stderr := &bytes.Buffer{}
+	cmdErr := c.Run(
+		ctx,
+		[]string{"shell"},
+		map[string]string{},
+		command.Stdio{Stdin: strings.NewReader("status\nstatus\n"), Stderr: stderr},
+	)
+	assert.Equal(t, cmdErr.Code, command.CmdErrCode(command.CmdErrCodeError))
+	assert.Equal(t, stderr.String(), "shell: context canceled\n\n")
+}
+
+func TestShellHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shell")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	history := filepath.Join(dir, "history")
+	assert.Nil(t, ioutil.WriteFile(history, []byte("echo old\n"), 0600))
+
+	c := mkShellCLI(ShellOptions{HistoryFile: history})
+	cmdErr, _, _ := runCLI(c, []string{"shell"}, map[string]string{}, "echo new\n  \nquit\n")
+	assert.Equal(t, cmdErr, command.NoError())
+
+	contents, err := ioutil.ReadFile(history)
+	assert.Nil(t, err)
+	assert.Equal(t, string(contents), "echo old\necho new\nquit\n")
+}
+
+func TestShellUsage(t *testing.T) {
+	c := mkShellCLI(ShellOptions{})
+	c.newUsage = func(w io.Writer, mode style.Mode) app.Usage {
+		return c.app.StyledRedirectedUsage(w, style.Never)
+	}
+
+	_, stdout, _ := runCLI(c, []string{"--help"}, map[string]string{}, "")
+	assert.True(t, strings.Contains(stdout, ShellSummary))
+}
+
+func TestValidateShell(t *testing.T) {
+	c := mkShellCLI(ShellOptions{})
+	assert.Nil(t, c.Validate())
+
+	shell := &command.Cmd{Name: "shell"}
+	c = mkNew(app.App{Name: "app", HasSubCmds: true}, shell).(*cli)
+	c.EnableShell(ShellOptions{})
+	assert.ErrorContains(t, c.Validate(), `the shell conflicts with the "shell" command`)
+
+	c = mkNew(app.App{Name: "app"}, &command.Cmd{Name: "app"}).(*cli)
+	c.EnableShell(ShellOptions{})
+	assert.ErrorContains(t, c.Validate(), "the shell requires sub-commands")
+}
+
+func TestCompleteShellLine(t *testing.T) {
+	c := mkShellCLI(ShellOptions{})
+	// register the built-in global flags
+	c.parseGlobalFlags(nil)
+
+	for _, tc := range []struct {
+		line      string
+		wantStart int
+		want      []string
+	}{
+		{"", 0, []string{"echo", "exit", "help", "quit", "status", "version"}},
+		{"e", 0, []string{"echo", "exit"}},
+		{"--verbose ec", 10, []string{"echo"}},
+		{"--profile prod st", 15, []string{"status"}},
+		{"--profile=prod st", 15, []string{"status"}},
+		{"-", 0, []string{"--color", "--error-format", "--help", "--profile", "--verbose", "--version"}},
+		{"--v", 0, []string{"--verbose", "--version"}},
+		{"help ", 5, []string{"echo", "status"}},
+		{"help echo ", 10, nil},
+		{"echo --up", 5, []string{"--upper", "--uptight"}},
+		{"echo --ups", 5, nil},
+		{"echo -h", 5, []string{"--help"}},
+		{"echo --upper=t", 5, nil},
+		{"echo arg", 5, nil},
+		{"bogus --", 6, nil},
+	} {
+		assert.Group(
+			fmt.Sprintf("completeShellLine(%q)", tc.line),
+			t,
+			func(g *assert.G) {
+				start, got := c.completeShellLine(tc.line)
+				assert.Equal(g, start, tc.wantStart)
+				if tc.want == nil {
+					assert.Equal(g, len(got), 0)
+				} else {
+					assert.DeepEqual(g, got, tc.want)
+				}
+			},
+		)
+	}
+}
+
+type runnerFunc func(cmd *command.Cmd, args []string) command.CmdErr
+
+func (f runnerFunc) Run(cmd *command.Cmd, args []string) command.CmdErr {
+	return f(cmd, args)
+}
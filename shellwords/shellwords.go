@@ -0,0 +1,149 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The shellwords package splits command lines into arguments using a
+// subset of POSIX shell quoting rules, and quotes arguments so that they
+// survive such splitting.
+package shellwords
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrUnterminatedQuote is returned by Split if a single- or
+	// double-quoted string is not closed.
+	ErrUnterminatedQuote = errors.New("unterminated quoted string")
+
+	// ErrTrailingEscape is returned by Split if the input ends with an
+	// unescaped backslash.
+	ErrTrailingEscape = errors.New("trailing backslash")
+)
+
+// Split splits a command line into arguments. Arguments are separated by
+// unquoted whitespace. Within single quotes, all characters are literal.
+// Within double quotes, a backslash escapes only a double quote,
+// backslash, dollar sign, backquote or newline, and is otherwise literal.
+// Outside of quotes, a backslash escapes any character, and a backslash
+// followed by a newline is removed entirely. No other shell expansion is
+// performed.
+func Split(s string) ([]string, error) {
+	var (
+		args    = []string{}
+		buf     bytes.Buffer
+		inWord  bool
+		escaped bool
+		quote   rune
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '"' && !strings.ContainsRune("\"\\$`\n", r) {
+				buf.WriteRune('\\')
+			}
+			// a line continuation does not itself begin a word
+			if r != '\n' {
+				buf.WriteRune(r)
+				inWord = true
+			}
+
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				buf.WriteRune(r)
+			}
+
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				buf.WriteRune(r)
+			}
+
+		case r == '\\':
+			escaped = true
+
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+
+		case isSpace(r):
+			if inWord {
+				args = append(args, buf.String())
+				buf.Reset()
+				inWord = false
+			}
+
+		default:
+			buf.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if escaped {
+		return nil, ErrTrailingEscape
+	}
+
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+
+	if inWord {
+		args = append(args, buf.String())
+	}
+
+	return args, nil
+}
+
+// Quote returns a representation of the given argument that Split will
+// return as a single, unchanged argument.
+func Quote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+
+	if strings.IndexFunc(arg, needsQuoting) == -1 {
+		return arg
+	}
+
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// Join quotes each of the given arguments and joins them with spaces,
+// producing a command line that Split will return as the same arguments.
+func Join(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+func needsQuoting(r rune) bool {
+	return isSpace(r) || strings.ContainsRune("'\"\\$`!*?[](){}<>|&;#~", r)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shellwords
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestSplit(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{``, []string{}},
+		{"  \t\n ", []string{}},
+		{`a b  c`, []string{"a", "b", "c"}},
+		{` a	b
+c `, []string{"a", "b", "c"}},
+		{`'a b' c`, []string{"a b", "c"}},
+		{`"a b" c`, []string{"a b", "c"}},
+		{`a'b c'd`, []string{"ab cd"}},
+		{`''`, []string{""}},
+		{`"" x`, []string{"", "x"}},
+		{`'a\b'`, []string{`a\b`}},
+		{`"a\b"`, []string{`a\b`}},
+		{`"a\"b\\c\$d"`, []string{`a"b\c$d`}},
+		{`a\ b`, []string{"a b"}},
+		{`a\'b`, []string{"a'b"}},
+		{"a\\\nb", []string{"ab"}},
+		{"\"a\\\nb\"", []string{"ab"}},
+		{"app deploy \\\n    --env=prod", []string{"app", "deploy", "--env=prod"}},
+		{"a \\\n", []string{"a"}},
+		{"\\\nb", []string{"b"}},
+		{"'' \\\n b", []string{"", "b"}},
+		{`--flag="some value" arg`, []string{"--flag=some value", "arg"}},
+		{`'it'\''s'`, []string{"it's"}},
+	} {
+		assert.Group(fmt.Sprintf("Split(%q)", tc.in), t, func(g *assert.G) {
+			got, err := Split(tc.in)
+			assert.Nil(g, err)
+			assert.DeepEqual(g, got, tc.want)
+		})
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want error
+	}{
+		{`'abc`, ErrUnterminatedQuote},
+		{`"abc`, ErrUnterminatedQuote},
+		{`abc "def' ghi`, ErrUnterminatedQuote},
+		{`abc\`, ErrTrailingEscape},
+		{`"abc\`, ErrTrailingEscape},
+	} {
+		got, err := Split(tc.in)
+		assert.Equal(t, err, tc.want)
+		assert.Nil(t, got)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, Quote(""), "''")
+	assert.Equal(t, Quote("abc"), "abc")
+	assert.Equal(t, Quote("--flag=x,y"), "--flag=x,y")
+	assert.Equal(t, Quote("a b"), "'a b'")
+	assert.Equal(t, Quote("it's"), `'it'\''s'`)
+	assert.Equal(t, Quote("$HOME"), "'$HOME'")
+}
+
+func TestJoinRoundTrip(t *testing.T) {
+	args := []string{"app", "", "a b", "it's", `"quoted"`, `back\slash`, "tab\there", "$x"}
+	got, err := Split(Join(args))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, got, args)
+}
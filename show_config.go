@@ -0,0 +1,207 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/turbinelabs/cli/command"
+	tbnflag "github.com/turbinelabs/nonstdlib/flag"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+)
+
+const ShowConfigSummary = "Print the effective value, source, and environment variable of each flag, rather than running the command; `format` is text (the default) or json"
+
+// showConfigFormat is the value of the show-config flag, which may be
+// given without a value, like a boolean flag, to select text output.
+type showConfigFormat int
+
+const (
+	showConfigOff showConfigFormat = iota
+	showConfigText
+	showConfigJSON
+)
+
+func (f showConfigFormat) String() string {
+	switch f {
+	case showConfigText:
+		return "text"
+	case showConfigJSON:
+		return "json"
+	default:
+		return ""
+	}
+}
+
+func (f *showConfigFormat) Set(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "false":
+		*f = showConfigOff
+	case "true", "text":
+		*f = showConfigText
+	case "json":
+		*f = showConfigJSON
+	default:
+		return fmt.Errorf("unknown format %q: expected text or json", s)
+	}
+	return nil
+}
+
+func (f *showConfigFormat) IsBoolFlag() bool {
+	return true
+}
+
+// Sources of flag values reported by the show-config flag.
+const (
+	configSourceDefault = "default"
+	configSourceFlag    = "flag"
+	configSourceEnv     = "env"
+	configSourceEnvFile = "env-file"
+	configSourceFile    = "file"
+	configSourceProfile = "profile"
+)
+
+// configEntry describes the effective value of a flag. Origin is the path
+// of the env file or flag file, or the name of the profile, from which the
+// value was read, if any.
+type configEntry struct {
+	Command string `json:"command,omitempty"`
+	Flag    string `json:"flag"`
+	Value   string `json:"value"`
+	Source  string `json:"source"`
+	Origin  string `json:"origin,omitempty"`
+	Env     string `json:"env"`
+}
+
+func (cli *cli) EnableShowConfig() {
+	cli.showConfigEnabled = true
+}
+
+func (cli *cli) addShowConfigFlagIfMissing(fs *flag.FlagSet, format *showConfigFormat) {
+	if cli.showConfigEnabled && fs.Lookup("show-config") == nil {
+		fs.Var(format, "show-config", ShowConfigSummary)
+	}
+}
+
+// showConfig prints the effective configuration of the global flags, and
+// of the given command, if non-nil, whose flags set on the command line
+// are given.
+func (cli *cli) showConfig(cmd *command.Cmd, cmdArgFlags map[string]bool) command.CmdErr {
+	entries := []configEntry{}
+	if cli.app.HasSubCmds {
+		entries = cli.configEntries("", &cli.flags, cli.flagsFromEnv, cli.globalArgFlags)
+	}
+	if cmd != nil {
+		cmdName := ""
+		if cli.app.HasSubCmds {
+			cmdName = cmd.Name
+		}
+		entries = append(
+			entries,
+			cli.configEntries(cmdName, &cmd.Flags, cli.commandFlagsFromEnv(cmd), cmdArgFlags)...,
+		)
+	}
+
+	if cli.showConfigFormat == showConfigJSON {
+		enc := json.NewEncoder(cli.stdio.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			Flags []configEntry `json:"flags"`
+		}{entries})
+	} else {
+		writeConfigEntries(cli.stdio.Stdout, entries)
+	}
+
+	return command.NoError()
+}
+
+// configEntries describes each flag in the FlagSet, other than aliases and
+// those added for help, version, and the show-config flag itself. Flags set
+// on the command line are given.
+func (cli *cli) configEntries(
+	cmdName string,
+	fs *flag.FlagSet,
+	fe tbnflag.FromEnv,
+	argFlags map[string]bool,
+) []configEntry {
+	filled := fe.Filled()
+
+	entries := []configEntry{}
+	fs.VisitAll(func(f *flag.Flag) {
+		switch f.Name {
+		case "help", "h", "version", "v", "show-config":
+			return
+		}
+		if _, ok := command.FlagAliasOf(f); ok {
+			return
+		}
+
+		key, _ := envKey(fe, f)
+		entry := configEntry{
+			Command: cmdName,
+			Flag:    f.Name,
+			Value:   f.Value.String(),
+			Source:  configSourceDefault,
+			Env:     key,
+		}
+
+		if usage.New(f.Usage).IsSensitive() && entry.Value != "" {
+			entry.Value = "<redacted>"
+		}
+
+		if argFlags[f.Name] {
+			entry.Source = configSourceFlag
+		} else if profile, ok := cli.profileFlags[f]; ok {
+			entry.Source, entry.Origin = configSourceProfile, profile
+		} else if _, ok := filled[key+FileEnvSuffix]; ok {
+			entry.Source = configSourceFile
+			entry.Origin, _ = cli.lookupEnv(key + FileEnvSuffix)
+		} else if path, ok := cli.envFileKeys[key]; ok {
+			entry.Source, entry.Origin = configSourceEnvFile, path
+		} else if _, ok := filled[key]; ok {
+			entry.Source = configSourceEnv
+		}
+
+		entries = append(entries, entry)
+	})
+
+	return entries
+}
+
+func writeConfigEntries(w io.Writer, entries []configEntry) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FLAG\tVALUE\tSOURCE\tENVIRONMENT VARIABLE")
+	for _, entry := range entries {
+		name := "--" + entry.Flag
+		if entry.Command != "" {
+			name = entry.Command + " " + name
+		}
+
+		source := entry.Source
+		if entry.Origin != "" {
+			source = fmt.Sprintf("%s (%s)", source, entry.Origin)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, entry.Value, source, entry.Env)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/cli/app"
+	"github.com/turbinelabs/cli/command"
+	"github.com/turbinelabs/nonstdlib/flag/usage"
+	"github.com/turbinelabs/test/assert"
+)
+
+func TestShowConfigFormat(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		want    showConfigFormat
+		wantErr string
+	}{
+		{input: "true", want: showConfigText},
+		{input: "text", want: showConfigText},
+		{input: " JSON ", want: showConfigJSON},
+		{input: "false", want: showConfigOff},
+		{input: "yaml", wantErr: `unknown format "yaml": expected text or json`},
+	} {
+		assert.Group(
+			fmt.Sprintf("Set(%q)", tc.input),
+			t,
+			func(g *assert.G) {
+				format := showConfigText
+				err := format.Set(tc.input)
+				if tc.wantErr != "" {
+					assert.ErrorContains(g, err, tc.wantErr)
+				} else {
+					assert.Nil(g, err)
+					assert.Equal(g, format, tc.want)
+				}
+			},
+		)
+	}
+
+	var format showConfigFormat
+	assert.True(t, format.IsBoolFlag())
+}
+
+func TestShowConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "showconfig")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	envFile := writeFlagFile(t, dir, ".env", "APP_DEPLOY_ZONE=b\n")
+	keyFile := writeFlagFile(t, dir, "key", "s3cr3t\n")
+	configFile := writeFlagFile(t, dir, "config", "[profile dev]\napi-url = http://localhost\n")
+
+	ran := false
+	deploy := &command.Cmd{
+		Name: "deploy",
+		Runner: runnerFunc(func(cmd *command.Cmd, args []string) command.CmdErr {
+			ran = true
+			return command.NoError()
+		}),
+	}
+	deploy.Flags.String("region", "us-east-1", "the region")
+	deploy.Flags.String("zone", "a", "the zone")
+	deploy.Flags.Int("replicas", 1, "the number of replicas")
+
+	c := mkNew(app.App{Name: "app", HasSubCmds: true}, deploy).(*cli)
+	c.flags.String("api-url", "", "the API URL")
+	c.flags.String("api-key", "", usage.New("the API key").SetSensitive().String())
+	c.SetConfigFile(configFile)
+	c.EnableProfiles()
+	c.EnableEnvFile(envFile)
+	c.EnableFlagFiles()
+	c.EnableShowConfig()
+
+	run := func(args ...string) string {
+		var stdout bytes.Buffer
+		cmdErr := c.Run(
+			context.Background(),
+			args,
+			map[string]string{
+				"APP_PROFILE":      "dev",
+				"APP_API_KEY_FILE": keyFile,
+				"APP_DEPLOY_ZONE":  "",
+			},
+			command.Stdio{Stdout: &stdout},
+		)
+		assert.Equal(t, cmdErr, command.NoError())
+		return stdout.String()
+	}
+
+	got := run("deploy", "--replicas=3", "--show-config")
+	assert.False(t, ran)
+
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	assert.DeepEqual(t, lines, []string{
+		"FLAG VALUE SOURCE ENVIRONMENT VARIABLE",
+		"--api-key <redacted> file (" + keyFile + ") APP_API_KEY",
+		"--api-url http://localhost profile (dev) APP_API_URL",
+		"--color auto default APP_COLOR",
+		"--env-file " + envFile + " default APP_ENV_FILE",
+		"--error-format text default APP_ERROR_FORMAT",
+		"--profile dev env APP_PROFILE",
+		"deploy --region us-east-1 default APP_DEPLOY_REGION",
+		"deploy --replicas 3 flag APP_DEPLOY_REPLICAS",
+		"deploy --zone env APP_DEPLOY_ZONE",
+	})
+
+	var config struct {
+		Flags []configEntry `json:"flags"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(run("--show-config=json")), &config))
+	assert.Equal(t, len(config.Flags), 6)
+	assert.DeepEqual(t, config.Flags[0], configEntry{
+		Flag:   "api-key",
+		Value:  "<redacted>",
+		Source: "file",
+		Origin: keyFile,
+		Env:    "APP_API_KEY",
+	})
+
+	assert.Nil(t, json.Unmarshal([]byte(run("--show-config=json", "deploy")), &config))
+	assert.Equal(t, len(config.Flags), 9)
+	assert.DeepEqual(t, config.Flags[8], configEntry{
+		Command: "deploy",
+		Flag:    "zone",
+		Value:   "",
+		Source:  "env",
+		Env:     "APP_DEPLOY_ZONE",
+	})
+	assert.False(t, ran)
+
+	run("deploy")
+	assert.True(t, ran)
+}
+
+func TestShowConfigEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "showconfig")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	envFile := writeFlagFile(t, dir, ".env", "APP_ZONE=b\n")
+
+	cmd := &command.Cmd{Name: "app", Runner: runnerFunc(nil)}
+	cmd.Flags.String("zone", "a", "the zone")
+
+	c := mkNew(app.App{Name: "app"}, cmd).(*cli)
+	c.EnableEnvFile(filepath.Join(dir, ".env"))
+	c.EnableShowConfig()
+
+	var stdout bytes.Buffer
+	cmdErr := c.Run(
+		context.Background(),
+		[]string{"--show-config=json"},
+		map[string]string{},
+		command.Stdio{Stdout: &stdout},
+	)
+	assert.Equal(t, cmdErr, command.NoError())
+
+	var config struct {
+		Flags []configEntry `json:"flags"`
+	}
+	assert.Nil(t, json.Unmarshal(stdout.Bytes(), &config))
+	assert.DeepEqual(t, config.Flags[len(config.Flags)-1], configEntry{
+		Flag:   "zone",
+		Value:  "b",
+		Source: "env-file",
+		Origin: envFile,
+		Env:    "APP_ZONE",
+	})
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The style package provides ANSI text styling for command-line output, and
+// the logic to decide whether styling should be applied to a given writer
+// based on a Mode and the conventional NO_COLOR, CLICOLOR_FORCE and TERM
+// environment variables.
+package style
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tty "github.com/mattn/go-isatty"
+)
+
+// Mode determines when styling is applied. Mode implements flag.Value,
+// and may be used directly as a command-line flag.
+type Mode int
+
+const (
+	// Auto applies styling when the output is an interactive terminal,
+	// subject to the NO_COLOR, CLICOLOR_FORCE and TERM environment
+	// variables.
+	Auto Mode = iota
+
+	// Always applies styling, regardless of the output or environment.
+	Always
+
+	// Never disables styling.
+	Never
+)
+
+var modeNames = []string{"auto", "always", "never"}
+
+// String returns the name of the Mode.
+func (m Mode) String() string {
+	if m < Auto || int(m) >= len(modeNames) {
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+	return modeNames[m]
+}
+
+// Set sets the Mode from its name.
+func (m *Mode) Set(s string) error {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range modeNames {
+		if s == name {
+			*m = Mode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid color mode %q: must be one of %s", s, m.ValidValuesDescription())
+}
+
+// Get returns the name of the Mode.
+func (m *Mode) Get() interface{} {
+	return m.String()
+}
+
+// ValidValuesDescription describes the valid names of a Mode.
+func (m *Mode) ValidValuesDescription() string {
+	return `"auto", "always", or "never"`
+}
+
+// Enabled reports whether styling should be applied to output written to
+// the given io.Writer. In Auto mode, styling is disabled if NO_COLOR is
+// set to a non-empty value; otherwise it is enabled if CLICOLOR_FORCE is
+// set to a value other than "0"; otherwise it is disabled if TERM is
+// "dumb"; otherwise it is enabled only if the io.Writer is an *os.File
+// attached to a terminal.
+func (m Mode) Enabled(w io.Writer) bool {
+	return m.EnabledInEnv(w, os.LookupEnv)
+}
+
+// EnabledInEnv is like Enabled, but looks up environment variables with the
+// given function rather than in the process environment.
+func (m Mode) EnabledInEnv(w io.Writer, lookupEnv func(string) (string, bool)) bool {
+	switch m {
+	case Always:
+		return true
+	case Never:
+		return false
+	}
+
+	if noColor, ok := lookupEnv("NO_COLOR"); ok && noColor != "" {
+		return false
+	}
+
+	if force, ok := lookupEnv("CLICOLOR_FORCE"); ok && force != "0" {
+		return true
+	}
+
+	if term, _ := lookupEnv("TERM"); term == "dumb" {
+		return false
+	}
+
+	if f, ok := w.(*os.File); ok {
+		return tty.IsTerminal(f.Fd()) || tty.IsCygwinTerminal(f.Fd())
+	}
+
+	return false
+}
+
+// Style applies ANSI styling to strings. The zero value applies no
+// styling.
+type Style struct {
+	enabled bool
+}
+
+// New produces a Style that applies styling only if enabled is true.
+func New(enabled bool) Style {
+	return Style{enabled: enabled}
+}
+
+// For produces a Style appropriate for output written to the given
+// io.Writer in the given Mode.
+func For(w io.Writer, mode Mode) Style {
+	return New(mode.Enabled(w))
+}
+
+// Enabled returns true if the Style applies styling.
+func (s Style) Enabled() bool {
+	return s.enabled
+}
+
+const (
+	codeBold      = "1"
+	codeFaint     = "2"
+	codeUnderline = "4"
+	codeRed       = "31"
+	codeGreen     = "32"
+	codeYellow    = "33"
+	codeCyan      = "36"
+)
+
+func (s Style) apply(code, str string) string {
+	if !s.enabled {
+		return str
+	}
+	return "\033[" + code + "m" + str + "\033[0m"
+}
+
+// Bold renders the string in bold.
+func (s Style) Bold(str string) string {
+	return s.apply(codeBold, str)
+}
+
+// Underline renders the string underlined.
+func (s Style) Underline(str string) string {
+	return s.apply(codeUnderline, str)
+}
+
+// Faint renders the string with decreased intensity.
+func (s Style) Faint(str string) string {
+	return s.apply(codeFaint, str)
+}
+
+// Error renders the string in red, for error messages.
+func (s Style) Error(str string) string {
+	return s.apply(codeRed, str)
+}
+
+// Warning renders the string in yellow, for warnings.
+func (s Style) Warning(str string) string {
+	return s.apply(codeYellow, str)
+}
+
+// Success renders the string in green, for messages indicating success.
+func (s Style) Success(str string) string {
+	return s.apply(codeGreen, str)
+}
+
+// Info renders the string in cyan, for informational messages.
+func (s Style) Info(str string) string {
+	return s.apply(codeCyan, str)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package style
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	tbnflag "github.com/turbinelabs/nonstdlib/flag"
+	"github.com/turbinelabs/test/assert"
+)
+
+var (
+	_ flag.Getter              = new(Mode)
+	_ tbnflag.ConstrainedValue = new(Mode)
+)
+
+func withEnv(env map[string]string, f func()) {
+	saved := map[string]*string{}
+	for k, v := range env {
+		if prev, ok := os.LookupEnv(k); ok {
+			saved[k] = &prev
+		} else {
+			saved[k] = nil
+		}
+		if v == "<unset>" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+	}
+	defer func() {
+		for k, v := range saved {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}()
+	f()
+}
+
+func TestModeSet(t *testing.T) {
+	var m Mode
+	assert.Equal(t, m, Auto)
+	assert.Equal(t, m.String(), "auto")
+
+	assert.Nil(t, m.Set("always"))
+	assert.Equal(t, m, Always)
+	assert.Equal(t, m.Get(), "always")
+
+	assert.Nil(t, m.Set(" NEVER "))
+	assert.Equal(t, m, Never)
+
+	assert.ErrorContains(t, m.Set("sometimes"), `invalid color mode "sometimes"`)
+	assert.Equal(t, m, Never)
+
+	assert.Equal(t, Mode(99).String(), "Mode(99)")
+}
+
+func TestModeEnabled(t *testing.T) {
+	clean := map[string]string{
+		"NO_COLOR":       "<unset>",
+		"CLICOLOR_FORCE": "<unset>",
+		"TERM":           "xterm",
+	}
+
+	buf := &bytes.Buffer{}
+
+	withEnv(clean, func() {
+		assert.True(t, Always.Enabled(buf))
+		assert.False(t, Never.Enabled(buf))
+		assert.False(t, Auto.Enabled(buf))
+	})
+
+	for _, tc := range []struct {
+		env  map[string]string
+		mode Mode
+		want bool
+	}{
+		{map[string]string{"CLICOLOR_FORCE": "1"}, Auto, true},
+		{map[string]string{"CLICOLOR_FORCE": "0"}, Auto, false},
+		{map[string]string{"CLICOLOR_FORCE": "1", "NO_COLOR": "1"}, Auto, false},
+		{map[string]string{"CLICOLOR_FORCE": "1", "NO_COLOR": ""}, Auto, true},
+		{map[string]string{"CLICOLOR_FORCE": "1", "TERM": "dumb"}, Auto, true},
+		{map[string]string{"NO_COLOR": "1"}, Always, true},
+		{map[string]string{"CLICOLOR_FORCE": "1"}, Never, false},
+	} {
+		withEnv(clean, func() {
+			withEnv(tc.env, func() {
+				assert.Equal(t, tc.mode.Enabled(buf), tc.want)
+			})
+		})
+	}
+}
+
+func TestModeEnabledInEnv(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	for _, tc := range []struct {
+		env  map[string]string
+		mode Mode
+		want bool
+	}{
+		{map[string]string{}, Auto, false},
+		{map[string]string{"CLICOLOR_FORCE": "1"}, Auto, true},
+		{map[string]string{"CLICOLOR_FORCE": "1", "NO_COLOR": "1"}, Auto, false},
+		{map[string]string{"CLICOLOR_FORCE": "1", "NO_COLOR": ""}, Auto, true},
+		{map[string]string{"NO_COLOR": "1"}, Always, true},
+		{map[string]string{"CLICOLOR_FORCE": "1"}, Never, false},
+	} {
+		lookupEnv := func(k string) (string, bool) {
+			v, ok := tc.env[k]
+			return v, ok
+		}
+
+		// the process environment is ignored
+		withEnv(map[string]string{"CLICOLOR_FORCE": "1", "NO_COLOR": "<unset>"}, func() {
+			assert.Equal(t, tc.mode.EnabledInEnv(buf, lookupEnv), tc.want)
+		})
+	}
+}
+
+func TestStyle(t *testing.T) {
+	plain := Style{}
+	assert.False(t, plain.Enabled())
+	for _, f := range []func(string) string{
+		plain.Bold,
+		plain.Underline,
+		plain.Faint,
+		plain.Error,
+		plain.Warning,
+		plain.Success,
+		plain.Info,
+	} {
+		assert.Equal(t, f("text"), "text")
+	}
+
+	styled := New(true)
+	assert.True(t, styled.Enabled())
+	assert.Equal(t, styled.Bold("text"), "\033[1mtext\033[0m")
+	assert.Equal(t, styled.Underline("text"), "\033[4mtext\033[0m")
+	assert.Equal(t, styled.Faint("text"), "\033[2mtext\033[0m")
+	assert.Equal(t, styled.Error("text"), "\033[31mtext\033[0m")
+	assert.Equal(t, styled.Warning("text"), "\033[33mtext\033[0m")
+	assert.Equal(t, styled.Success("text"), "\033[32mtext\033[0m")
+	assert.Equal(t, styled.Info("text"), "\033[36mtext\033[0m")
+
+	assert.False(t, For(&bytes.Buffer{}, Never).Enabled())
+	assert.True(t, For(&bytes.Buffer{}, Always).Enabled())
+}
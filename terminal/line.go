@@ -0,0 +1,446 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terminal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tty "github.com/mattn/go-isatty"
+)
+
+// ErrInterrupted is returned by LineReader.ReadLine if Ctrl-C is pressed
+// while a line is being edited.
+var ErrInterrupted = errors.New("interrupted")
+
+// DefaultMaxHistory is the default value of LineReader.MaxHistory.
+const DefaultMaxHistory = 1000
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyNewline   = 10
+	keyCtrlK     = 11
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// A CompleteFunc returns candidate completions for the text before the
+// cursor, given as line. Each candidate replaces line[start:].
+type CompleteFunc func(line string) (start int, candidates []string)
+
+// A LineReader reads lines of input and maintains a history of them. When
+// both its input and output are attached to a terminal, lines may be
+// edited with the arrow keys and common Emacs-style control keys, earlier
+// lines recalled with the up and down arrows, and the word before the
+// cursor completed with tab. Otherwise, lines are read as by Prompt.
+type LineReader struct {
+	// Complete, if non-nil, is called when tab is pressed.
+	Complete CompleteFunc
+
+	// MaxHistory is the number of lines retained in the history.
+	MaxHistory int
+
+	in      io.Reader
+	out     io.Writer
+	fd      uintptr
+	editing bool
+	history []string
+}
+
+type fder interface {
+	Fd() uintptr
+}
+
+// NewLineReader produces a LineReader which reads from in and writes
+// prompts and edited lines to out.
+func NewLineReader(in io.Reader, out io.Writer) *LineReader {
+	r := &LineReader{MaxHistory: DefaultMaxHistory, in: in, out: out}
+
+	inFile, inOK := in.(fder)
+	outFile, outOK := out.(fder)
+	if inOK && outOK && tty.IsTerminal(inFile.Fd()) && tty.IsTerminal(outFile.Fd()) {
+		r.fd = inFile.Fd()
+		r.editing = true
+	}
+
+	return r
+}
+
+// ReadLine prints the prompt and reads a line of input, without its line
+// ending. If the input ends, the text read so far is returned with
+// io.EOF. If Ctrl-C is pressed while editing, ErrInterrupted is returned.
+// Lines are not added to the history automatically; see AddHistory.
+func (r *LineReader) ReadLine(prompt string) (string, error) {
+	if r.editing {
+		if restore, err := makeRaw(r.fd); err == nil {
+			defer restore()
+			return r.edit(prompt)
+		}
+	}
+
+	fmt.Fprint(r.out, prompt)
+	return readLine(r.in)
+}
+
+// AddHistory appends a line to the history, unless it is blank or repeats
+// the most recent line. The oldest lines are discarded to keep the
+// history within MaxHistory lines.
+func (r *LineReader) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return
+	}
+
+	r.history = append(r.history, line)
+	if excess := len(r.history) - r.MaxHistory; excess > 0 {
+		r.history = append([]string(nil), r.history[excess:]...)
+	}
+}
+
+// History returns the lines in the history, oldest first.
+func (r *LineReader) History() []string {
+	return append([]string(nil), r.history...)
+}
+
+// ReadHistory adds each line read from the given io.Reader to the
+// history, as by AddHistory.
+func (r *LineReader) ReadHistory(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		r.AddHistory(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// WriteHistory writes the history to the given io.Writer, one line per
+// line, oldest first.
+func (r *LineReader) WriteHistory(w io.Writer) error {
+	for _, line := range r.history {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineState is a line being edited.
+type lineState struct {
+	out    io.Writer
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+func (s *lineState) set(line []rune) {
+	s.buf = append([]rune(nil), line...)
+	s.pos = len(s.buf)
+}
+
+func (s *lineState) insert(ch rune) {
+	s.buf = append(s.buf, 0)
+	copy(s.buf[s.pos+1:], s.buf[s.pos:])
+	s.buf[s.pos] = ch
+	s.pos++
+}
+
+func (s *lineState) deleteBackward() {
+	if s.pos > 0 {
+		s.buf = append(s.buf[:s.pos-1], s.buf[s.pos:]...)
+		s.pos--
+	}
+}
+
+func (s *lineState) deleteForward() {
+	if s.pos < len(s.buf) {
+		s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+	}
+}
+
+func (s *lineState) deleteWord() {
+	start := s.pos
+	for start > 0 && unicode.IsSpace(s.buf[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(s.buf[start-1]) {
+		start--
+	}
+	s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+	s.pos = start
+}
+
+// refresh redraws the prompt and line, and positions the cursor.
+func (s *lineState) refresh() {
+	fmt.Fprintf(s.out, "\r%s%s\x1b[K", s.prompt, string(s.buf))
+	if n := len(s.buf) - s.pos; n > 0 {
+		fmt.Fprintf(s.out, "\x1b[%dD", n)
+	}
+}
+
+// edit reads and edits a line, assuming the terminal is in raw mode.
+func (r *LineReader) edit(prompt string) (string, error) {
+	s := &lineState{out: r.out, prompt: prompt}
+
+	// histIdx is the position in the history being displayed; the line
+	// being edited is saved while earlier lines are recalled
+	histIdx := len(r.history)
+	var saved []rune
+
+	prev := func() {
+		if histIdx > 0 {
+			if histIdx == len(r.history) {
+				saved = append([]rune(nil), s.buf...)
+			}
+			histIdx--
+			s.set([]rune(r.history[histIdx]))
+		}
+	}
+
+	next := func() {
+		if histIdx < len(r.history) {
+			histIdx++
+			if histIdx == len(r.history) {
+				s.set(saved)
+			} else {
+				s.set([]rune(r.history[histIdx]))
+			}
+		}
+	}
+
+	s.refresh()
+	for {
+		ch, err := r.readRune()
+		if err != nil {
+			fmt.Fprint(r.out, "\r\n")
+			return string(s.buf), err
+		}
+
+		switch ch {
+		case keyEnter, keyNewline:
+			fmt.Fprint(r.out, "\r\n")
+			return string(s.buf), nil
+
+		case keyCtrlC:
+			fmt.Fprint(r.out, "^C\r\n")
+			return "", ErrInterrupted
+
+		case keyCtrlD:
+			if len(s.buf) == 0 {
+				fmt.Fprint(r.out, "\r\n")
+				return "", io.EOF
+			}
+			s.deleteForward()
+
+		case keyBackspace, keyCtrlH:
+			s.deleteBackward()
+
+		case keyCtrlA:
+			s.pos = 0
+
+		case keyCtrlE:
+			s.pos = len(s.buf)
+
+		case keyCtrlB:
+			if s.pos > 0 {
+				s.pos--
+			}
+
+		case keyCtrlF:
+			if s.pos < len(s.buf) {
+				s.pos++
+			}
+
+		case keyCtrlK:
+			s.buf = s.buf[:s.pos]
+
+		case keyCtrlU:
+			s.buf = append([]rune(nil), s.buf[s.pos:]...)
+			s.pos = 0
+
+		case keyCtrlW:
+			s.deleteWord()
+
+		case keyCtrlP:
+			prev()
+
+		case keyCtrlN:
+			next()
+
+		case keyTab:
+			r.complete(s)
+
+		case keyEscape:
+			key, err := r.readEscape()
+			if err != nil {
+				fmt.Fprint(r.out, "\r\n")
+				return string(s.buf), err
+			}
+
+			switch key {
+			case "A":
+				prev()
+			case "B":
+				next()
+			case "C":
+				if s.pos < len(s.buf) {
+					s.pos++
+				}
+			case "D":
+				if s.pos > 0 {
+					s.pos--
+				}
+			case "H", "1~", "7~":
+				s.pos = 0
+			case "F", "4~", "8~":
+				s.pos = len(s.buf)
+			case "3~":
+				s.deleteForward()
+			}
+
+		default:
+			if unicode.IsPrint(ch) {
+				s.insert(ch)
+			}
+		}
+
+		s.refresh()
+	}
+}
+
+// readEscape reads the remainder of an escape sequence following ESC,
+// returning its final characters: for example, "A" for the up arrow
+// (ESC [ A) or "3~" for delete (ESC [ 3 ~). Unrecognized sequences
+// return the empty string.
+func (r *LineReader) readEscape() (string, error) {
+	ch, err := r.readRune()
+	if err != nil || (ch != '[' && ch != 'O') {
+		return "", err
+	}
+
+	key := ""
+	for {
+		ch, err := r.readRune()
+		if err != nil {
+			return "", err
+		}
+		key += string(ch)
+		if ch < '0' || ch > '9' {
+			return key, nil
+		}
+	}
+}
+
+// complete replaces the word before the cursor with the longest common
+// prefix of its completions, or if that makes no progress, lists them.
+func (r *LineReader) complete(s *lineState) {
+	if r.Complete == nil {
+		return
+	}
+
+	line := string(s.buf[:s.pos])
+	start, candidates := r.Complete(line)
+	if len(candidates) == 0 || start < 0 || start > len(line) {
+		return
+	}
+
+	word := line[start:]
+	prefix := commonPrefix(candidates)
+	if len(candidates) == 1 {
+		prefix += " "
+	}
+
+	if len(prefix) > len(word) {
+		head := []rune(line[:start] + prefix)
+		s.buf = append(head, s.buf[s.pos:]...)
+		s.pos = len(head)
+		return
+	}
+
+	fmt.Fprintf(r.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+}
+
+func commonPrefix(strs []string) string {
+	prefix := []rune(strs[0])
+	for _, str := range strs[1:] {
+		i := 0
+		for _, ch := range str {
+			if i >= len(prefix) || prefix[i] != ch {
+				break
+			}
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return string(prefix)
+}
+
+// readRune reads a single UTF-8 encoded rune, one byte at a time, so
+// that no input intended for other consumers is buffered.
+func (r *LineReader) readRune() (rune, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if b < utf8.RuneSelf {
+		return rune(b), nil
+	}
+
+	buf := []byte{b}
+	for !utf8.FullRune(buf) {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		buf = append(buf, b)
+	}
+
+	ch, _ := utf8.DecodeRune(buf)
+	return ch, nil
+}
+
+func (r *LineReader) readByte() (byte, error) {
+	var b [1]byte
+	for {
+		n, err := r.in.Read(b[:])
+		if n == 1 {
+			return b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/turbinelabs/test/assert"
+)
+
+func mkEditingLineReader(input string) (*LineReader, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	r := NewLineReader(strings.NewReader(input), out)
+	r.editing = true
+	return r, out
+}
+
+func TestNewLineReaderNotATerminal(t *testing.T) {
+	r := NewLineReader(strings.NewReader(""), &bytes.Buffer{})
+	assert.False(t, r.editing)
+	assert.Equal(t, r.MaxHistory, DefaultMaxHistory)
+}
+
+func TestLineReaderReadLinePlain(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := NewLineReader(strings.NewReader("one\r\ntwo"), out)
+
+	line, err := r.ReadLine("> ")
+	assert.Nil(t, err)
+	assert.Equal(t, line, "one")
+
+	line, err = r.ReadLine("> ")
+	assert.Equal(t, err, io.EOF)
+	assert.Equal(t, line, "two")
+
+	assert.Equal(t, out.String(), "> > ")
+}
+
+func TestLineReaderEdit(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain", input: "abc\r", want: "abc"},
+		{name: "newline", input: "abc\n", want: "abc"},
+		{name: "backspace", input: "abx\x7fc\r", want: "abc"},
+		{name: "left arrow", input: "ac\x1b[Db\r", want: "abc"},
+		{name: "right arrow", input: "ac\x02\x1b[C\x1b[Cb\r", want: "acb"},
+		{name: "home and end", input: "bc\x1b[Ha\x1b[Fd\r", want: "abcd"},
+		{name: "ctrl-a and ctrl-e", input: "bc\x01a\x05d\r", want: "abcd"},
+		{name: "delete", input: "abxc\x02\x02\x1b[3~\r", want: "abc"},
+		{name: "ctrl-d deletes forward", input: "abxc\x02\x02\x04\r", want: "abc"},
+		{name: "ctrl-k", input: "abcdef\x02\x02\x02\x0b\r", want: "abc"},
+		{name: "ctrl-u", input: "xyzabc\x02\x02\x02\x15\r", want: "abc"},
+		{name: "ctrl-w", input: "abc def  \x17\r", want: "abc "},
+		{name: "unicode", input: "héllo 世\x7f界\r", want: "héllo 界"},
+		{name: "ignores control characters", input: "a\x00b\x1bXc\r", want: "abc"},
+	} {
+		assert.Group(
+			fmt.Sprintf("edit(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				r, _ := mkEditingLineReader(tc.input)
+				line, err := r.edit("> ")
+				assert.Nil(g, err)
+				assert.Equal(g, line, tc.want)
+			},
+		)
+	}
+}
+
+func TestLineReaderEditOutput(t *testing.T) {
+	r, out := mkEditingLineReader("ab\x1b[D\r")
+	line, err := r.edit("> ")
+	assert.Nil(t, err)
+	assert.Equal(t, line, "ab")
+	assert.Equal(
+		t,
+		out.String(),
+		"\r> \x1b[K"+"\r> a\x1b[K"+"\r> ab\x1b[K"+"\r> ab\x1b[K\x1b[1D"+"\r\n",
+	)
+}
+
+func TestLineReaderEditInterrupt(t *testing.T) {
+	r, out := mkEditingLineReader("abc\x03")
+	line, err := r.edit("> ")
+	assert.Equal(t, err, ErrInterrupted)
+	assert.Equal(t, line, "")
+	assert.True(t, strings.HasSuffix(out.String(), "^C\r\n"))
+}
+
+func TestLineReaderEditEOF(t *testing.T) {
+	r, _ := mkEditingLineReader("\x04")
+	line, err := r.edit("> ")
+	assert.Equal(t, err, io.EOF)
+	assert.Equal(t, line, "")
+
+	r, _ = mkEditingLineReader("abc")
+	line, err = r.edit("> ")
+	assert.Equal(t, err, io.EOF)
+	assert.Equal(t, line, "abc")
+}
+
+func TestLineReaderEditHistory(t *testing.T) {
+	r, _ := mkEditingLineReader(
+		// recall the previous line
+		"\x1b[A\r" +
+			// recall the first line, then return to the edited line
+			"new\x1b[A\x1b[A\x1b[B\x1b[B\r" +
+			// ctrl-p and ctrl-n
+			"\x10\x10\x0e!\r",
+	)
+	r.AddHistory("first")
+	r.AddHistory("second")
+
+	line, err := r.edit("> ")
+	assert.Nil(t, err)
+	assert.Equal(t, line, "second")
+
+	line, err = r.edit("> ")
+	assert.Nil(t, err)
+	assert.Equal(t, line, "new")
+
+	line, err = r.edit("> ")
+	assert.Nil(t, err)
+	assert.Equal(t, line, "second!")
+}
+
+func TestLineReaderEditComplete(t *testing.T) {
+	words := []string{"status", "start", "stop", "--verbose"}
+	complete := func(line string) (int, []string) {
+		start := strings.LastIndex(line, " ") + 1
+		candidates := []string{}
+		for _, w := range words {
+			if strings.HasPrefix(w, line[start:]) {
+				candidates = append(candidates, w)
+			}
+		}
+		return start, candidates
+	}
+
+	for _, tc := range []struct {
+		name     string
+		input    string
+		want     string
+		wantList bool
+	}{
+		{name: "unique", input: "x --v\t\r", want: "x --verbose "},
+		{name: "common prefix", input: "sta\t\r", want: "sta"},
+		{name: "extends common prefix", input: "s\t\r", want: "st"},
+		{name: "lists candidates", input: "st\t\r", want: "st", wantList: true},
+		{name: "no candidates", input: "x\t\r", want: "x"},
+		{name: "mid-line", input: "sto arg\x01\x06\x06\x06\t\r", want: "stop  arg"},
+	} {
+		assert.Group(
+			fmt.Sprintf("complete(%s)", tc.name),
+			t,
+			func(g *assert.G) {
+				r, out := mkEditingLineReader(tc.input)
+				r.Complete = complete
+				line, err := r.edit("> ")
+				assert.Nil(g, err)
+				assert.Equal(g, line, tc.want)
+				assert.Equal(
+					g,
+					strings.Contains(out.String(), "\r\nstatus  start  stop\r\n"),
+					tc.wantList,
+				)
+			},
+		)
+	}
+}
+
+func TestLineReaderHistory(t *testing.T) {
+	r := NewLineReader(strings.NewReader(""), &bytes.Buffer{})
+	r.MaxHistory = 3
+
+	assert.Nil(t, r.ReadHistory(strings.NewReader("a\nb\n\n  \nb\nc\n")))
+	assert.DeepEqual(t, r.History(), []string{"a", "b", "c"})
+
+	r.AddHistory("d")
+	assert.DeepEqual(t, r.History(), []string{"b", "c", "d"})
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, r.WriteHistory(buf))
+	assert.Equal(t, buf.String(), "b\nc\nd\n")
+}
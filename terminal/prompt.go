@@ -31,9 +31,13 @@ import (
 // stdin.
 func Prompt(os tbnos.OS, prompt string, args ...interface{}) (string, error) {
 	fmt.Fprintf(os.Stdout(), prompt, args...)
+	return readLine(os.Stdin())
+}
 
+// readLine reads a line from the given io.Reader, one byte at a time, as
+// described by Prompt.
+func readLine(in io.Reader) (string, error) {
 	buffer := make([]byte, 128)
-	in := os.Stdin()
 	n := 0
 	eof := false
 	for {
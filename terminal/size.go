@@ -0,0 +1,114 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terminal
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultColumns is the width reported by GetSize when no other
+	// source of terminal size information is available.
+	DefaultColumns = 80
+
+	// DefaultRows is the height reported by GetSize when no other
+	// source of terminal size information is available.
+	DefaultRows = 24
+)
+
+// Size represents the dimensions of a terminal, in character cells.
+type Size struct {
+	Columns int
+	Rows    int
+}
+
+// SizeOf queries the terminal attached to the given file descriptor
+// for its size. An error is returned if the descriptor does not refer
+// to a terminal, or if the platform does not support the query.
+func SizeOf(fd uintptr) (Size, error) {
+	return sizeOf(fd)
+}
+
+// GetSize returns the size of the terminal attached to the given file
+// descriptor. If the terminal cannot be queried, or reports a zero
+// dimension, the COLUMNS and LINES environment variables are
+// consulted, and failing that DefaultColumns and DefaultRows are
+// used.
+func GetSize(fd uintptr) Size {
+	size, _ := SizeOf(fd)
+
+	if size.Columns <= 0 {
+		size.Columns = intFromEnv("COLUMNS", DefaultColumns)
+	}
+
+	if size.Rows <= 0 {
+		size.Rows = intFromEnv("LINES", DefaultRows)
+	}
+
+	return size
+}
+
+func intFromEnv(key string, defaultValue int) int {
+	if i, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil && i > 0 {
+		return i
+	}
+	return defaultValue
+}
+
+// NotifyResize causes the current size of the terminal attached to the
+// given file descriptor, as determined by GetSize, to be sent on the
+// given channel whenever the terminal is resized. Sends do not block:
+// if the channel is not ready to receive, the notification is
+// dropped, so callers should use a buffered channel. The returned
+// function stops notifications; it is safe to call more than once. On
+// platforms without resize signals, no notifications are ever sent.
+func NotifyResize(fd uintptr, c chan<- Size) func() {
+	if c == nil {
+		panic("terminal: NotifyResize using nil channel")
+	}
+
+	signals, stopSignals := notifyResizeSignals()
+	if signals == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+				select {
+				case c <- GetSize(fd):
+				default:
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			stopSignals()
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2018 Turbine Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terminal
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/turbinelabs/test/assert"
+)
+
+func withEnv(t *testing.T, env map[string]string, f func()) {
+	saved := map[string]*string{}
+	for k, v := range env {
+		if prev, ok := os.LookupEnv(k); ok {
+			saved[k] = &prev
+		} else {
+			saved[k] = nil
+		}
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+	}
+	defer func() {
+		for k, v := range saved {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}()
+	f()
+}
+
+func notATerminal(t *testing.T) *os.File {
+	f, err := ioutil.TempFile("", "size_test")
+	assert.Nil(t, err)
+	return f
+}
+
+func TestSizeOfNotATerminal(t *testing.T) {
+	f := notATerminal(t)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := SizeOf(f.Fd())
+	assert.NonNil(t, err)
+	assert.Equal(t, size, Size{})
+}
+
+func TestGetSizeFromEnv(t *testing.T) {
+	f := notATerminal(t)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	withEnv(t, map[string]string{"COLUMNS": "132", "LINES": "50"}, func() {
+		assert.Equal(t, GetSize(f.Fd()), Size{Columns: 132, Rows: 50})
+	})
+
+	withEnv(t, map[string]string{"COLUMNS": " 100 ", "LINES": ""}, func() {
+		assert.Equal(t, GetSize(f.Fd()), Size{Columns: 100, Rows: DefaultRows})
+	})
+}
+
+func TestGetSizeDefaults(t *testing.T) {
+	f := notATerminal(t)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	for _, env := range []map[string]string{
+		{"COLUMNS": "", "LINES": ""},
+		{"COLUMNS": "wide", "LINES": "tall"},
+		{"COLUMNS": "0", "LINES": "-1"},
+	} {
+		withEnv(t, env, func() {
+			assert.Equal(t, GetSize(f.Fd()), Size{Columns: DefaultColumns, Rows: DefaultRows})
+		})
+	}
+}
+
+func TestNotifyResizeStop(t *testing.T) {
+	c := make(chan Size, 1)
+	stop := NotifyResize(os.Stdout.Fd(), c)
+	stop()
+	stop()
+}